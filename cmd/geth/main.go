@@ -143,6 +143,7 @@ func init() {
 	app.Commands = []cli.Command{
 		initCommand,
 		dumpCommand,
+		recordsCommand,
 		consoleCommand,
 		accountCommand,
 	}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"AQChainRe/cmd/utils"
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/log"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	recordBlockFlag = cli.Int64Flag{
+		Name:  "block",
+		Usage: "Block number of the record state to export (default: latest)",
+		Value: -1,
+	}
+	recordFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Export format (jsonl|csv)",
+		Value: "jsonl",
+	}
+
+	recordsCommand = cli.Command{
+		Name:     "records",
+		Usage:    "Export, verify and prune the record state",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Export the record state of a block for offline audits, and verify an export by
+recomputing its record root.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Stream every record of a block to a file",
+				ArgsUsage: "[<file>]",
+				Action:    utils.MigrateFlags(exportRecords),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					recordBlockFlag,
+					recordFormatFlag,
+				},
+				Description: `
+    geth records export --block N --format jsonl|csv [<file>]
+
+Writes every record of the state at block N (key, trie key, origin, owner,
+status, transactions and storage metadata) one per line, to stdout if no file
+is given. The first line holds the block and its record root. Records and
+their storage are keyed by their trie keys, so nodes without the trie
+preimages leave the record key empty but can still export and verify.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Recompute the record root of an export",
+				ArgsUsage: "<file>",
+				Action:    utils.MigrateFlags(verifyRecords),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+    geth records verify <file>
+
+Rebuilds the record trie from an export in either format and checks that its
+root matches the record root of the exported block in the local chain.`,
+			},
+			{
+				Name:      "prune",
+				Usage:     "Prune the payloads of erased records from block bodies",
+				ArgsUsage: "[<recordKey>...]",
+				Action:    utils.MigrateFlags(pruneRecords),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+    geth records prune [<recordKey>...]
+
+Removes the payloads carried by the transactions of records erased by a
+tombstone from the locally stored block bodies, for the given records or every
+erased record of the current state. The records keep their keys, origins and
+transaction lists, so their provenance still verifies. Pruned block bodies no
+longer match their transaction roots and cannot be served to syncing peers.`,
+			},
+		},
+	}
+)
+
+// recordExportHeader is the first line of a record export.
+type recordExportHeader struct {
+	Block      uint64      `json:"block"`
+	Hash       common.Hash `json:"hash"`
+	RecordRoot common.Hash `json:"recordRoot"`
+}
+
+// csvRecordColumns are the columns of a CSV record export.
+var csvRecordColumns = []string{"key", "hash", "origin", "owner", "status", "txs", "storage"}
+
+func exportRecords(ctx *cli.Context) error {
+	format := ctx.String(recordFormatFlag.Name)
+	if format != "jsonl" && format != "csv" {
+		utils.Fatalf("Unknown export format %q", format)
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var block *types.Block
+	if number := ctx.Int64(recordBlockFlag.Name); number < 0 {
+		block = chain.CurrentBlock()
+	} else {
+		block = chain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		utils.Fatalf("block not found")
+	}
+	stateRecord, err := state.NewRecord(block.RecordRoot(), state.NewDatabase(chainDb))
+	if err != nil {
+		utils.Fatalf("could not open record state: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if path := ctx.Args().First(); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Failed to create export file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	header := recordExportHeader{Block: block.NumberU64(), Hash: block.Hash(), RecordRoot: block.RecordRoot()}
+
+	var (
+		write  func(*state.ExportedRecord) error
+		csvOut *csv.Writer
+	)
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(writer)
+		if err := enc.Encode(header); err != nil {
+			return err
+		}
+		write = func(record *state.ExportedRecord) error { return enc.Encode(record) }
+	case "csv":
+		fmt.Fprintf(writer, "# block=%d hash=%s recordRoot=%s\n", header.Block, header.Hash.Hex(), header.RecordRoot.Hex())
+		csvOut = csv.NewWriter(writer)
+		if err := csvOut.Write(csvRecordColumns); err != nil {
+			return err
+		}
+		write = func(record *state.ExportedRecord) error { return csvOut.Write(encodeRecordCSV(record)) }
+	}
+
+	exported := 0
+	err = stateRecord.ForEachRecord(func(record *state.ExportedRecord) error {
+		exported++
+		return write(record)
+	})
+	if err != nil {
+		utils.Fatalf("Export failed: %v", err)
+	}
+	if csvOut != nil {
+		csvOut.Flush()
+		if err := csvOut.Error(); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("Exported record state", "block", header.Block, "records", exported, "root", header.RecordRoot)
+	return nil
+}
+
+func verifyRecords(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	if len(path) == 0 {
+		utils.Fatalf("Must supply path to the record export")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		utils.Fatalf("Failed to open export file: %v", err)
+	}
+	defer file.Close()
+
+	builder, err := state.NewRecordRootBuilder()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	first, err := reader.ReadString('\n')
+	if err != nil {
+		utils.Fatalf("Failed to read export header: %v", err)
+	}
+
+	// 首行为 JSON 时按 jsonl 解析, 为注释时按 csv 解析
+	var (
+		header   recordExportHeader
+		verified int
+	)
+	if strings.HasPrefix(first, "#") {
+		if header, err = decodeCSVHeader(first); err != nil {
+			utils.Fatalf("Invalid export header: %v", err)
+		}
+		records := csv.NewReader(reader)
+		records.FieldsPerRecord = len(csvRecordColumns)
+		if _, err := records.Read(); err != nil {
+			utils.Fatalf("Failed to read column names: %v", err)
+		}
+		for {
+			row, err := records.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				utils.Fatalf("Failed to read record: %v", err)
+			}
+			record, err := decodeRecordCSV(row)
+			if err != nil {
+				utils.Fatalf("Invalid record %q: %v", row[1], err)
+			}
+			if err := builder.Add(record); err != nil {
+				return err
+			}
+			verified++
+		}
+	} else {
+		if err := json.Unmarshal([]byte(first), &header); err != nil {
+			utils.Fatalf("Invalid export header: %v", err)
+		}
+		dec := json.NewDecoder(reader)
+		for {
+			record := new(state.ExportedRecord)
+			if err := dec.Decode(record); err == io.EOF {
+				break
+			} else if err != nil {
+				utils.Fatalf("Failed to read record: %v", err)
+			}
+			if err := builder.Add(record); err != nil {
+				return err
+			}
+			verified++
+		}
+	}
+	root, err := builder.Root()
+	if err != nil {
+		return err
+	}
+	// 以本地链上该区块的记录根为准, 而不是导出文件首行中的根
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.GetBlockByNumber(header.Block)
+	if block == nil {
+		utils.Fatalf("Block %d of the export not found in the local chain", header.Block)
+	}
+	if block.Hash() != header.Hash {
+		utils.Fatalf("Block %d of the export is not canonical: export has %x, chain has %x", header.Block, header.Hash, block.Hash())
+	}
+	if root != block.RecordRoot() {
+		utils.Fatalf("Record root mismatch: block %d has %x, recomputed %x", header.Block, block.RecordRoot(), root)
+	}
+	log.Info("Verified record export", "block", header.Block, "records", verified, "root", root)
+	return nil
+}
+
+func pruneRecords(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.CurrentBlock()
+	stateRecord, err := state.NewRecord(block.RecordRoot(), state.NewDatabase(chainDb))
+	if err != nil {
+		utils.Fatalf("could not open record state: %v", err)
+	}
+	var records []*state.ExportedRecord
+	if ctx.NArg() > 0 {
+		for _, arg := range ctx.Args() {
+			key := common.HexToHash(arg)
+			if !stateRecord.Exist(key) {
+				utils.Fatalf("Record %x not found", key)
+			}
+			if !stateRecord.IsErased(key) {
+				utils.Fatalf("Record %x has not been erased", key)
+			}
+			records = append(records, &state.ExportedRecord{Key: key, Txs: stateRecord.GetRecordTxs(key)})
+		}
+	} else {
+		err = stateRecord.ForEachRecord(func(record *state.ExportedRecord) error {
+			if record.Status == state.RecordErased {
+				records = append(records, record)
+			}
+			return nil
+		})
+		if err != nil {
+			utils.Fatalf("Failed to collect erased records: %v", err)
+		}
+	}
+
+	total := 0
+	for _, record := range records {
+		pruned, err := core.PruneRecordPayloads(chainDb, chain.Config(), stateRecord, record.Key, record.Txs)
+		if err != nil {
+			utils.Fatalf("Failed to prune record %x: %v", record.Key, err)
+		}
+		total += pruned
+	}
+	log.Info("Pruned erased record payloads", "block", block.NumberU64(), "records", len(records), "txs", total)
+	return nil
+}
+
+// encodeRecordCSV flattens a record into a CSV row, joining the transactions
+// and the storage entries with semicolons.
+func encodeRecordCSV(record *state.ExportedRecord) []string {
+	txs := make([]string, len(record.Txs))
+	for i, tx := range record.Txs {
+		txs[i] = tx.Hex()
+	}
+	storage := make([]string, 0, len(record.Storage))
+	for key, value := range record.Storage {
+		storage = append(storage, key.Hex()+"="+value.Hex())
+	}
+	key := ""
+	if record.Key != (common.Hash{}) {
+		key = record.Key.Hex()
+	}
+	return []string{
+		key,
+		record.Hash.Hex(),
+		record.Origin.Hex(),
+		record.Owner.Hex(),
+		strconv.Itoa(int(record.Status)),
+		strings.Join(txs, ";"),
+		strings.Join(storage, ";"),
+	}
+}
+
+// decodeRecordCSV restores a record from a CSV row.
+func decodeRecordCSV(row []string) (*state.ExportedRecord, error) {
+	status, err := strconv.ParseUint(row[4], 10, 8)
+	if err != nil {
+		return nil, err
+	}
+	record := &state.ExportedRecord{
+		Key:     common.HexToHash(row[0]),
+		Hash:    common.HexToHash(row[1]),
+		Origin:  common.HexToAddress(row[2]),
+		Owner:   common.HexToAddress(row[3]),
+		Status:  uint8(status),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	if row[5] != "" {
+		for _, tx := range strings.Split(row[5], ";") {
+			record.Txs = append(record.Txs, common.HexToHash(tx))
+		}
+	}
+	if row[6] != "" {
+		for _, entry := range strings.Split(row[6], ";") {
+			kv := strings.SplitN(entry, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid storage entry %q", entry)
+			}
+			record.Storage[common.HexToHash(kv[0])] = common.HexToHash(kv[1])
+		}
+	}
+	return record, nil
+}
+
+// decodeCSVHeader parses the leading comment line of a CSV export.
+func decodeCSVHeader(line string) (recordExportHeader, error) {
+	var header recordExportHeader
+	for _, field := range strings.Fields(strings.TrimPrefix(line, "#")) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return header, fmt.Errorf("invalid header field %q", field)
+		}
+		switch kv[0] {
+		case "block":
+			number, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return header, err
+			}
+			header.Block = number
+		case "hash":
+			header.Hash = common.HexToHash(kv[1])
+		case "recordRoot":
+			header.RecordRoot = common.HexToHash(kv[1])
+		}
+	}
+	if header.RecordRoot == (common.Hash{}) {
+		return header, fmt.Errorf("missing record root")
+	}
+	return header, nil
+}
@@ -24,6 +24,7 @@ import (
 	"AQChainRe/pkg/accounts"
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/crypto/ecies"
 	"AQChainRe/pkg/event"
 	types "AQChainRe/pkg/core/types"
 	"crypto/ecdsa"
@@ -296,6 +297,29 @@ func (ks *KeyStore) SignHashWithPassphrase(a accounts.Account, passphrase string
 	return crypto.Sign(hash, key.PrivateKey)
 }
 
+// DecryptWithPassphrase decrypts an ECIES ciphertext addressed to the account
+// if its private key can be decrypted with the given passphrase.
+func (ks *KeyStore) DecryptWithPassphrase(a accounts.Account, passphrase string, ciphertext []byte) ([]byte, error) {
+	_, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	return ecies.ImportECDSA(key.PrivateKey).Decrypt(crand.Reader, ciphertext, nil, nil)
+}
+
+// PublicKeyWithPassphrase returns the public key of the account if its private
+// key can be decrypted with the given passphrase.
+func (ks *KeyStore) PublicKeyWithPassphrase(a accounts.Account, passphrase string) (*ecdsa.PublicKey, error) {
+	_, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	pub := key.PrivateKey.PublicKey
+	return &pub, nil
+}
+
 // SignTxWithPassphrase signs the transaction if the private key matching the
 // given address can be decrypted with the given passphrase.
 func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
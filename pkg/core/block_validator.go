@@ -68,6 +68,28 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions()); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	return ValidateBlockLimits(v.config, block.Number(), block.Transactions())
+}
+
+// ValidateBlockLimits checks that the transactions of a block with the given
+// number fit into the block limits of the chain, from the block limits fork on.
+func ValidateBlockLimits(config *params.ChainConfig, number *big.Int, txs types.Transactions) error {
+	if !config.IsBlockLimits(number) {
+		return nil
+	}
+	limits := config.BlockLimits
+	if limits.MaxTxs > 0 && uint64(len(txs)) > limits.MaxTxs {
+		return ErrBlockTxLimit
+	}
+	if limits.MaxPayloadBytes > 0 {
+		var payload uint64
+		for _, tx := range txs {
+			payload += tx.PayloadSize()
+		}
+		if payload > limits.MaxPayloadBytes {
+			return ErrBlockPayloadLimit
+		}
+	}
 	return nil
 }
 
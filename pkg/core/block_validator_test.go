@@ -17,10 +17,12 @@
 package core
 
 import (
+	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/consensus/ethash"
 	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/params"
+	"math/big"
 	"runtime"
 	"testing"
 	"time"
@@ -195,3 +197,37 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 		t.Errorf("verification count too large: have %d, want below %d", verified, 2*threads)
 	}
 }
+
+// Tests that blocks exceeding the transaction count or payload limits of the
+// chain are rejected from the block limits fork on.
+func TestValidateBlockLimits(t *testing.T) {
+	config := *params.TestChainConfig
+	config.BlockLimitsBlock = big.NewInt(2)
+	config.BlockLimits = &params.BlockLimits{MaxTxs: 3, MaxPayloadBytes: 10}
+
+	tx := func(payload string) *types.Transaction {
+		return types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, []byte(payload))
+	}
+	tests := []struct {
+		txs types.Transactions
+		err error
+	}{
+		{types.Transactions{}, nil},
+		{types.Transactions{tx("12345"), tx("12345")}, nil},
+		{types.Transactions{tx("12345"), tx("123456")}, ErrBlockPayloadLimit},
+		{types.Transactions{tx("1"), tx("2"), tx("3")}, nil},
+		{types.Transactions{tx("1"), tx("2"), tx("3"), tx("4")}, ErrBlockTxLimit},
+	}
+	for i, test := range tests {
+		if err := ValidateBlockLimits(&config, big.NewInt(2), test.txs); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+	// Blocks before the fork and chains without limits accept any block
+	if err := ValidateBlockLimits(&config, big.NewInt(1), tests[4].txs); err != nil {
+		t.Errorf("block before the fork rejected: %v", err)
+	}
+	if err := ValidateBlockLimits(params.TestChainConfig, big.NewInt(2), tests[4].txs); err != nil {
+		t.Errorf("unbounded chain rejected block: %v", err)
+	}
+}
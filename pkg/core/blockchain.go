@@ -303,6 +303,14 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	if _, err := trie.NewSecure(block.Root(), bc.chainDb, 0); err != nil {
 		return err
 	}
+	if _, err := trie.NewSecure(block.RecordRoot(), bc.chainDb, 0); err != nil {
+		return err
+	}
+	if context := block.Header().PocContext; context != nil {
+		if _, err := types.NewPocContextFromProto(bc.chainDb, context); err != nil {
+			return err
+		}
+	}
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock = block
@@ -398,6 +406,16 @@ func (bc *BlockChain) StateRecordAt(root common.Hash) (*state.StateDBRecord, err
 	return state.NewRecord(root, bc.stateCache)
 }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// PocContextAt returns the poc context of a particular block.
+func (bc *BlockChain) PocContextAt(header *types.Header) (*types.PocContext, error) {
+	return types.NewPocContextFromProto(bc.chainDb, header.PocContext)
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -512,13 +530,18 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 }
 
 // GetBodyRLP retrieves a block body in RLP encoding from the database by hash,
-// caching it if found.
+// caching it if found. Bodies with pruned record payloads no longer match their
+// header and are not returned.
 func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyRLPCache.Get(hash); ok {
 		return cached.(rlp.RawValue)
 	}
-	body := GetBodyRLP(bc.chainDb, hash, bc.hc.GetBlockNumber(hash))
+	number := bc.hc.GetBlockNumber(hash)
+	if IsBodyPruned(bc.chainDb, hash, number) {
+		return nil
+	}
+	body := GetBodyRLP(bc.chainDb, hash, number)
 	if len(body) == 0 {
 		return nil
 	}
@@ -44,6 +44,8 @@ type BlockGen struct {
 	header        *types.Header
 	stateDB       *state.StateDB
 	stateDBRecord *state.StateDBRecord
+	pocContext    *types.PocContext
+	db            ethdb.Database
 
 	txs      []*types.Transaction
 	receipts []*types.Receipt
@@ -122,6 +124,20 @@ func (b *BlockGen) PrevBlock(index int) *types.Block {
 	return b.chain[index]
 }
 
+// PocContext returns the poc context of the block being generated, opened on
+// the parent's context. Changes made to it are committed with the block; if it
+// is never requested the block inherits the parent's context as is.
+func (b *BlockGen) PocContext() *types.PocContext {
+	if b.pocContext == nil {
+		pocContext, err := types.NewPocContextFromProto(b.db, b.parent.Header().PocContext)
+		if err != nil {
+			panic(err)
+		}
+		b.pocContext = pocContext
+	}
+	return b.pocContext
+}
+
 // OffsetTime modifies the time instance of a block, implicitly changing its
 // associated difficulty. It's useful to test scenarios where forking is not
 // tied to chain length directly.
@@ -151,7 +167,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 	}
 	blocks, receipts := make(types.Blocks, n), make([]types.Receipts, n)
 	genblock := func(i int, h *types.Header, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (*types.Block, types.Receipts) {
-		b := &BlockGen{parent: parent, i: i, chain: blocks, header: h, stateDB: statedb, stateDBRecord: statedbRecord, config: config}
+		b := &BlockGen{parent: parent, i: i, chain: blocks, header: h, stateDB: statedb, stateDBRecord: statedbRecord, db: db, config: config}
 		// Mutate the state and block according to any hard-fork specs
 		if daoBlock := config.DAOForkBlock; daoBlock != nil {
 			limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
@@ -164,6 +180,9 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(h.Number) == 0 {
 			misc.ApplyDAOHardFork(statedb)
 		}
+		if config.IsRecordTrie(h.Number) {
+			statedb.EnableRecordTrie()
+		}
 		// Execute any user modifications to the block and finalize it
 		if gen != nil {
 			gen(i, b)
@@ -177,6 +196,11 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 		h.Root = root
 		h.RecordRoot = recordRoot
 		h.PocContext = parent.Header().PocContext
+		if b.pocContext != nil {
+			if h.PocContext, err = b.pocContext.CommitTo(db); err != nil {
+				panic(fmt.Sprintf("poc context write error: %v", err))
+			}
+		}
 		return types.NewBlock(h, b.txs, b.uncles, b.receipts), b.receipts
 	}
 	for i := 0; i < n; i++ {
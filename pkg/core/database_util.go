@@ -190,6 +190,7 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 		log.Error("Invalid block body RLP", "hash", hash, "err", err)
 		return nil
 	}
+	restorePrunedTxs(db, hash, number, body)
 	return body
 }
 
@@ -520,6 +521,7 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 // DeleteBody removes all block body data associated with a hash.
 func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	db.Delete(prunedBodyKey(hash, number))
 }
 
 // DeleteTd removes all block total difficulty data associated with a hash.
@@ -32,4 +32,44 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrBlockTxLimit is returned if a block holds more transactions than the
+	// block limits allow.
+	ErrBlockTxLimit = errors.New("block exceeds the transaction limit")
+
+	// ErrBlockPayloadLimit is returned if the transactions of a block carry more
+	// payload bytes than the block limits allow.
+	ErrBlockPayloadLimit = errors.New("block exceeds the payload limit")
+
+	// ErrTxTypeNotActive is returned if a transaction of a type introduced by a
+	// fork is included in a block before that fork.
+	ErrTxTypeNotActive = errors.New("transaction type not active")
+
+	// ErrTxEnvelopeNotActive is returned if a transaction carrying envelope
+	// extensions is included in a block before the transaction envelope fork.
+	ErrTxEnvelopeNotActive = errors.New("transaction envelope extensions not active")
+
+	// ErrTxNotYetValid is returned if a transaction is included in a block
+	// before its validity window opens.
+	ErrTxNotYetValid = errors.New("transaction not yet valid")
+
+	// ErrTxExpired is returned if a transaction is included in a block after
+	// its validity window has closed.
+	ErrTxExpired = errors.New("transaction expired")
+
+	// ErrNotMultisig is returned if a multisig transaction is sent by an
+	// account which is not a multisig account.
+	ErrNotMultisig = errors.New("sender is not a multisig account")
+
+	// ErrUnknownSigner is returned if a multisig transaction is signed by an
+	// account which is not a signer of the multisig account.
+	ErrUnknownSigner = errors.New("unknown multisig signer")
+
+	// ErrMultisigQuorum is returned if a multisig transaction is signed by
+	// fewer signers than the threshold of the multisig account.
+	ErrMultisigQuorum = errors.New("multisig threshold not reached")
+
+	// ErrMultisigExists is returned if a multisig creation derives the address
+	// of an existing multisig account.
+	ErrMultisigExists = errors.New("multisig account already exists")
 )
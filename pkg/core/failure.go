@@ -0,0 +1,38 @@
+package core
+
+import (
+	"AQChainRe/pkg/core/types"
+)
+
+// failureCodes maps the errors of transactions included as failed to the
+// reason stored in their receipts.
+var failureCodes = map[error]types.FailureCode{
+	ErrInsufficientBalance:   types.FailureInsufficientBalance,
+	types.ErrEmptyWrappedKey: types.FailureInvalidPayload,
+	types.ErrInvalidProposal: types.FailureInvalidPayload,
+	ErrRecordExists:          types.FailureDuplicateRecord,
+	ErrUnknownRecord:         types.FailureUnknownRecord,
+	ErrRecordExpired:         types.FailureRecordExpired,
+	ErrRecordErased:          types.FailureRecordErased,
+	ErrNotRecordOwner:        types.FailureNotOwner,
+	ErrNotJointOwner:         types.FailureNotOwner,
+	ErrRecordNotTransferable: types.FailureRecordFrozen,
+	ErrNoTransferOffer:       types.FailureNoTransferOffer,
+	ErrAskPriceMismatch:      types.FailureInvalidValue,
+	ErrNotTakedownApprover:   types.FailureNotApprover,
+	ErrAlreadyApproved:       types.FailureAlreadyApproved,
+	ErrExpiryPassed:          types.FailureInvalidExpiry,
+	ErrRecordNotExpiring:     types.FailureInvalidExpiry,
+	ErrAlreadyCandidate:      types.FailureAlreadyCandidate,
+	ErrNotCandidate:          types.FailureNotCandidate,
+	ErrMultisigExists:        types.FailureMultisigExists,
+}
+
+// failureCode returns the reason a transaction included as failed with the
+// given error failed.
+func failureCode(err error) types.FailureCode {
+	if code, ok := failureCodes[err]; ok {
+		return code
+	}
+	return types.FailureUnknown
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"errors"
+	"math/big"
+)
+
+// ErrInsufficientFee is returned if the sender cannot pay the fee of a
+// transaction. Such a transaction is invalid and never enters a block.
+var ErrInsufficientFee = errors.New("insufficient balance to pay the fee")
+
+// payFee moves the fee of a transaction from its payer, the sender or the
+// sponsor, to the validator of the block. It runs before the transaction takes effect, so a transaction
+// that fails afterwards still pays for the space it occupies.
+func payFee(statedb *state.StateDB, payer, validator common.Address, fee *big.Int) error {
+	if fee == nil || fee.Sign() == 0 {
+		return nil
+	}
+	if statedb.GetBalance(payer).Cmp(fee) < 0 {
+		return ErrInsufficientFee
+	}
+	Transfer(statedb, payer, validator, fee)
+	return nil
+}
+
+// TxCost returns the most a transaction takes from the balance of its sender
+// at block number: the fee plus the value it spends. Before the fee fork the
+// legacy value + gasprice * gaslimit is kept. The fee of a sponsored
+// transaction is paid by the sponsor, its sender only spends the value.
+func TxCost(config *params.ChainConfig, number *big.Int, tx *types.Transaction, from common.Address) *big.Int {
+	if tx.Sponsored() {
+		return tx.Spent(from)
+	}
+	if !config.IsFee(number) {
+		return tx.Cost()
+	}
+	return new(big.Int).Add(tx.Fee(config, number), tx.Spent(from))
+}
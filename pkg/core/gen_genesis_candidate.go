@@ -0,0 +1,43 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/common/math"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+var _ = (*genesisCandidateMarshaling)(nil)
+
+func (g GenesisCandidate) MarshalJSON() ([]byte, error) {
+	type GenesisCandidate struct {
+		Address      common.Address        `json:"address"      gencodec:"required"`
+		Contribution *math.HexOrDecimal256 `json:"contribution"`
+	}
+	var enc GenesisCandidate
+	enc.Address = g.Address
+	enc.Contribution = (*math.HexOrDecimal256)(g.Contribution)
+	return json.Marshal(&enc)
+}
+
+func (g *GenesisCandidate) UnmarshalJSON(input []byte) error {
+	type GenesisCandidate struct {
+		Address      *common.Address       `json:"address"      gencodec:"required"`
+		Contribution *math.HexOrDecimal256 `json:"contribution"`
+	}
+	var dec GenesisCandidate
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Address == nil {
+		return errors.New("missing required field 'address' for GenesisCandidate")
+	}
+	g.Address = *dec.Address
+	if dec.Contribution != nil {
+		g.Contribution = (*big.Int)(dec.Contribution)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/common/math"
+	"encoding/json"
+	"errors"
+)
+
+var _ = (*genesisRecordMarshaling)(nil)
+
+func (g GenesisRecord) MarshalJSON() ([]byte, error) {
+	type GenesisRecord struct {
+		Key      common.Hash                 `json:"key"      gencodec:"required"`
+		Origin   common.Address              `json:"origin"`
+		Owner    common.Address              `json:"owner"    gencodec:"required"`
+		Status   math.HexOrDecimal64         `json:"status,omitempty"`
+		Metadata map[storageJSON]storageJSON `json:"metadata,omitempty"`
+	}
+	var enc GenesisRecord
+	enc.Key = g.Key
+	enc.Origin = g.Origin
+	enc.Owner = g.Owner
+	enc.Status = math.HexOrDecimal64(g.Status)
+	if g.Metadata != nil {
+		enc.Metadata = make(map[storageJSON]storageJSON, len(g.Metadata))
+		for k, v := range g.Metadata {
+			enc.Metadata[storageJSON(k)] = storageJSON(v)
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+func (g *GenesisRecord) UnmarshalJSON(input []byte) error {
+	type GenesisRecord struct {
+		Key      *common.Hash                `json:"key"      gencodec:"required"`
+		Origin   *common.Address             `json:"origin"`
+		Owner    *common.Address             `json:"owner"    gencodec:"required"`
+		Status   *math.HexOrDecimal64        `json:"status,omitempty"`
+		Metadata map[storageJSON]storageJSON `json:"metadata,omitempty"`
+	}
+	var dec GenesisRecord
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Key == nil {
+		return errors.New("missing required field 'key' for GenesisRecord")
+	}
+	g.Key = *dec.Key
+	if dec.Origin != nil {
+		g.Origin = *dec.Origin
+	}
+	if dec.Owner == nil {
+		return errors.New("missing required field 'owner' for GenesisRecord")
+	}
+	g.Owner = *dec.Owner
+	if dec.Status != nil {
+		g.Status = uint8(*dec.Status)
+	}
+	if dec.Metadata != nil {
+		g.Metadata = make(map[common.Hash]common.Hash, len(dec.Metadata))
+		for k, v := range dec.Metadata {
+			g.Metadata[common.Hash(k)] = common.Hash(v)
+		}
+	}
+	return nil
+}
@@ -37,6 +37,8 @@ import (
 
 //go:generate gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
 //go:generate gencodec -type GenesisAccount -field-override genesisAccountMarshaling -out gen_genesis_account.go
+//go:generate gencodec -type GenesisRecord -field-override genesisRecordMarshaling -out gen_genesis_record.go
+//go:generate gencodec -type GenesisCandidate -field-override genesisCandidateMarshaling -out gen_genesis_candidate.go
 
 var errGenesisNoConfig = errors.New("genesis has no chain configuration")
 
@@ -53,6 +55,10 @@ type Genesis struct {
 	Coinbase   common.Address      `json:"coinbase"`
 	Alloc      GenesisAlloc        `json:"alloc"      gencodec:"required"`
 
+	// 创世块中预先登记的数据记录和候选者
+	Records    []GenesisRecord    `json:"records,omitempty"`
+	Candidates []GenesisCandidate `json:"candidates,omitempty"`
+
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
 	Number     uint64      `json:"number"`
@@ -84,6 +90,22 @@ type GenesisAccount struct {
 	PrivateKey []byte                      `json:"secretKey,omitempty"` // for tests
 }
 
+// GenesisRecord is a data record registered in the genesis block.
+type GenesisRecord struct {
+	Key      common.Hash                 `json:"key"      gencodec:"required"`
+	Origin   common.Address              `json:"origin"`
+	Owner    common.Address              `json:"owner"    gencodec:"required"`
+	Status   uint8                       `json:"status,omitempty"`
+	Metadata map[common.Hash]common.Hash `json:"metadata,omitempty"`
+}
+
+// GenesisCandidate is a validator candidate registered in the genesis block
+// together with its initial contribution.
+type GenesisCandidate struct {
+	Address      common.Address `json:"address"      gencodec:"required"`
+	Contribution *big.Int       `json:"contribution"`
+}
+
 // field type overrides for gencodec
 type genesisSpecMarshaling struct {
 	Nonce      math.HexOrDecimal64
@@ -104,6 +126,15 @@ type genesisAccountMarshaling struct {
 	PrivateKey hexutil.Bytes
 }
 
+type genesisRecordMarshaling struct {
+	Status   math.HexOrDecimal64
+	Metadata map[storageJSON]storageJSON
+}
+
+type genesisCandidateMarshaling struct {
+	Contribution *math.HexOrDecimal256
+}
+
 // storageJSON represents a 256 bit byte array, but allows less than 256 bits when
 // unmarshaling from hex.
 type storageJSON common.Hash
@@ -152,6 +183,11 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	if genesis != nil && genesis.Config == nil {
 		return params.PocChainConfig, common.Hash{}, errGenesisNoConfig
 	}
+	if genesis != nil {
+		if err := genesis.checkRecords(); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 
 	// Just commit the new block if there is no stored genesis block.
 	stored := GetCanonicalHash(db, 0)
@@ -215,6 +251,19 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	}
 }
 
+// checkRecords ensures that no record key is registered twice, which would
+// silently merge the records into the last one.
+func (g *Genesis) checkRecords() error {
+	keys := make(map[common.Hash]bool, len(g.Records))
+	for _, record := range g.Records {
+		if keys[record.Key] {
+			return fmt.Errorf("genesis record %x registered twice", record.Key)
+		}
+		keys[record.Key] = true
+	}
+	return nil
+}
+
 // ToBlock creates the block and state of a genesis specification.
 func (g *Genesis) ToBlock() (*types.Block, *state.StateDB, *state.StateDBRecord) {
 	db, _ := ethdb.NewMemDatabase()
@@ -228,6 +277,27 @@ func (g *Genesis) ToBlock() (*types.Block, *state.StateDB, *state.StateDBRecord)
 			statedb.SetState(addr, key, value)
 		}
 	}
+	if g.Config != nil && g.Config.IsRecordTrie(new(big.Int).SetUint64(g.Number)) {
+		statedb.EnableRecordTrie()
+	}
+	for _, record := range g.Records {
+		origin := record.Origin
+		if origin == (common.Address{}) {
+			origin = record.Owner
+		}
+		statedbRecord.SetOrigin(record.Key, origin)
+		statedbRecord.SetOwner(record.Key, record.Owner)
+		statedbRecord.SetStatus(record.Key, record.Status)
+		for key, value := range record.Metadata {
+			statedbRecord.SetState(record.Key, key, value)
+		}
+		statedb.AddRecords(record.Owner, record.Key)
+	}
+	for _, candidate := range g.Candidates {
+		if candidate.Contribution != nil {
+			statedb.SetContribution(candidate.Address, candidate.Contribution)
+		}
+	}
 	root := statedb.IntermediateRoot(false)
 	rootRecord := statedbRecord.IntermediateRoot(false)
 
@@ -359,5 +429,18 @@ func initGenesisPocContext(g *Genesis, db ethdb.Database) *types.PocContext {
 			dc.CandidateTrie().TryUpdate(validator.Bytes(), validator.Bytes())
 		}
 	}
+	// 预先登记的候选者及其贡献值
+	if len(g.Candidates) > 0 {
+		contributions := make([]types.AccountContribution, 0, len(g.Candidates))
+		for _, candidate := range g.Candidates {
+			dc.CandidateTrie().TryUpdate(candidate.Address.Bytes(), candidate.Address.Bytes())
+			contribution := candidate.Contribution
+			if contribution == nil {
+				contribution = new(big.Int)
+			}
+			contributions = append(contributions, types.AccountContribution{Account: candidate.Address, Contribution: contribution})
+		}
+		dc.SetContributions(contributions)
+	}
 	return dc
 }
@@ -19,8 +19,11 @@ package core
 import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/consensus/ethash"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/params"
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -113,3 +116,84 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+func TestGenesisRecordsAndCandidates(t *testing.T) {
+	var (
+		owner     = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		origin    = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		candidate = common.HexToAddress("0x1000000000000000000000000000000000000003")
+		key       = common.HexToHash("0x01")
+	)
+	input := `{
+		"config": {"chainId": 1},
+		"gasLimit": "0x47b760",
+		"difficulty": "0x1",
+		"alloc": {},
+		"records": [{"key": "` + key.Hex() + `", "origin": "` + origin.Hex() + `", "owner": "` + owner.Hex() + `", "metadata": {"0x01": "0x02"}}],
+		"candidates": [{"address": "` + candidate.Hex() + `", "contribution": "1000"}]
+	}`
+	genesis := new(Genesis)
+	if err := json.Unmarshal([]byte(input), genesis); err != nil {
+		t.Fatalf("failed to decode genesis: %v", err)
+	}
+	// 编码后重新解码应得到同样的创世块
+	enc, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatalf("failed to encode genesis: %v", err)
+	}
+	decoded := new(Genesis)
+	if err := json.Unmarshal(enc, decoded); err != nil {
+		t.Fatalf("failed to decode encoded genesis: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Records, genesis.Records) || len(decoded.Candidates) != 1 || decoded.Candidates[0].Contribution.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("genesis marshalling mismatch: have %s", enc)
+	}
+
+	db, _ := ethdb.NewMemDatabase()
+	block := genesis.MustCommit(db)
+
+	statedbRecord, err := state.NewRecord(block.RecordRoot(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open record state: %v", err)
+	}
+	if !statedbRecord.Exist(key) {
+		t.Fatalf("genesis record missing")
+	}
+	if have := statedbRecord.GetOwner(key); have != owner {
+		t.Errorf("owner mismatch: have %x, want %x", have, owner)
+	}
+	if have := statedbRecord.GetOrigin(key); have != origin {
+		t.Errorf("origin mismatch: have %x, want %x", have, origin)
+	}
+	if have := statedbRecord.GetState(key, common.HexToHash("0x01")); have != common.HexToHash("0x02") {
+		t.Errorf("metadata mismatch: have %x", have)
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	if !statedb.HasRecord(owner, key) {
+		t.Errorf("genesis record not indexed under its owner")
+	}
+	if have := statedb.GetContribution(candidate); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("contribution mismatch: have %v, want 1000", have)
+	}
+
+	candidateTrie, err := types.NewCandidateTrie(block.Header().PocContext.CandidateHash, db)
+	if err != nil {
+		t.Fatalf("failed to open candidate trie: %v", err)
+	}
+	if candidateTrie.Get(candidate.Bytes()) == nil {
+		t.Errorf("genesis candidate missing from candidate trie")
+	}
+
+	// A record key registered twice is rejected instead of silently merged
+	genesis.Records = append(genesis.Records, GenesisRecord{Key: key, Owner: origin})
+	db, _ = ethdb.NewMemDatabase()
+	if _, _, err := SetupGenesisBlock(db, genesis); err == nil {
+		t.Fatalf("genesis with a duplicate record accepted")
+	}
+	if stored := GetCanonicalHash(db, 0); stored != (common.Hash{}) {
+		t.Fatalf("genesis with a duplicate record written")
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"math/big"
+)
+
+// applyMultiOperationMessage runs the operations of a multi-operation
+// transaction in order. The nonce is checked and the fee paid once for the
+// whole transaction. If an operation fails, the changes of all operations are
+// reverted from snapshots of the state, the record state and the poc context,
+// and the transaction is included as failed so the fee and nonce stay spent.
+// The result of every operation is returned for the receipt, the failing one
+// carrying the reason it failed. An error is only returned if the transaction
+// can't be included at all.
+func applyMultiOperationMessage(config *params.ChainConfig, txHash common.Hash, number *big.Int, msg Message, statedb *state.StateDB, statedbRecord *state.StateDBRecord, pocContext *types.PocContext, validator common.Address, fee *big.Int) (bool, []*types.OperationResult, error) {
+	st := NewStateTransition(msg, statedb)
+	if err := st.preCheck(); err != nil {
+		return false, nil, err
+	}
+	multi, err := types.DecodeMultiOperation(msg.Data())
+	if err != nil {
+		return false, nil, err
+	}
+	// 操作的类型同样要在分叉后才能打包
+	for _, op := range multi.Ops {
+		if err := checkTxType(config, op.Type, number); err != nil {
+			return false, nil, err
+		}
+	}
+	if err := payFee(statedb, st.payer(), validator, fee); err != nil {
+		return false, nil, err
+	}
+	sender := st.from()
+	nonce := statedb.GetNonce(sender)
+
+	var (
+		snap       = statedb.Snapshot()
+		snapRecord = statedbRecord.Snapshot()
+		pocSnap    = pocContext.Snapshot()
+		results    = make([]*types.OperationResult, len(multi.Ops))
+		failed     bool
+	)
+	for i, op := range multi.Ops {
+		if failed {
+			results[i] = &types.OperationResult{Status: types.OperationStatusSkipped}
+			continue
+		}
+		opMsg := op.AsMessage(sender, nonce)
+		var opFailed bool
+		if op.Type == types.Binary {
+			_, opFailed, err = ApplyMessage(opMsg, statedb, validator, nil)
+		} else {
+			opFailed, err = ApplyDataMessage(config, txHash, number, opMsg, statedb, statedbRecord, validator, nil)
+		}
+		if opFailed || err != nil {
+			failed = true
+			results[i] = &types.OperationResult{Status: types.ReceiptStatusFailed, Failure: failureCode(err)}
+			continue
+		}
+		results[i] = &types.OperationResult{Status: types.ReceiptStatusSuccessful}
+	}
+	if failed {
+		statedb.RevertToSnapshot(snap)
+		statedbRecord.RevertToSnapshot(snapRecord)
+		pocContext.RevertToSnapShot(pocSnap)
+	}
+	// 所有操作共用一个 nonce, 各操作的递增不计
+	statedb.SetNonce(sender, nonce+1)
+	return failed, results, nil
+}
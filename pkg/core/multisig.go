@@ -0,0 +1,84 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"math/big"
+)
+
+// applyMultisigCreation creates the multisig account derived from the sender
+// and the transaction nonce, storing its signers and threshold in the
+// storage of the account.
+func applyMultisigCreation(msg Message, statedb *state.StateDB, validator common.Address, fee *big.Int) (bool, error) {
+	st := NewStateTransition(msg, statedb)
+	if err := st.preCheck(); err != nil {
+		return false, err
+	}
+	sender := st.from()
+	if err := payFee(statedb, st.payer(), validator, fee); err != nil {
+		return false, err
+	}
+	statedb.SetNonce(sender, msg.Nonce()+1)
+
+	account, err := types.DecodeMultisigAccount(msg.Data())
+	if err != nil {
+		return true, err
+	}
+	addr := types.MultisigAddress(sender, msg.Nonce())
+	if statedb.IsMultisig(addr) {
+		return true, ErrMultisigExists
+	}
+	statedb.SetMultisig(addr, account.Signers, account.Threshold)
+	statedb.AddLog(&types.Log{
+		Address: sender,
+		Topics:  []common.Hash{MultisigCreatedTopic, addr.Hash(), sender.Hash()},
+		Data:    common.BigToHash(new(big.Int).SetUint64(account.Threshold)).Bytes(),
+	})
+	return false, nil
+}
+
+// checkMultisig verifies that a transaction sent by a multisig account is
+// signed by enough distinct signers of the account. Other transactions are
+// accepted as they are.
+func checkMultisig(statedb *state.StateDB, signer types.Signer, tx *types.Transaction) error {
+	missing, err := MultisigQuorum(statedb, signer, tx)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		return ErrMultisigQuorum
+	}
+	return nil
+}
+
+// MultisigQuorum returns how many more signers of the multisig account a
+// transaction is sent from have to sign it before it reaches the threshold.
+// Signatures of accounts that are not signers are an error, an unsigned
+// transaction has no signers yet. Other transactions need no signers.
+func MultisigQuorum(statedb *state.StateDB, signer types.Signer, tx *types.Transaction) (uint64, error) {
+	account, ok := tx.MultisigAccount()
+	if !ok {
+		return 0, nil
+	}
+	members, threshold := statedb.GetMultisig(account)
+	if threshold == 0 {
+		return 0, ErrNotMultisig
+	}
+	if _, r, _ := tx.RawSignatureValues(); r == nil || r.Sign() == 0 {
+		return threshold, nil
+	}
+	signers, err := types.MultisigSigners(signer, tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, addr := range signers {
+		if !containsAddress(members, addr) {
+			return 0, ErrUnknownSigner
+		}
+	}
+	if uint64(len(signers)) >= threshold {
+		return 0, nil
+	}
+	return threshold - uint64(len(signers)), nil
+}
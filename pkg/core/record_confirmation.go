@@ -0,0 +1,17 @@
+package core
+
+import (
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"math/big"
+)
+
+// decodeConfirmation returns the record confirmed by a ConfirmationData payload
+// at block number. Before the record market fork the payload is the record
+// data itself, from the fork on it carries the data along with its terms.
+func decodeConfirmation(config *params.ChainConfig, number *big.Int, payload []byte) (*types.RecordConfirmation, error) {
+	if !config.IsRecordMarket(number) {
+		return &types.RecordConfirmation{Data: payload}, nil
+	}
+	return types.DecodeRecordConfirmation(payload)
+}
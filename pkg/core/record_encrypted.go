@@ -0,0 +1,27 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+)
+
+// recordKeyGrant resolves the key grant carried by a data transaction on an
+// encrypted record. Payloads are matched against plaintext records first, so
+// only payloads not naming an existing record are decoded as grants.
+func recordKeyGrant(txType types.TxType, payload []byte, hash common.Hash, statedbRecord *state.StateDBRecord) *types.KeyGrant {
+	switch txType {
+	case types.AuthorizationData, types.TransferData, types.TransferOffer, types.TransferAccept, types.TransferCancel,
+		types.RecordRenewal:
+	default:
+		return nil
+	}
+	if statedbRecord.Exist(hash) {
+		return nil
+	}
+	grant, err := types.DecodeKeyGrant(payload)
+	if err != nil || !statedbRecord.IsEncrypted(grant.Record) {
+		return nil
+	}
+	return grant
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrRecordNotExpiring = errors.New("record does not expire")
+	ErrExpiryPassed      = errors.New("record expiry block already passed")
+	ErrRecordExpired     = errors.New("record has expired")
+)
+
+// expireRecord lazily removes a record whose expiry block has passed. The
+// record is dropped from the accounts referencing it and marked as expired,
+// after which it is treated as non-existent and deleted from the record trie
+// in Finalise. It reports whether the record had expired.
+func expireRecord(statedb *state.StateDB, statedbRecord *state.StateDBRecord, record common.Hash, number *big.Int) bool {
+	if !statedbRecord.Exist(record) || !statedbRecord.IsExpired(record, number.Uint64()) {
+		return false
+	}
+	if statedbRecord.GetStatus(record) == state.RecordPendingTransfer {
+		to, _, _ := statedbRecord.GetTransferOffer(record)
+		statedb.RemovePendingOffer(to, record)
+	}
+	owners, _ := statedbRecord.GetJointOwners(record)
+	if owner := statedbRecord.GetOwner(record); owner != (common.Address{}) {
+		owners = append(owners, owner)
+	}
+	for _, owner := range owners {
+		statedb.RemoveRecords(owner, record)
+	}
+	return statedbRecord.Expire(record)
+}
+
+// expireRecords drops the expired records and lapsed transfer offers among the
+// records a transaction operates on. Failed transactions keep this cleanup
+// when the rest of their changes are reverted.
+func expireRecords(config *params.ChainConfig, number *big.Int, tx *types.Transaction, statedb *state.StateDB, statedbRecord *state.StateDBRecord) {
+	for _, record := range RecordKeys(config, number, tx, statedbRecord) {
+		if !expireRecord(statedb, statedbRecord, record, number) {
+			expireTransferOffer(statedb, statedbRecord, record, number)
+		}
+	}
+}
+
+// renewRecord extends the expiry of a record by the renewal period. Only the
+// owner or a co-owner may renew, paying the renewal fee which is burned.
+func renewRecord(txHash common.Hash, sender common.Address, record common.Hash, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (bool, error) {
+	if !statedbRecord.Exist(record) {
+		return true, ErrUnknownRecord
+	}
+	owners, _ := statedbRecord.GetJointOwners(record)
+	if statedbRecord.GetOwner(record) != sender && !containsAddress(owners, sender) {
+		return true, ErrNotRecordOwner
+	}
+	expiry := statedbRecord.GetExpiry(record)
+	if expiry == 0 {
+		return true, ErrRecordNotExpiring
+	}
+	fee := new(big.Int).SetUint64(params.RecordRenewalFee)
+	if !CanTransfer(statedb, sender, fee) {
+		return true, ErrInsufficientBalance
+	}
+	statedb.SubBalance(sender, fee)
+	statedbRecord.SetExpiry(record, expiry+params.RecordRenewalPeriod)
+	statedbRecord.AddTxHash(record, txHash)
+	return false, nil
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/log"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"encoding/binary"
+	"math/big"
+)
+
+var (
+	recordHistoryPrefix        = []byte("Rh") // recordHistoryPrefix + record hash -> sections, + section (uint64 big endian) -> history entries
+	recordHistorySectionPrefix = []byte("Rt") // recordHistorySectionPrefix + section (uint64 big endian) -> record hashes touched in the section
+
+	// RecordHistoryIndexPrefix is the data table of the record history chain indexer to track its progress
+	RecordHistoryIndexPrefix = []byte("iRh")
+)
+
+// RecordHistoryEntry is a single provenance step of a record.
+type RecordHistoryEntry struct {
+	Type        types.TxType
+	From        common.Address
+	To          common.Address
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Time        *big.Int
+	TxHash      common.Hash
+	TxIndex     uint64
+}
+
+// RecordKeys returns the keys of the records touched by a data transaction
+// included at block number. Key grants on encrypted records are told apart from
+// plaintext payloads the same way the state transition does, against the given
+// record state.
+func RecordKeys(config *params.ChainConfig, number *big.Int, tx *types.Transaction, statedbRecord *state.StateDBRecord) []common.Hash {
+	switch tx.Type() {
+	case types.ConfirmationData:
+		record, err := decodeConfirmation(config, number, tx.Data())
+		if err != nil {
+			return nil
+		}
+		return []common.Hash{record.Key()}
+	case types.AuthorizationData, types.TransferData, types.TransferOffer, types.TransferAccept, types.TransferCancel,
+		types.RecordRenewal:
+		b, _ := rlp.EncodeToBytes(tx.Data())
+		hash := common.BytesToHash(b)
+		// 加密记录的交易数据为内容密钥授予
+		if grant := recordKeyGrant(tx.Type(), tx.Data(), hash, statedbRecord); grant != nil {
+			return []common.Hash{grant.Record}
+		}
+		return []common.Hash{hash}
+	case types.EncryptedConfirmationData:
+		record, err := types.DecodeEncryptedRecord(tx.Data())
+		if err != nil {
+			return nil
+		}
+		return []common.Hash{record.Key()}
+	case types.BatchConfirmationData:
+		batch, err := types.DecodeBatchConfirmation(tx.Data())
+		if err != nil {
+			return nil
+		}
+		return append([]common.Hash{batch.Root}, batch.Leaves...)
+	case types.JointOwnershipData:
+		joint, err := types.DecodeJointOwnership(tx.Data())
+		if err != nil {
+			return nil
+		}
+		return []common.Hash{joint.Record}
+	case types.JointApprovalData:
+		proposal, err := types.DecodeRecordProposal(tx.Data())
+		if err != nil {
+			return nil
+		}
+		return []common.Hash{proposal.Record}
+	case types.RecordTombstone:
+		tombstone, err := types.DecodeTombstone(tx.Data())
+		if err != nil {
+			return nil
+		}
+		return []common.Hash{tombstone.Record}
+	case types.MultiOperationData:
+		var keys []common.Hash
+		for _, op := range recordOperations(tx) {
+			keys = append(keys, RecordKeys(config, number, op, statedbRecord)...)
+		}
+		return keys
+	}
+	return nil
+}
+
+// recordOperations returns the operations of a transaction as unsigned
+// transactions: the operations of a multi-operation, or the transaction itself.
+func recordOperations(tx *types.Transaction) []*types.Transaction {
+	if tx.Type() != types.MultiOperationData {
+		return []*types.Transaction{tx}
+	}
+	multi, err := types.DecodeMultiOperation(tx.Data())
+	if err != nil {
+		return nil
+	}
+	txs := make([]*types.Transaction, len(multi.Ops))
+	for i, op := range multi.Ops {
+		txs[i] = op.Transaction(tx.Nonce())
+	}
+	return txs
+}
+
+// DeriveRecordHistory extracts the provenance entries of all successful data
+// transactions in a block, grouped by record key in transaction order. Key
+// grants are resolved against the record state the block committed to, as the
+// encryption of a record is fixed once it is confirmed.
+func DeriveRecordHistory(config *params.ChainConfig, block *types.Block, receipts types.Receipts, statedbRecord *state.StateDBRecord) map[common.Hash][]RecordHistoryEntry {
+	history := make(map[common.Hash][]RecordHistoryEntry)
+	signer := types.MakeSigner(config, block.Number())
+
+	for i, tx := range block.Transactions() {
+		keys := RecordKeys(config, block.Number(), tx, statedbRecord)
+		if len(keys) == 0 {
+			continue
+		}
+		if i < len(receipts) && len(receipts[i].PostState) == 0 && receipts[i].Status == types.ReceiptStatusFailed {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		// 多操作交易的每个操作作为一条来源记录, 类型和接收者取自操作
+		for _, op := range recordOperations(tx) {
+			entry := RecordHistoryEntry{
+				Type:        op.Type(),
+				From:        from,
+				BlockHash:   block.Hash(),
+				BlockNumber: block.NumberU64(),
+				Time:        block.Time(),
+				TxHash:      tx.Hash(),
+				TxIndex:     uint64(i),
+			}
+			if to := op.To(); to != nil {
+				entry.To = *to
+			} else {
+				entry.To = from
+			}
+			for _, key := range RecordKeys(config, block.Number(), op, statedbRecord) {
+				history[key] = append(history[key], entry)
+			}
+		}
+	}
+	return history
+}
+
+func encodeSection(section uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, section)
+	return enc
+}
+
+func recordHistoryKey(key common.Hash, section uint64) []byte {
+	return append(append(append([]byte{}, recordHistoryPrefix...), key[:]...), encodeSection(section)...)
+}
+
+// GetRecordHistorySections retrieves the indexed sections holding history of a record.
+func GetRecordHistorySections(db DatabaseReader, key common.Hash) []uint64 {
+	data, _ := db.Get(append(append([]byte{}, recordHistoryPrefix...), key[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	var sections []uint64
+	if err := rlp.DecodeBytes(data, &sections); err != nil {
+		log.Error("Invalid record history sections RLP", "key", key, "err", err)
+		return nil
+	}
+	return sections
+}
+
+// GetRecordHistory retrieves the indexed history entries of a record in a section.
+func GetRecordHistory(db DatabaseReader, key common.Hash, section uint64) []RecordHistoryEntry {
+	data, _ := db.Get(recordHistoryKey(key, section))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []RecordHistoryEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid record history RLP", "key", key, "section", section, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WriteRecordHistorySection stores the history entries produced by a whole
+// section, replacing anything previously indexed for that section.
+func WriteRecordHistorySection(db ethdb.Database, section uint64, history map[common.Hash][]RecordHistoryEntry) error {
+	if err := DeleteRecordHistorySection(db, section); err != nil {
+		return err
+	}
+	batch := db.NewBatch()
+	keys := make([]common.Hash, 0, len(history))
+	for key, entries := range history {
+		data, err := rlp.EncodeToBytes(entries)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(recordHistoryKey(key, section), data); err != nil {
+			return err
+		}
+		sections := append(GetRecordHistorySections(db, key), section)
+		data, err = rlp.EncodeToBytes(sections)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(append(append([]byte{}, recordHistoryPrefix...), key[:]...), data); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	data, err := rlp.EncodeToBytes(keys)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(append(append([]byte{}, recordHistorySectionPrefix...), encodeSection(section)...), data); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// DeleteRecordHistorySection removes the history entries indexed for a section,
+// used when the section is reprocessed after a reorg.
+func DeleteRecordHistorySection(db ethdb.Database, section uint64) error {
+	sectionKey := append(append([]byte{}, recordHistorySectionPrefix...), encodeSection(section)...)
+	data, _ := db.Get(sectionKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var keys []common.Hash
+	if err := rlp.DecodeBytes(data, &keys); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		var kept []uint64
+		for _, s := range GetRecordHistorySections(db, key) {
+			if s != section {
+				kept = append(kept, s)
+			}
+		}
+		enc, err := rlp.EncodeToBytes(kept)
+		if err != nil {
+			return err
+		}
+		if err := db.Put(append(append([]byte{}, recordHistoryPrefix...), key[:]...), enc); err != nil {
+			return err
+		}
+		if err := db.Delete(recordHistoryKey(key, section)); err != nil {
+			return err
+		}
+	}
+	return db.Delete(sectionKey)
+}
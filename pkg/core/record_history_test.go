@@ -0,0 +1,64 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/params"
+	"math/big"
+	"testing"
+)
+
+// Tests that the record history of signed data transactions is derived from a
+// block and survives a store, reindex and delete cycle.
+func TestRecordHistoryStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x0000000000000000000000000000000000000aaa")
+	signer := types.MakeSigner(params.TestChainConfig, big.NewInt(1))
+
+	confirm, _ := types.SignTx(types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, []byte("document")), signer, key)
+	transfer, _ := types.SignTx(types.NewTransaction(types.TransferData, 1, to, nil, nil, nil, []byte("document")), signer, key)
+	binary, _ := types.SignTx(types.NewTransaction(types.Binary, 2, to, big.NewInt(1), nil, nil, nil), signer, key)
+
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), Time: big.NewInt(10)}, []*types.Transaction{confirm, binary, transfer}, nil, nil)
+	statedbRecord, _ := state.NewRecord(common.Hash{}, state.NewDatabase(db))
+	history := DeriveRecordHistory(params.TestChainConfig, block, nil, statedbRecord)
+
+	record := RecordKeys(params.TestChainConfig, common.Big0, confirm, statedbRecord)[0]
+	if len(history) != 1 || len(history[record]) != 2 {
+		t.Fatalf("history mismatch: have %v, want 2 entries of %x", history, record)
+	}
+	if entry := history[record][0]; entry.Type != types.ConfirmationData || entry.From != from || entry.To != from || entry.TxIndex != 0 {
+		t.Fatalf("confirmation entry mismatch: %+v", entry)
+	}
+	if entry := history[record][1]; entry.Type != types.TransferData || entry.From != from || entry.To != to || entry.TxIndex != 2 {
+		t.Fatalf("transfer entry mismatch: %+v", entry)
+	}
+	// Store the section twice (reindex after a reorg) and check nothing is duplicated
+	for i := 0; i < 2; i++ {
+		if err := WriteRecordHistorySection(db, 3, history); err != nil {
+			t.Fatalf("failed to write record history: %v", err)
+		}
+	}
+	if sections := GetRecordHistorySections(db, record); len(sections) != 1 || sections[0] != 3 {
+		t.Fatalf("sections mismatch: have %v, want [3]", sections)
+	}
+	if entries := GetRecordHistory(db, record, 3); len(entries) != 2 || entries[1].TxHash != transfer.Hash() {
+		t.Fatalf("stored history mismatch: %+v", entries)
+	}
+	// Drop the section and verify the record has no history left
+	if err := DeleteRecordHistorySection(db, 3); err != nil {
+		t.Fatalf("failed to delete record history: %v", err)
+	}
+	if sections := GetRecordHistorySections(db, record); len(sections) != 0 {
+		t.Fatalf("sections left after delete: %v", sections)
+	}
+	if entries := GetRecordHistory(db, record, 3); len(entries) != 0 {
+		t.Fatalf("history left after delete: %v", entries)
+	}
+}
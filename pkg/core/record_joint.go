@@ -0,0 +1,125 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrNotJointOwner   = errors.New("sender is not a co-owner of the record")
+	ErrAlreadyApproved = errors.New("proposal already approved by the co-owner")
+)
+
+// applyJointOwnership turns a singly owned record into one owned by a set of
+// co-owners. The record keeps no single owner afterwards, so it can only be
+// transferred or have its status changed through approved proposals.
+func applyJointOwnership(config *params.ChainConfig, txHash common.Hash, number *big.Int, sender common.Address, joint *types.JointOwnership, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (bool, error) {
+	record := joint.Record
+	if expireRecord(statedb, statedbRecord, record, number) {
+		return true, ErrRecordExpired
+	}
+	if !statedbRecord.Exist(record) {
+		return true, ErrUnknownRecord
+	}
+	expireTransferOffer(statedb, statedbRecord, record, number)
+
+	if statedbRecord.GetOwner(record) != sender {
+		return true, ErrNotRecordOwner
+	}
+	if statedbRecord.GetStatus(record) != state.RecordNormal {
+		return true, ErrRecordNotTransferable
+	}
+	statedbRecord.SetJointOwners(record, joint.Owners, joint.Threshold)
+	statedbRecord.SetOwner(record, common.Address{})
+	statedbRecord.SetAskPrice(record, new(big.Int))
+	statedbRecord.AddTxHash(record, txHash)
+
+	// 记录出现在每个共有人的账户记录中
+	removeAccountRecord(config, number, statedb, txHash, record, sender)
+	for _, owner := range joint.Owners {
+		addAccountRecord(config, number, statedb, txHash, record, owner)
+	}
+	logRecordTransferred(statedb, sender, record, sender, common.Address{})
+	return false, nil
+}
+
+// applyJointApproval counts the approval of a co-owner towards a proposal,
+// opening it on the first approval, and executes it once the threshold of
+// the record is reached. Expired proposals are dropped along the way.
+func applyJointApproval(config *params.ChainConfig, txHash common.Hash, number *big.Int, sender common.Address, proposal *types.RecordProposal, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (bool, error) {
+	record := proposal.Record
+	if expireRecord(statedb, statedbRecord, record, number) {
+		return true, ErrRecordExpired
+	}
+	owners, threshold := statedbRecord.GetJointOwners(record)
+	if !containsAddress(owners, sender) {
+		return true, ErrNotJointOwner
+	}
+	if statedbRecord.IsErased(record) {
+		return true, ErrRecordErased
+	}
+	switch proposal.Action {
+	case types.ProposalTransfer:
+		if statedbRecord.GetStatus(record) != state.RecordNormal {
+			return true, ErrRecordNotTransferable
+		}
+	case types.ProposalStatus:
+		// 待转移状态只能由转移要约产生, 擦除状态只能由墓碑交易产生
+		if proposal.Status == state.RecordPendingTransfer || proposal.Status == state.RecordErased {
+			return true, types.ErrInvalidProposal
+		}
+	}
+	for _, id := range statedbRecord.GetProposals(record) {
+		if _, expiry, _ := statedbRecord.GetProposal(record, id); number.Uint64() > expiry {
+			statedbRecord.RemoveProposal(record, id)
+		}
+	}
+
+	id := proposal.ID()
+	if packed, _, _ := statedbRecord.GetProposal(record, id); packed == (common.Hash{}) {
+		statedbRecord.AddProposal(record, id, proposal.Pack(), number.Uint64()+params.RecordProposalDuration)
+	}
+	if statedbRecord.HasApproved(record, id, sender) {
+		return true, ErrAlreadyApproved
+	}
+	approvals := statedbRecord.Approve(record, id, sender)
+	statedbRecord.AddTxHash(record, txHash)
+	if approvals < threshold {
+		return false, nil
+	}
+
+	// 批准数达到门限, 执行提案
+	statedbRecord.RemoveProposal(record, id)
+	switch proposal.Action {
+	case types.ProposalStatus:
+		statedbRecord.SetStatus(record, proposal.Status)
+		logRecordStatus(statedb, statedbRecord, sender, record)
+
+	case types.ProposalTransfer:
+		for _, other := range statedbRecord.GetProposals(record) {
+			statedbRecord.RemoveProposal(record, other)
+		}
+		statedbRecord.SetJointOwners(record, nil, 0)
+		statedbRecord.SetOwner(record, proposal.To)
+		for _, owner := range owners {
+			removeAccountRecord(config, number, statedb, txHash, record, owner)
+		}
+		addAccountRecord(config, number, statedb, txHash, record, proposal.To)
+		// 共有记录没有单一的原拥有者
+		logRecordTransferred(statedb, sender, record, common.Address{}, proposal.To)
+	}
+	return false, nil
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+)
+
+// logRecordConfirmed emits the RecordConfirmed log of a newly created record.
+func logRecordConfirmed(statedb *state.StateDB, origin common.Address, record common.Hash) {
+	addRecordLog(statedb, origin, RecordConfirmedTopic, record, origin.Hash())
+}
+
+// logRecordTransferred emits the RecordTransferred log of an ownership change.
+// The zero address stands for the co-owners of a jointly owned record.
+func logRecordTransferred(statedb *state.StateDB, sender common.Address, record common.Hash, from, to common.Address) {
+	addRecordLog(statedb, sender, RecordTransferredTopic, record, from.Hash(), to.Hash())
+}
+
+// logAuthorizationGranted emits the AuthorizationGranted log of an authorization.
+func logAuthorizationGranted(statedb *state.StateDB, sender common.Address, record common.Hash, grantee common.Address) {
+	addRecordLog(statedb, sender, AuthorizationGrantedTopic, record, sender.Hash(), grantee.Hash())
+}
+
+// logRecordStatus emits the RecordStatusChanged log carrying the current owner
+// and status of a record.
+func logRecordStatus(statedb *state.StateDB, statedbRecord *state.StateDBRecord, sender common.Address, record common.Hash) {
+	status := common.BytesToHash([]byte{statedbRecord.GetStatus(record)})
+	addRecordLog(statedb, sender, RecordStatusChangedTopic, record, statedbRecord.GetOwner(record).Hash(), status)
+}
+
+// addRecordLog emits a log of a data-record or poc transaction. The event
+// topic is followed by the record key and the parties involved, so that the
+// bloom filters of the block can match on any of them.
+func addRecordLog(statedb *state.StateDB, sender common.Address, topic common.Hash, topics ...common.Hash) {
+	statedb.AddLog(&types.Log{
+		Address: sender,
+		Topics:  append([]common.Hash{topic}, topics...),
+	})
+}
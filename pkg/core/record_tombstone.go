@@ -0,0 +1,219 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/log"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	prunedPayloadPrefix = []byte("pruned-tx-")   // prunedPayloadPrefix + tx hash -> flag, the payload of the transaction was pruned from its block body
+	prunedBodyPrefix    = []byte("pruned-body-") // prunedBodyPrefix + num (uint64 big endian) + hash -> pruned transactions of the block body
+)
+
+// prunedTx is a transaction of a block body whose payload was pruned, with the
+// hash it had before.
+type prunedTx struct {
+	Index uint64
+	Hash  common.Hash
+}
+
+var (
+	ErrRecordErased        = errors.New("record has been erased")
+	ErrNotTakedownApprover = errors.New("sender is neither the origin of the record nor a validator")
+)
+
+// applyTombstoneMessage erases a record. The origin of the record erases it
+// right away, otherwise the tombstone counts as the takedown approval of a
+// validator and the record is erased once more than two thirds of the
+// current validators approved.
+func applyTombstoneMessage(txHash common.Hash, number *big.Int, msg Message, statedb *state.StateDB, statedbRecord *state.StateDBRecord, pocContext *types.PocContext, validator common.Address, fee *big.Int) (bool, error) {
+	st := NewStateTransition(msg, statedb)
+	if err := st.preCheck(); err != nil {
+		return false, err
+	}
+	sender := st.from()
+	if err := payFee(statedb, st.payer(), validator, fee); err != nil {
+		return false, err
+	}
+	statedb.SetNonce(sender, statedb.GetNonce(sender)+1)
+
+	tombstone, err := types.DecodeTombstone(msg.Data())
+	if err != nil {
+		return true, err
+	}
+	record := tombstone.Record
+	if expireRecord(statedb, statedbRecord, record, number) {
+		return true, ErrRecordExpired
+	}
+	if !statedbRecord.Exist(record) {
+		return true, ErrUnknownRecord
+	}
+	if statedbRecord.IsErased(record) {
+		return true, ErrRecordErased
+	}
+
+	if statedbRecord.GetOrigin(record) != sender {
+		validators, err := pocContext.GetValidators()
+		if err != nil {
+			return true, err
+		}
+		if !containsAddress(validators, sender) {
+			return true, ErrNotTakedownApprover
+		}
+		if statedbRecord.HasApprovedTakedown(record, sender) {
+			return true, ErrAlreadyApproved
+		}
+		statedbRecord.ApproveTakedown(record, sender)
+		statedbRecord.AddTxHash(record, txHash)
+
+		// 只统计当前验证者的批准, 已离开的验证者的批准不再计入
+		approvals := 0
+		for _, validator := range validators {
+			if statedbRecord.HasApprovedTakedown(record, validator) {
+				approvals++
+			}
+		}
+		if approvals < len(validators)*2/3+1 {
+			return false, nil
+		}
+	} else {
+		statedbRecord.AddTxHash(record, txHash)
+	}
+	eraseRecord(statedb, statedbRecord, sender, record)
+	return false, nil
+}
+
+// eraseRecord tombstones a record, dropping the pending offer index of the
+// recipient of an open transfer offer.
+func eraseRecord(statedb *state.StateDB, statedbRecord *state.StateDBRecord, sender common.Address, record common.Hash) {
+	if statedbRecord.GetStatus(record) == state.RecordPendingTransfer {
+		to, _, _ := statedbRecord.GetTransferOffer(record)
+		statedb.RemovePendingOffer(to, record)
+	}
+	statedbRecord.Erase(record)
+	logRecordStatus(statedb, statedbRecord, sender, record)
+}
+
+// IsPayloadPruned reports whether the payload of a transaction was pruned from
+// its block body.
+func IsPayloadPruned(db DatabaseReader, hash common.Hash) bool {
+	data, _ := db.Get(append(append([]byte{}, prunedPayloadPrefix...), hash[:]...))
+	return len(data) > 0
+}
+
+// IsBodyPruned reports whether payloads were pruned from a block body. Such a
+// body no longer matches the transaction root of its header and must not be
+// served to peers.
+func IsBodyPruned(db DatabaseReader, hash common.Hash, number uint64) bool {
+	return len(getPrunedTxs(db, hash, number)) > 0
+}
+
+// getPrunedTxs retrieves the transactions of a block body whose payloads were
+// pruned, nil if none were.
+func getPrunedTxs(db DatabaseReader, hash common.Hash, number uint64) []prunedTx {
+	data, _ := db.Get(prunedBodyKey(hash, number))
+	if len(data) == 0 {
+		return nil
+	}
+	var txs []prunedTx
+	if err := rlp.DecodeBytes(data, &txs); err != nil {
+		log.Error("Invalid pruned transactions RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return txs
+}
+
+// restorePrunedTxs gives the pruned transactions of a block body back the
+// hashes they had before their payloads were pruned, so that lookups and
+// reorgs keep finding them.
+func restorePrunedTxs(db DatabaseReader, hash common.Hash, number uint64, body *types.Body) {
+	for _, pruned := range getPrunedTxs(db, hash, number) {
+		if pruned.Index < uint64(len(body.Transactions)) {
+			body.Transactions[pruned.Index] = body.Transactions[pruned.Index].WithoutPayload(pruned.Hash)
+		}
+	}
+}
+
+func prunedBodyKey(hash common.Hash, number uint64) []byte {
+	return append(append(append([]byte{}, prunedBodyPrefix...), encodeBlockNumber(number)...), hash[:]...)
+}
+
+// PruneRecordPayloads removes the payloads of the given transactions of an
+// erased record from the stored block bodies, reporting how many were pruned.
+// Tombstones carry only the record key and are kept, and so are transactions
+// touching other records as well, e.g. batch confirmations and multi-operations,
+// whose payloads the other records still need. The pruned transactions keep
+// their original hashes in a separate store, so lookups and reorgs still find
+// them, and the record keeps its key and transaction list, so its provenance
+// still verifies against anyone holding the original payload. Pruned bodies no
+// longer match their transaction root and are not served to peers.
+func PruneRecordPayloads(db ethdb.Database, config *params.ChainConfig, statedbRecord *state.StateDBRecord, record common.Hash, txs []common.Hash) (int, error) {
+	pruned := 0
+	for _, hash := range txs {
+		if IsPayloadPruned(db, hash) {
+			continue
+		}
+		blockHash, number, index := GetTxLookupEntry(db, hash)
+		if blockHash == (common.Hash{}) {
+			continue
+		}
+		body := GetBody(db, blockHash, number)
+		if body == nil || uint64(len(body.Transactions)) <= index {
+			return pruned, fmt.Errorf("missing body of transaction %x", hash)
+		}
+		tx := body.Transactions[index]
+		if tx.Hash() != hash {
+			return pruned, fmt.Errorf("transaction %x not found at its lookup entry", hash)
+		}
+		if tx.Type() == types.RecordTombstone || len(tx.Data()) == 0 {
+			continue
+		}
+		if !touchesOnly(RecordKeys(config, new(big.Int).SetUint64(number), tx, statedbRecord), record) {
+			log.Warn("Refusing to prune transaction touching other records", "record", record, "tx", hash)
+			continue
+		}
+		body.Transactions[index] = tx.WithoutPayload(hash)
+
+		batch := db.NewBatch()
+		if err := WriteBody(batch, blockHash, number, body); err != nil {
+			return pruned, err
+		}
+		data, err := rlp.EncodeToBytes(append(getPrunedTxs(db, blockHash, number), prunedTx{Index: index, Hash: hash}))
+		if err != nil {
+			return pruned, err
+		}
+		if err := batch.Put(prunedBodyKey(blockHash, number), data); err != nil {
+			return pruned, err
+		}
+		if err := batch.Put(append(append([]byte{}, prunedPayloadPrefix...), hash[:]...), []byte{1}); err != nil {
+			return pruned, err
+		}
+		if err := batch.Write(); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// touchesOnly reports whether the record keys of a transaction name the given
+// record and nothing else.
+func touchesOnly(keys []common.Hash, record common.Hash) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	for _, key := range keys {
+		if key != record {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/params"
+	"math/big"
+	"testing"
+)
+
+// Tests that pruning an erased record strips the payloads from the stored block
+// bodies while the transactions stay reachable by their original hashes, that
+// transactions touching other records are kept and that pruned bodies are not
+// served.
+func TestPruneRecordPayloads(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedbRecord, _ := state.NewRecord(common.Hash{}, state.NewDatabase(db))
+
+	key, _ := crypto.GenerateKey()
+	config := params.TestChainConfig
+	signer := types.MakeSigner(config, big.NewInt(1))
+	payload := []byte("leaked document")
+
+	confirm, _ := types.SignTx(types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, payload), signer, key)
+	record := RecordKeys(config, big.NewInt(1), confirm, statedbRecord)[0]
+	data, _ := (&types.Tombstone{Record: record}).EncodeToBytes()
+	tombstone, _ := types.SignTx(types.NewTransaction(types.RecordTombstone, 1, common.Address{}, nil, nil, nil, data), signer, key)
+	batch, _ := types.NewBatchConfirmation([]common.Hash{record, {0x02}})
+	data, _ = batch.EncodeToBytes()
+	shared, _ := types.SignTx(types.NewTransaction(types.BatchConfirmationData, 2, common.Address{}, nil, nil, nil, data), signer, key)
+
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{confirm, tombstone, shared}, nil, nil)
+	if err := WriteBlock(db, block); err != nil {
+		t.Fatalf("failed to write block: %v", err)
+	}
+	if err := WriteTxLookupEntries(db, block); err != nil {
+		t.Fatalf("failed to write lookup entries: %v", err)
+	}
+	txs := []common.Hash{confirm.Hash(), tombstone.Hash(), shared.Hash()}
+	for i := 0; i < 2; i++ {
+		pruned, err := PruneRecordPayloads(db, config, statedbRecord, record, txs)
+		if err != nil {
+			t.Fatalf("failed to prune payloads: %v", err)
+		}
+		if want := 1 - i; pruned != want {
+			t.Fatalf("run %d: pruned %d transactions, want %d", i, pruned, want)
+		}
+	}
+	if !IsPayloadPruned(db, confirm.Hash()) || IsPayloadPruned(db, tombstone.Hash()) || IsPayloadPruned(db, shared.Hash()) {
+		t.Fatalf("pruned flags mismatch")
+	}
+	body := GetBody(db, block.Hash(), block.NumberU64())
+	if len(body.Transactions[0].Data()) != 0 {
+		t.Fatalf("payload left in block body")
+	}
+	if len(body.Transactions[1].Data()) == 0 {
+		t.Fatalf("tombstone payload pruned")
+	}
+	if len(body.Transactions[2].Data()) == 0 {
+		t.Fatalf("payload of a transaction touching other records pruned")
+	}
+	for i, tx := range body.Transactions {
+		if tx.Hash() != txs[i] {
+			t.Fatalf("tx %d: hash mismatch: have %x, want %x", i, tx.Hash(), txs[i])
+		}
+	}
+	if blockHash, _, index := GetTxLookupEntry(db, confirm.Hash()); blockHash != block.Hash() || index != 0 {
+		t.Fatalf("lookup entry of pruned transaction lost")
+	}
+	if !IsBodyPruned(db, block.Hash(), block.NumberU64()) {
+		t.Fatalf("pruned body not marked")
+	}
+	DeleteBody(db, block.Hash(), block.NumberU64())
+	if IsBodyPruned(db, block.Hash(), block.NumberU64()) {
+		t.Fatalf("pruned transactions left after deleting the body")
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"math/big"
+)
+
+// payRecordPrice settles the sale of a record: the buyer pays the price, the
+// origin receives the royalty fixed at confirmation and the seller the rest.
+// The sale is recorded in the receipt as a RecordSold log.
+func payRecordPrice(statedb *state.StateDB, statedbRecord *state.StateDBRecord, record common.Hash, buyer, seller common.Address, price *big.Int) {
+	royalty := new(big.Int).Mul(price, new(big.Int).SetUint64(statedbRecord.GetRoyalty(record)))
+	royalty.Div(royalty, new(big.Int).SetUint64(params.RoyaltyBasisPoints))
+
+	statedb.SubBalance(buyer, price)
+	statedb.AddBalance(statedbRecord.GetOrigin(record), royalty)
+	statedb.AddBalance(seller, new(big.Int).Sub(price, royalty))
+
+	statedb.AddLog(&types.Log{
+		Address: buyer,
+		Topics:  []common.Hash{RecordSoldTopic, record, seller.Hash(), buyer.Hash()},
+		Data:    append(common.BigToHash(price).Bytes(), common.BigToHash(royalty).Bytes()...),
+	})
+}
+
+// expireTransferOffer lazily drops the transfer offer of a record once its
+// expiry block has passed, returning the record to the normal state.
+func expireTransferOffer(statedb *state.StateDB, statedbRecord *state.StateDBRecord, record common.Hash, number *big.Int) {
+	if statedbRecord.GetStatus(record) != state.RecordPendingTransfer {
+		return
+	}
+	to, _, expiry := statedbRecord.GetTransferOffer(record)
+	if number.Uint64() <= expiry {
+		return
+	}
+	statedbRecord.ClearTransferOffer(record)
+	statedb.RemovePendingOffer(to, record)
+	logRecordStatus(statedb, statedbRecord, statedbRecord.GetOwner(record), record)
+}
+
+// applyLegacyTransfer applies a TransferData transaction under the rules from
+// before the record market fork, so that old blocks replay into the state they
+// committed to: the value is ignored, and while the sender is checked against
+// the owner of the record, the transfer rewrites its origin.
+func applyLegacyTransfer(config *params.ChainConfig, number *big.Int, txHash, record common.Hash, sender, recipient common.Address, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (bool, error) {
+	if statedbRecord.GetOwner(record) != sender {
+		return true, ErrNotRecordOwner
+	}
+	if statedbRecord.GetStatus(record) != state.RecordNormal {
+		return true, ErrRecordNotTransferable
+	}
+	statedbRecord.SetOrigin(record, recipient)
+	statedbRecord.AddTxHash(record, txHash)
+	moveAccountRecord(config, number, statedb, txHash, record, sender, recipient)
+	statedb.AddContribution(sender, big.NewInt(1e+18))
+	return false, nil
+}
+
+// moveAccountRecord moves a transferred record between the records of the
+// accounts. Before the record trie fork accounts listed the transfer hash
+// instead of the record and never dropped their first entry, which is kept so
+// that old state roots stay unchanged.
+func moveAccountRecord(config *params.ChainConfig, number *big.Int, statedb *state.StateDB, txHash, record common.Hash, from, to common.Address) {
+	addAccountRecord(config, number, statedb, txHash, record, to)
+	removeAccountRecord(config, number, statedb, txHash, record, from)
+}
+
+// addAccountRecord lists a record among the records of an account, by the hash
+// of the transaction handing it over before the record trie fork.
+func addAccountRecord(config *params.ChainConfig, number *big.Int, statedb *state.StateDB, txHash, record common.Hash, addr common.Address) {
+	if config.IsRecordTrie(number) {
+		statedb.AddRecords(addr, record)
+		return
+	}
+	statedb.AddRecords(addr, txHash)
+}
+
+// removeAccountRecord drops a record from the records of an account, keeping
+// the legacy layout before the record trie fork like moveAccountRecord does.
+func removeAccountRecord(config *params.ChainConfig, number *big.Int, statedb *state.StateDB, txHash, record common.Hash, addr common.Address) {
+	if config.IsRecordTrie(number) {
+		statedb.RemoveRecords(addr, record)
+		return
+	}
+	statedb.RemoveLegacyRecords(addr, txHash)
+}
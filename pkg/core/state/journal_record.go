@@ -90,7 +90,7 @@ func (a addLogChangeRecord) undo(record *StateDBRecord) {
 }
 
 func (ch storageChangeRecord) undo(s *StateDBRecord) {
-	panic("implement me")
+	s.getStateObject(*ch.account).setState(ch.key, ch.prevalue)
 }
 
 func (t touchChangeRecord) undo(s *StateDBRecord) {
@@ -109,7 +109,7 @@ func (ch resetObjectChangeRecord) undo(s *StateDBRecord) {
 func (ch suicideChangeRecord) undo(s *StateDBRecord) {
 	obj := s.getStateObject(*ch.record)
 	if obj != nil {
-		//obj.suicided = ch.prev
+		obj.suicided = ch.prev
 		//obj.setBalance(ch.prevbalance)
 	}
 }
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/rlp"
+	"AQChainRe/pkg/trie"
+	"bytes"
+	"fmt"
+)
+
+// ExportedRecord is a record together with the metadata kept in its storage
+// trie, as streamed by ForEachRecord and consumed by RecordRootBuilder. The
+// record and its storage are exported under their trie keys, the hashes of the
+// record key and of the storage keys, so that nodes without the preimages, like
+// fast synced ones, can export and verify their state too.
+type ExportedRecord struct {
+	Key     common.Hash                 `json:"key"`  // 记录键, 没有原像时为空
+	Hash    common.Hash                 `json:"hash"` // 记录在状态树中的键
+	Origin  common.Address              `json:"origin"`
+	Owner   common.Address              `json:"owner"`
+	Status  uint8                       `json:"status"`
+	Txs     []common.Hash               `json:"txs"`
+	Storage map[common.Hash]common.Hash `json:"storage"` // 以存储树中的键为索引
+}
+
+// ForEachRecord streams every record of the state to the callback, one at a
+// time in trie order, stopping at the first error. Record keys are resolved
+// through the trie preimages where available.
+func (self *StateDBRecord) ForEachRecord(cb func(*ExportedRecord) error) error {
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		var data Record
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return fmt.Errorf("invalid record %x: %v", it.Key, err)
+		}
+		record := &ExportedRecord{
+			Key:     common.BytesToHash(self.trie.GetKey(it.Key)),
+			Hash:    common.BytesToHash(it.Key),
+			Origin:  data.Origin,
+			Owner:   data.Owner,
+			Status:  data.Status,
+			Txs:     data.Txs,
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		storage, err := self.db.OpenStorageTrie(record.Hash, data.Root)
+		if err != nil {
+			return fmt.Errorf("missing storage of record %x: %v", it.Key, err)
+		}
+		storageIt := trie.NewIterator(storage.NodeIterator(nil))
+		for storageIt.Next() {
+			_, content, _, err := rlp.Split(storageIt.Value)
+			if err != nil {
+				return fmt.Errorf("invalid storage value of record %x: %v", it.Key, err)
+			}
+			record.Storage[common.BytesToHash(storageIt.Key)] = common.BytesToHash(content)
+		}
+		if storageIt.Err != nil {
+			return storageIt.Err
+		}
+		if err := cb(record); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
+// RecordRootBuilder recomputes the record root of an exported record state.
+// The rebuilt trie is held entirely in memory.
+type RecordRootBuilder struct {
+	db   ethdb.Database
+	trie *trie.Trie
+}
+
+// NewRecordRootBuilder creates a builder on an empty in-memory database.
+func NewRecordRootBuilder() (*RecordRootBuilder, error) {
+	db, _ := ethdb.NewMemDatabase()
+	tr, err := trie.New(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordRootBuilder{db: db, trie: tr}, nil
+}
+
+// Add inserts an exported record into the rebuilt trie under its trie key,
+// along with its storage trie.
+func (b *RecordRootBuilder) Add(record *ExportedRecord) error {
+	storage, err := trie.New(common.Hash{}, b.db)
+	if err != nil {
+		return err
+	}
+	for key, value := range record.Storage {
+		// 与状态对象相同, 存储值去掉前导零后编码
+		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+		if err := storage.TryUpdate(key[:], v); err != nil {
+			return err
+		}
+	}
+	root, err := storage.CommitTo(b.db)
+	if err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(Record{
+		Origin: record.Origin,
+		Owner:  record.Owner,
+		Txs:    record.Txs,
+		Status: record.Status,
+		Root:   root,
+	})
+	if err != nil {
+		return err
+	}
+	return b.trie.TryUpdate(record.Hash[:], data)
+}
+
+// Root commits the records added so far and returns the resulting root.
+func (b *RecordRootBuilder) Root() (common.Hash, error) {
+	return b.trie.CommitTo(b.db)
+}
@@ -29,6 +29,9 @@ import (
 
 var emptyCodeHash = crypto.Keccak256(nil)
 
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
 type Code []byte
 
 func (self Code) String() string {
@@ -93,6 +96,12 @@ type stateObject struct {
 
 // empty returns whether the account is considered empty.
 func (s *stateObject) empty() bool {
+	// 开启记录树后, 拥有记录或转移要约索引的账户不再视为空账户
+	if s.db.recordTrie {
+		if len(s.data.Records) > 0 || len(s.dirtyStorage) > 0 || (s.data.Root != emptyRoot && s.data.Root != common.Hash{}) {
+			return false
+		}
+	}
 	return s.data.Nonce == 0 && s.data.Balance.Sign() == 0 && s.data.Contribution.Sign() == 0 && bytes.Equal(s.data.CodeHash, emptyCodeHash)
 }
 
@@ -276,6 +285,19 @@ func (self *stateObject) setRecords(txs []common.Hash) {
 	}
 }
 
+// migrateRecords moves the records still held in the account body into the
+// account's record trie. It is called when the object is written out, after
+// the journal of the transaction is no longer needed.
+func (self *stateObject) migrateRecords() {
+	if len(self.data.Records) == 0 {
+		return
+	}
+	for _, record := range self.data.Records {
+		self.setState(record, record)
+	}
+	self.data.Records = make([]common.Hash, 0)
+}
+
 // AddBalance removes amount from c's balance.
 // It is used to add funds to the destination prev of a transfer.
 func (c *stateObject) AddBalance(amount *big.Int) {
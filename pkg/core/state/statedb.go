@@ -19,6 +19,7 @@ package state
 
 import (
 	"AQChainRe/pkg/core/types"
+	"bytes"
 	"fmt"
 	"math/big"
 	"sort"
@@ -72,6 +73,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// 是否将账户拥有的记录保存在账户的记录树中, 由分叉区块开启
+	recordTrie bool
+
 	lock sync.Mutex
 }
 
@@ -327,45 +331,224 @@ func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 	}
 }
 
+// EnableRecordTrie switches the state to keep the records owned by an account
+// in the account's record trie instead of the account body. Accounts still
+// holding records in their body are migrated the next time they are written.
+func (self *StateDB) EnableRecordTrie() {
+	self.recordTrie = true
+}
+
 func (self *StateDB) AddRecords(addr common.Address, record common.Hash) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if self.recordTrie {
+			stateObject.SetState(self.db, record, record)
+			return
+		}
 		records := stateObject.Records()
 		// 遍历检查是否已经存在该记录 存在的话就不进行添加
-		if len(records) > 0 {
-			for _, r := range records {
-				if r == record {
-					return
-				}
+		for _, r := range records {
+			if r == record {
+				return
 			}
-			records = append(records, record)
-			stateObject.setRecords(records)
-		} else {
-			stateObject.setRecords([]common.Hash{record})
 		}
+		stateObject.SetRecords(append(append([]common.Hash{}, records...), record))
 	}
 }
 
 func (self *StateDB) RemoveRecords(addr common.Address, record common.Hash) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if self.recordTrie {
+			stateObject.SetState(self.db, record, common.Hash{})
+		}
+		// 未迁移的账户记录仍在账户数据中
 		records := stateObject.Records()
-		if len(records) > 0 {
-			index := -1
-			for k, r := range records {
-				if r == record {
-					index = k
-					break
-				}
+		for k, r := range records {
+			if r == record {
+				kept := append(append([]common.Hash{}, records[:k]...), records[k+1:]...)
+				stateObject.SetRecords(kept)
+				break
 			}
-			if index > 0 {
-				records = append(records[:index], records[index+1:]...)
-				stateObject.setRecords(records)
+		}
+	}
+}
+
+// RemoveLegacyRecords removes a record from the account body the way accounts
+// did before the record trie fork, never removing the first record. Blocks from
+// before the fork are replayed with it to reproduce their state roots.
+func (self *StateDB) RemoveLegacyRecords(addr common.Address, record common.Hash) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		records := stateObject.Records()
+		for k, r := range records {
+			if r == record {
+				if k > 0 {
+					kept := append(append([]common.Hash{}, records[:k]...), records[k+1:]...)
+					stateObject.SetRecords(kept)
+				}
+				break
 			}
 		}
 	}
 }
 
+// HasRecord reports whether the account owns the given record.
+func (self *StateDB) HasRecord(addr common.Address, record common.Hash) bool {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return false
+	}
+	for _, r := range stateObject.Records() {
+		if r == record {
+			return true
+		}
+	}
+	return stateObject.GetState(self.db, record) == record
+}
+
+// OwnedRecords returns up to limit records owned by the account starting at
+// the given cursor, along with the cursor of the next page (nil when done).
+// Accounts not yet migrated are paged through their record list, migrated ones
+// through their record trie.
+func (self *StateDB) OwnedRecords(addr common.Address, cursor []byte, limit int) ([]common.Hash, []byte) {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil || limit <= 0 {
+		return nil, nil
+	}
+	if records := stateObject.Records(); len(records) > 0 {
+		start := 0
+		if len(cursor) > 0 {
+			start = int(new(big.Int).SetBytes(cursor).Uint64())
+		}
+		if start >= len(records) {
+			return nil, nil
+		}
+		end := start + limit
+		if end >= len(records) {
+			return records[start:], nil
+		}
+		return records[start:end], new(big.Int).SetUint64(uint64(end)).Bytes()
+	}
+	var records []common.Hash
+	it := trie.NewIterator(self.StorageTrie(addr).NodeIterator(cursor))
+	for it.Next() {
+		if len(records) == limit {
+			return records, common.CopyBytes(it.Key)
+		}
+		_, content, _, err := rlp.Split(it.Value)
+		if err != nil {
+			self.setError(err)
+			return records, nil
+		}
+		// 记录以自身为键保存, 跳过存储树中的其它索引 (如转移要约)
+		record := common.BytesToHash(content)
+		if !bytes.Equal(crypto.Keccak256(record[:]), it.Key) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// pendingOfferCountKey is the storage slot of an account holding the number of
+// transfer offers addressed to it.
+var pendingOfferCountKey = crypto.Keccak256Hash([]byte("pendingOffers"))
+
+// pendingOfferSlot is the storage slot of an account holding the transfer offer
+// at the given position of its offer index.
+func pendingOfferSlot(index uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("pendingOfferSlot"), new(big.Int).SetUint64(index).Bytes())
+}
+
+// pendingOfferKey is the storage slot of an account holding the position of a
+// transfer offer in its offer index plus one, zero if the record is not offered.
+func pendingOfferKey(record common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("pendingOffer"), record[:])
+}
+
+// AddPendingOffer indexes a transfer offer of a record under its recipient.
+func (self *StateDB) AddPendingOffer(addr common.Address, record common.Hash) {
+	if self.GetState(addr, pendingOfferKey(record)) != (common.Hash{}) {
+		return
+	}
+	count := self.GetState(addr, pendingOfferCountKey).Big().Uint64()
+	self.SetState(addr, pendingOfferSlot(count), record)
+	self.SetState(addr, pendingOfferKey(record), common.BigToHash(new(big.Int).SetUint64(count+1)))
+	self.SetState(addr, pendingOfferCountKey, common.BigToHash(new(big.Int).SetUint64(count+1)))
+}
+
+// RemovePendingOffer drops a transfer offer from the index of its recipient,
+// moving the last offer of the index into the freed position.
+func (self *StateDB) RemovePendingOffer(addr common.Address, record common.Hash) {
+	if self.getStateObject(addr) == nil {
+		return
+	}
+	position := self.GetState(addr, pendingOfferKey(record)).Big().Uint64()
+	if position == 0 {
+		return
+	}
+	count := self.GetState(addr, pendingOfferCountKey).Big().Uint64()
+	if position < count {
+		last := self.GetState(addr, pendingOfferSlot(count-1))
+		self.SetState(addr, pendingOfferSlot(position-1), last)
+		self.SetState(addr, pendingOfferKey(last), common.BigToHash(new(big.Int).SetUint64(position)))
+	}
+	self.SetState(addr, pendingOfferSlot(count-1), common.Hash{})
+	self.SetState(addr, pendingOfferKey(record), common.Hash{})
+	self.SetState(addr, pendingOfferCountKey, common.BigToHash(new(big.Int).SetUint64(count-1)))
+}
+
+// PendingOffers returns the records offered to the account and not yet
+// accepted or cancelled. Expired offers are only dropped when touched again.
+func (self *StateDB) PendingOffers(addr common.Address) []common.Hash {
+	count := self.GetState(addr, pendingOfferCountKey).Big().Uint64()
+	if count == 0 {
+		return nil
+	}
+	records := make([]common.Hash, count)
+	for i := range records {
+		records[i] = self.GetState(addr, pendingOfferSlot(uint64(i)))
+	}
+	return records
+}
+
+// multisigKey is a storage slot of a multisig account holding its threshold,
+// its signer count or one of its signers, apart from the slots of its records.
+func multisigKey(field string, index uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte(field), new(big.Int).SetUint64(index).Bytes())
+}
+
+// SetMultisig turns the account into a multisig account controlled by the
+// signers with the given threshold.
+func (self *StateDB) SetMultisig(addr common.Address, signers []common.Address, threshold uint64) {
+	self.SetState(addr, multisigKey("multisigThreshold", 0), common.BigToHash(new(big.Int).SetUint64(threshold)))
+	self.SetState(addr, multisigKey("multisigSigners", 0), common.BigToHash(big.NewInt(int64(len(signers)))))
+	for i, signer := range signers {
+		self.SetState(addr, multisigKey("multisigSigner", uint64(i)), signer.Hash())
+	}
+}
+
+// GetMultisig returns the signers and the threshold of a multisig account, or
+// a zero threshold if the account is not a multisig account.
+func (self *StateDB) GetMultisig(addr common.Address) ([]common.Address, uint64) {
+	threshold := self.GetState(addr, multisigKey("multisigThreshold", 0)).Big().Uint64()
+	if threshold == 0 {
+		return nil, 0
+	}
+	count := self.GetState(addr, multisigKey("multisigSigners", 0)).Big().Uint64()
+	signers := make([]common.Address, count)
+	for i := range signers {
+		signers[i] = common.BytesToAddress(self.GetState(addr, multisigKey("multisigSigner", uint64(i))).Bytes())
+	}
+	return signers, threshold
+}
+
+// IsMultisig reports whether the account is a multisig account.
+func (self *StateDB) IsMultisig(addr common.Address) bool {
+	return self.GetState(addr, multisigKey("multisigThreshold", 0)) != (common.Hash{})
+}
+
 func (self *StateDB) SetRecords(addr common.Address, records []common.Hash) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
@@ -547,6 +730,7 @@ func (self *StateDB) Copy() *StateDB {
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte),
+		recordTrie:        self.recordTrie,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateObjectsDirty {
@@ -607,6 +791,9 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
+			if s.recordTrie {
+				stateObject.migrateRecords()
+			}
 			stateObject.updateRoot(s.db)
 			s.updateStateObject(stateObject)
 		}
@@ -678,6 +865,9 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 				}
 				stateObject.dirtyCode = false
 			}
+			if s.recordTrie {
+				stateObject.migrateRecords()
+			}
 			// Write any storage changes in the state object to its storage trie.
 			if err := stateObject.CommitTrie(s.db, dbw); err != nil {
 				return common.Hash{}, err
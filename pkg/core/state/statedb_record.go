@@ -20,15 +20,58 @@ package state
 import (
 	"AQChainRe/pkg/core/types"
 	"fmt"
+	"math/big"
 	"sort"
 	"sync"
 
 	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
 	"AQChainRe/pkg/log"
 	"AQChainRe/pkg/rlp"
 	"AQChainRe/pkg/trie"
 )
 
+// 记录存储树中保存的附加字段的键
+var (
+	batchRootKey   = common.BytesToHash([]byte("batchRoot"))   // 批量确权的默克尔根
+	royaltyKey     = common.BytesToHash([]byte("royalty"))     // 确权时由原始作者设定的版税比例 (万分比)
+	askPriceKey    = common.BytesToHash([]byte("askPrice"))    // 拥有者挂出的转让价格, 为零表示未出售
+	offerToKey     = common.BytesToHash([]byte("offerTo"))     // 待接受转移要约的接收者
+	offerPriceKey  = common.BytesToHash([]byte("offerPrice"))  // 接收者接受时需支付的价格
+	offerExpiryKey = common.BytesToHash([]byte("offerExpiry")) // 转移要约失效的区块高度
+
+	jointOwnersKey    = common.BytesToHash([]byte("jointOwners"))    // 共有人数量, 第 i 个共有人保存在 indexedKey("jointOwner", i)
+	jointThresholdKey = common.BytesToHash([]byte("jointThreshold")) // 共有记录提案生效所需的批准数
+	proposalsKey      = common.BytesToHash([]byte("proposals"))      // 未完成提案数量, 第 i 个提案 ID 保存在 indexedKey("proposal", i)
+
+	encryptedKey = common.BytesToHash([]byte("encrypted")) // 记录内容已加密, 授权和转移需携带内容密钥
+	expiryKey    = common.BytesToHash([]byte("expiry"))    // 记录有效的最后一个区块高度, 为零表示永久有效
+)
+
+// indexedKey derives the storage slot of the index-th element of a list field.
+func indexedKey(field string, index uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte(field), common.BigToHash(new(big.Int).SetUint64(index)).Bytes())
+}
+
+// takedownKey derives the storage slot of the takedown approval of a validator.
+func takedownKey(validator common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte("takedown"), validator[:])
+}
+
+// proposalKey derives the storage slot of a field of a proposal.
+func proposalKey(field string, id common.Hash, extra ...[]byte) common.Hash {
+	return crypto.Keccak256Hash(append([][]byte{[]byte(field), id[:]}, extra...)...)
+}
+
+// 记录状态
+const (
+	RecordNormal          uint8 = iota // 正常
+	RecordPendingTransfer              // 存在待接收者接受的转移要约
+
+	// 其余状态由共有人提案自由设定, 擦除状态取最大值以免冲突
+	RecordErased uint8 = 0xff // 已被墓碑交易擦除, 只保留来源信息
+)
+
 // empty returns whether the account is considered empty.
 func (s *stateObjectRecord) empty() bool {
 	return false
@@ -153,9 +196,10 @@ func (self *StateDBRecord) Preimages() map[common.Hash][]byte {
 }
 
 // Exist reports whether the given prev hash exists in the state.
-// Notably this also returns true for suicided accounts.
+// Records marked as expired are reported as non-existent.
 func (self *StateDBRecord) Exist(addr common.Hash) bool {
-	return self.getStateObject(addr) != nil
+	stateObject := self.getStateObject(addr)
+	return stateObject != nil && !stateObject.suicided
 }
 
 // Empty returns whether the state object is either non-existent
@@ -205,6 +249,97 @@ func (self *StateDBRecord) GetStatus(addr common.Hash) uint8 {
 	return 100
 }
 
+// GetBatchRoot returns the merkle root of the batch confirmation the record
+// was registered in, or the empty hash for individually confirmed records.
+func (self *StateDBRecord) GetBatchRoot(addr common.Hash) common.Hash {
+	return self.GetState(addr, batchRootKey)
+}
+
+// GetRoyalty returns the royalty rate in basis points paid to the origin of
+// the record on every priced transfer.
+func (self *StateDBRecord) GetRoyalty(addr common.Hash) uint64 {
+	return self.GetState(addr, royaltyKey).Big().Uint64()
+}
+
+// GetAskPrice returns the price the owner is selling the record for, zero if
+// the record is not for sale.
+func (self *StateDBRecord) GetAskPrice(addr common.Hash) *big.Int {
+	return self.GetState(addr, askPriceKey).Big()
+}
+
+// GetTransferOffer returns the recipient, price and expiry block of the
+// pending transfer offer of a record, if any.
+func (self *StateDBRecord) GetTransferOffer(addr common.Hash) (common.Address, *big.Int, uint64) {
+	to := common.BytesToAddress(self.GetState(addr, offerToKey).Bytes())
+	return to, self.GetState(addr, offerPriceKey).Big(), self.GetState(addr, offerExpiryKey).Big().Uint64()
+}
+
+// IsEncrypted reports whether the record was confirmed with an encrypted payload.
+func (self *StateDBRecord) IsEncrypted(addr common.Hash) bool {
+	return self.GetState(addr, encryptedKey) != (common.Hash{})
+}
+
+// GetExpiry returns the last block the record is valid in, zero for records
+// that never expire.
+func (self *StateDBRecord) GetExpiry(addr common.Hash) uint64 {
+	return self.GetState(addr, expiryKey).Big().Uint64()
+}
+
+// IsExpired reports whether the record is no longer valid in the given block.
+func (self *StateDBRecord) IsExpired(addr common.Hash, number uint64) bool {
+	expiry := self.GetExpiry(addr)
+	return expiry != 0 && number > expiry
+}
+
+// IsErased reports whether the record was erased by a tombstone.
+func (self *StateDBRecord) IsErased(addr common.Hash) bool {
+	return self.GetStatus(addr) == RecordErased
+}
+
+// HasApprovedTakedown reports whether a validator approved the takedown of a record.
+func (self *StateDBRecord) HasApprovedTakedown(addr common.Hash, validator common.Address) bool {
+	return self.GetState(addr, takedownKey(validator)) != (common.Hash{})
+}
+
+// GetJointOwners returns the co-owners and the approval threshold of a
+// jointly owned record, nil for records with a single owner.
+func (self *StateDBRecord) GetJointOwners(addr common.Hash) ([]common.Address, uint64) {
+	count := self.GetState(addr, jointOwnersKey).Big().Uint64()
+	if count == 0 {
+		return nil, 0
+	}
+	owners := make([]common.Address, count)
+	for i := range owners {
+		owners[i] = common.BytesToAddress(self.GetState(addr, indexedKey("jointOwner", uint64(i))).Bytes())
+	}
+	return owners, self.GetState(addr, jointThresholdKey).Big().Uint64()
+}
+
+// GetProposals returns the IDs of the proposals of a jointly owned record
+// still collecting approvals.
+func (self *StateDBRecord) GetProposals(addr common.Hash) []common.Hash {
+	count := self.GetState(addr, proposalsKey).Big().Uint64()
+	ids := make([]common.Hash, count)
+	for i := range ids {
+		ids[i] = self.GetState(addr, indexedKey("proposal", uint64(i)))
+	}
+	return ids
+}
+
+// GetProposal returns the packed action, the expiry block and the number of
+// approvals of a proposal.
+func (self *StateDBRecord) GetProposal(addr common.Hash, id common.Hash) (common.Hash, uint64, uint64) {
+	packed := self.GetState(addr, proposalKey("action", id))
+	expiry := self.GetState(addr, proposalKey("expiry", id)).Big().Uint64()
+	approvals := self.GetState(addr, proposalKey("approvals", id)).Big().Uint64()
+	return packed, expiry, approvals
+}
+
+// HasApproved reports whether a co-owner already approved a proposal.
+func (self *StateDBRecord) HasApproved(addr common.Hash, id common.Hash, owner common.Address) bool {
+	return self.GetState(addr, proposalKey("approved", id, owner[:])) != (common.Hash{})
+}
+
 // StorageTrie returns the storage trie of an prev.
 // The return value is a copy and is nil for non-existent accounts.
 func (self *StateDBRecord) StorageTrie(a common.Hash) Trie {
@@ -238,7 +373,7 @@ func (self *StateDBRecord) SetOrigin(addr common.Hash, account common.Address) {
 func (self *StateDBRecord) SetOwner(addr common.Hash, account common.Address) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
-		stateObject.SetOrigin(account)
+		stateObject.SetOwner(account)
 	}
 }
 
@@ -250,6 +385,137 @@ func (self *StateDBRecord) AddTxHash(addr common.Hash, tx common.Hash) {
 	}
 }
 
+func (self *StateDBRecord) SetBatchRoot(addr common.Hash, root common.Hash) {
+	self.SetState(addr, batchRootKey, root)
+}
+
+func (self *StateDBRecord) SetRoyalty(addr common.Hash, royalty uint64) {
+	self.SetState(addr, royaltyKey, common.BigToHash(new(big.Int).SetUint64(royalty)))
+}
+
+func (self *StateDBRecord) SetAskPrice(addr common.Hash, price *big.Int) {
+	self.SetState(addr, askPriceKey, common.BigToHash(price))
+}
+
+func (self *StateDBRecord) SetStatus(addr common.Hash, status uint8) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		stateObject.SetStatus(status)
+	}
+}
+
+// SetTransferOffer puts the record into the pending transfer state until the
+// recipient accepts it, the owner cancels it or the expiry block passes.
+func (self *StateDBRecord) SetTransferOffer(addr common.Hash, to common.Address, price *big.Int, expiry uint64) {
+	self.SetStatus(addr, RecordPendingTransfer)
+	self.SetState(addr, offerToKey, to.Hash())
+	self.SetState(addr, offerPriceKey, common.BigToHash(price))
+	self.SetState(addr, offerExpiryKey, common.BigToHash(new(big.Int).SetUint64(expiry)))
+}
+
+// ClearTransferOffer drops the pending transfer offer of a record.
+func (self *StateDBRecord) ClearTransferOffer(addr common.Hash) {
+	self.SetStatus(addr, RecordNormal)
+	self.SetState(addr, offerToKey, common.Hash{})
+	self.SetState(addr, offerPriceKey, common.Hash{})
+	self.SetState(addr, offerExpiryKey, common.Hash{})
+}
+
+func (self *StateDBRecord) SetEncrypted(addr common.Hash) {
+	self.SetState(addr, encryptedKey, common.BytesToHash([]byte{1}))
+}
+
+func (self *StateDBRecord) SetExpiry(addr common.Hash, expiry uint64) {
+	self.SetState(addr, expiryKey, common.BigToHash(new(big.Int).SetUint64(expiry)))
+}
+
+// ApproveTakedown records the approval of a validator to take the record down.
+func (self *StateDBRecord) ApproveTakedown(addr common.Hash, validator common.Address) {
+	self.SetState(addr, takedownKey(validator), common.BytesToHash([]byte{1}))
+}
+
+// Erase tombstones a record. Pending offers and the asking price are dropped,
+// while the origin, owner and transactions are kept as provenance.
+func (self *StateDBRecord) Erase(addr common.Hash) {
+	if self.GetStatus(addr) == RecordPendingTransfer {
+		self.ClearTransferOffer(addr)
+	}
+	self.SetAskPrice(addr, new(big.Int))
+	self.SetStatus(addr, RecordErased)
+}
+
+// Expire marks the record for deletion. The record is reported as
+// non-existent right away and removed from the trie in Finalise.
+func (self *StateDBRecord) Expire(addr common.Hash) bool {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return false
+	}
+	self.journal = append(self.journal, suicideChangeRecord{
+		record: &addr,
+		prev:   stateObject.suicided,
+	})
+	stateObject.markSuicided()
+	return true
+}
+
+// SetJointOwners replaces the co-owners and threshold of a record. An empty
+// owner set turns the record back into a singly owned one.
+func (self *StateDBRecord) SetJointOwners(addr common.Hash, owners []common.Address, threshold uint64) {
+	prev, _ := self.GetJointOwners(addr)
+	for i := len(owners); i < len(prev); i++ {
+		self.SetState(addr, indexedKey("jointOwner", uint64(i)), common.Hash{})
+	}
+	for i, owner := range owners {
+		self.SetState(addr, indexedKey("jointOwner", uint64(i)), owner.Hash())
+	}
+	self.SetState(addr, jointOwnersKey, common.BigToHash(new(big.Int).SetUint64(uint64(len(owners)))))
+	self.SetState(addr, jointThresholdKey, common.BigToHash(new(big.Int).SetUint64(threshold)))
+}
+
+// AddProposal opens a proposal on a jointly owned record until the expiry block.
+func (self *StateDBRecord) AddProposal(addr common.Hash, id common.Hash, packed common.Hash, expiry uint64) {
+	count := self.GetState(addr, proposalsKey).Big().Uint64()
+	self.SetState(addr, indexedKey("proposal", count), id)
+	self.SetState(addr, proposalsKey, common.BigToHash(new(big.Int).SetUint64(count+1)))
+	self.SetState(addr, proposalKey("action", id), packed)
+	self.SetState(addr, proposalKey("expiry", id), common.BigToHash(new(big.Int).SetUint64(expiry)))
+}
+
+// Approve records the approval of a co-owner and returns the number of
+// approvals the proposal has collected.
+func (self *StateDBRecord) Approve(addr common.Hash, id common.Hash, owner common.Address) uint64 {
+	_, _, approvals := self.GetProposal(addr, id)
+	approvals++
+	self.SetState(addr, proposalKey("approved", id, owner[:]), common.BytesToHash([]byte{1}))
+	self.SetState(addr, proposalKey("approvals", id), common.BigToHash(new(big.Int).SetUint64(approvals)))
+	return approvals
+}
+
+// RemoveProposal drops a proposal and the approvals collected for it.
+func (self *StateDBRecord) RemoveProposal(addr common.Hash, id common.Hash) {
+	owners, _ := self.GetJointOwners(addr)
+	for _, owner := range owners {
+		self.SetState(addr, proposalKey("approved", id, owner[:]), common.Hash{})
+	}
+	self.SetState(addr, proposalKey("action", id), common.Hash{})
+	self.SetState(addr, proposalKey("expiry", id), common.Hash{})
+	self.SetState(addr, proposalKey("approvals", id), common.Hash{})
+
+	// 用最后一个提案填补被删除的位置
+	ids := self.GetProposals(addr)
+	for i, other := range ids {
+		if other != id {
+			continue
+		}
+		last := uint64(len(ids) - 1)
+		self.SetState(addr, indexedKey("proposal", uint64(i)), ids[last])
+		self.SetState(addr, indexedKey("proposal", last), common.Hash{})
+		self.SetState(addr, proposalsKey, common.BigToHash(new(big.Int).SetUint64(last)))
+		break
+	}
+}
+
 func (self *StateDBRecord) SetState(addr common.Hash, key common.Hash, value common.Hash) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
@@ -333,7 +599,7 @@ func (self *StateDBRecord) setStateObject(object *stateObjectRecord) {
 // Retrieve a state object or create a new state object if nil
 func (self *StateDBRecord) GetOrNewStateObject(addr common.Hash) *stateObjectRecord {
 	stateObject := self.getStateObject(addr)
-	if stateObject == nil || stateObject.deleted {
+	if stateObject == nil || stateObject.deleted || stateObject.suicided {
 		stateObject, _ = self.createObject(addr)
 	}
 	return stateObject
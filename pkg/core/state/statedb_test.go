@@ -371,3 +371,101 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that owned records are moved from the account body into the record
+// trie once the fork is enabled, and that they can be paged in both layouts.
+func TestRecordTrieMigration(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+
+	addr := common.BytesToAddress([]byte{0x01})
+	records := make([]common.Hash, 5)
+	for i := range records {
+		records[i] = common.BytesToHash([]byte{byte(i + 1)})
+		state.AddRecords(addr, records[i])
+	}
+	// Adding a record twice must not duplicate it
+	state.AddRecords(addr, records[0])
+	if have := state.GetRecords(addr); len(have) != len(records) {
+		t.Fatalf("legacy records mismatch: have %d, want %d", len(have), len(records))
+	}
+	page, next := state.OwnedRecords(addr, nil, 3)
+	if len(page) != 3 || next == nil {
+		t.Fatalf("legacy first page mismatch: have %d records, next %x", len(page), next)
+	}
+	if page, next = state.OwnedRecords(addr, next, 3); len(page) != 2 || next != nil {
+		t.Fatalf("legacy second page mismatch: have %d records, next %x", len(page), next)
+	}
+	root, _ := state.CommitTo(db, false)
+
+	// Reopen the state with the fork enabled and touch the account to migrate it
+	state, _ = New(root, sdb)
+	state.EnableRecordTrie()
+	state.RemoveRecords(addr, records[4])
+	state.Finalise(false)
+
+	if have := state.GetRecords(addr); len(have) != 0 {
+		t.Fatalf("records left in account body: %v", have)
+	}
+	for i, record := range records {
+		if want := i != 4; state.HasRecord(addr, record) != want {
+			t.Errorf("record %d: ownership mismatch, want %v", i, want)
+		}
+	}
+	root, _ = state.CommitTo(db, false)
+	state, _ = New(root, sdb)
+
+	seen := make(map[common.Hash]bool)
+	var cursor []byte
+	for {
+		page, next := state.OwnedRecords(addr, cursor, 3)
+		for _, record := range page {
+			if seen[record] {
+				t.Fatalf("record %x returned twice", record)
+			}
+			seen[record] = true
+		}
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 4 || seen[records[4]] {
+		t.Fatalf("paged records mismatch: %v", seen)
+	}
+}
+
+// Tests that the transfer offers of an account are indexed by position, so that
+// removing an offer keeps the index dense and adding one twice is a no-op.
+func TestPendingOfferIndex(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+	state.EnableRecordTrie()
+
+	addr := common.BytesToAddress([]byte{0x01})
+	offers := []common.Hash{{0x01}, {0x02}, {0x03}}
+	for _, offer := range offers {
+		state.AddPendingOffer(addr, offer)
+	}
+	state.AddPendingOffer(addr, offers[1])
+	if have := state.PendingOffers(addr); fmt.Sprint(have) != fmt.Sprint(offers) {
+		t.Fatalf("offers mismatch: have %x, want %x", have, offers)
+	}
+	// Removing the first offer moves the last one into its position
+	state.RemovePendingOffer(addr, offers[0])
+	state.RemovePendingOffer(addr, common.Hash{0x04})
+	want := []common.Hash{offers[2], offers[1]}
+	if have := state.PendingOffers(addr); fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Fatalf("offers mismatch after removal: have %x, want %x", have, want)
+	}
+	root, _ := state.CommitTo(db, false)
+	state, _ = New(root, sdb)
+
+	state.RemovePendingOffer(addr, offers[1])
+	state.RemovePendingOffer(addr, offers[2])
+	if have := state.PendingOffers(addr); len(have) != 0 {
+		t.Fatalf("offers left after removing all: %x", have)
+	}
+}
@@ -38,3 +38,19 @@ func NewStateSync(root common.Hash, database trie.DatabaseReader) *trie.TrieSync
 	syncer = trie.NewTrieSync(root, database, callback)
 	return syncer
 }
+
+// NewRecordSync create a new record trie download scheduler, following the
+// storage trie of every record.
+func NewRecordSync(root common.Hash, database trie.DatabaseReader) *trie.TrieSync {
+	var syncer *trie.TrieSync
+	callback := func(leaf []byte, parent common.Hash) error {
+		var obj Record
+		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
+			return err
+		}
+		syncer.AddSubTrie(obj.Root, 64, parent, nil)
+		return nil
+	}
+	syncer = trie.NewTrieSync(root, database, callback)
+	return syncer
+}
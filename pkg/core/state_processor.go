@@ -63,6 +63,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, sta
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	if p.config.IsRecordTrie(block.Number()) {
+		statedb.EnableRecordTrie()
+	}
 	// Set block poc context
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
@@ -99,50 +102,201 @@ func ApplyTransaction(config *params.ChainConfig, pocContext *types.PocContext,
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTxType(config, tx.Type(), header.Number); err != nil {
+		return nil, err
+	}
+	if err := checkTxEnvelope(config, tx, header.Number, header.Time); err != nil {
+		return nil, err
+	}
+	if err := checkMultisig(statedb, types.MakeSigner(config, header.Number), tx); err != nil {
+		return nil, err
+	}
+	receipt, err := applyTransactionMessage(config, pocContext, statedb, statedbRecord, header, tx, msg)
+	if err != nil {
+		return nil, err
+	}
 
-	var failed bool
-
-	// 没有了evm 直接区分三种交易类型进行分别处理 转账 共识 数据记录
-	if msg.Type() == types.Binary {
-		_, failed, err = ApplyMessage(msg, statedb)
-		if err != nil {
-			return nil, err
-		}
+	// Update the state with pending changes, storing the intermediate roots in the
+	// receipt based on the eip phase
+	if config.IsByzantium(header.Number) {
+		statedb.Finalise(true)
+		statedbRecord.Finalise(true)
+	} else {
+		receipt.PostState = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+		receipt.PostStateRecord = statedbRecord.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
 	}
+	return receipt, nil
+}
 
-	if msg.Type() == types.ConfirmationData || msg.Type() == types.AuthorizationData || msg.Type() == types.TransferData {
-		failed, err = ApplyDataMessage(tx.Hash(), msg, statedb, statedbRecord)
-		if err != nil {
-			return nil, err
-		}
+// SimulateTransaction applies an unsigned transaction sent by from to the
+// given states like ApplyTransaction would, without checking its signatures.
+// The states are modified, callers pass copies they throw away afterwards.
+// Copies share their tries with the original, so the changes are never
+// written to the tries and the receipt holds no intermediate roots. A
+// multisig transaction is checked against the account and the signatures it
+// carries, but may still lack some to reach the threshold, which callers
+// find out with MultisigQuorum.
+func SimulateTransaction(config *params.ChainConfig, pocContext *types.PocContext, statedb *state.StateDB, statedbRecord *state.StateDBRecord, header *types.Header, tx *types.Transaction, from common.Address) (*types.Receipt, error) {
+	if err := tx.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkTxType(config, tx.Type(), header.Number); err != nil {
+		return nil, err
 	}
+	if err := checkTxEnvelope(config, tx, header.Number, header.Time); err != nil {
+		return nil, err
+	}
+	if _, err := MultisigQuorum(statedb, types.MakeSigner(config, header.Number), tx); err != nil {
+		return nil, err
+	}
+	if config.IsRecordTrie(header.Number) {
+		statedb.EnableRecordTrie()
+	}
+	statedb.Prepare(tx.Hash(), header.Hash(), 0)
+	statedbRecord.Prepare(tx.Hash(), header.Hash(), 0)
+	return applyTransactionMessage(config, pocContext, statedb, statedbRecord, header, tx, tx.AsSimulatedMessage(from))
+}
+
+// applyTransactionMessage applies the message of a transaction whose sender
+// has been established and returns its receipt, without intermediate roots.
+func applyTransactionMessage(config *params.ChainConfig, pocContext *types.PocContext, statedb *state.StateDB, statedbRecord *state.StateDBRecord, header *types.Header, tx *types.Transaction, msg types.Message) (*types.Receipt, error) {
+	var (
+		err     error
+		failed  bool
+		results []*types.OperationResult
+	)
 
+	// 手续费按费用表计算, 支付给出块的验证者
+	fee := tx.Fee(config, header.Number)
+
+	// 执行失败的交易只保留手续费和 nonce, 其他改动从快照中撤销. 共识上下文的
+	// 快照是整体复制, 只有候选交易会修改它
+	var (
+		snap       = statedb.Snapshot()
+		snapRecord = statedbRecord.Snapshot()
+		pocSnap    *types.PocContext
+	)
 	if msg.Type() == types.LoginCandidate || msg.Type() == types.LogoutCandidate {
-		err = applyPocMessage(pocContext, msg)
-		if err != nil {
+		pocSnap = pocContext.Snapshot()
+	}
+
+	// 没有了evm 直接区分三种交易类型进行分别处理 转账 共识 数据记录
+	switch {
+	case msg.Type() == types.Binary:
+		_, failed, err = ApplyMessage(msg, statedb, header.Validator, fee)
+	case isDataMessage(msg.Type()):
+		failed, err = ApplyDataMessage(config, tx.Hash(), header.Number, msg, statedb, statedbRecord, header.Validator, fee)
+	case msg.Type() == types.RecordTombstone:
+		failed, err = applyTombstoneMessage(tx.Hash(), header.Number, msg, statedb, statedbRecord, pocContext, header.Validator, fee)
+	case msg.Type() == types.MultiOperationData:
+		failed, results, err = applyMultiOperationMessage(config, tx.Hash(), header.Number, msg, statedb, statedbRecord, pocContext, header.Validator, fee)
+	case msg.Type() == types.MultisigCreation:
+		failed, err = applyMultisigCreation(msg, statedb, header.Validator, fee)
+	case msg.Type() == types.LoginCandidate || msg.Type() == types.LogoutCandidate:
+		failed, err = applyPocMessage(config, header.Number, statedb, pocContext, msg, header.Validator, fee)
+	}
+	// 未能支付手续费或 nonce 不对的交易使区块无效. 已支付手续费但执行失败的交易
+	// 从分叉起以失败状态打包, 收据中记下失败原因, 之前带错误的失败使交易无效
+	if err != nil && (!failed || !config.IsFailedTx(header.Number) && err != ErrRecordExpired) {
+		return nil, err
+	}
+	var failure types.FailureCode
+	if failed {
+		failure = failureCode(err)
+
+		nonce := statedb.GetNonce(msg.From())
+		statedb.RevertToSnapshot(snap)
+		statedbRecord.RevertToSnapshot(snapRecord)
+		if pocSnap != nil {
+			pocContext.RevertToSnapShot(pocSnap)
+		}
+		if err := payFee(statedb, msg.Payer(), header.Validator, fee); err != nil {
 			return nil, err
 		}
-	}
+		statedb.SetNonce(msg.From(), nonce)
 
-	// Update the state with pending changes
-	var root []byte
-	var rootRecord []byte
-	if config.IsByzantium(header.Number) {
-		statedb.Finalise(true)
-		statedbRecord.Finalise(true)
-	} else {
-		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
-		rootRecord = statedbRecord.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+		// 过期记录的清理不随失败撤销, 否则后续交易会一再遇到同一条过期记录
+		expireRecords(config, header.Number, tx, statedb, statedbRecord)
+	}
+	// 多操作交易的失败原因记在失败的操作上
+	for _, result := range results {
+		if result.Status == types.ReceiptStatusFailed {
+			failure = result.Failure
+		}
 	}
 
-	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
-	// based on the eip phase, we're passing wether the root touch-delete accounts.
-	receipt := types.NewReceipt(root, rootRecord, failed)
+	// Create a new receipt for the transaction
+	receipt := types.NewReceipt(nil, nil, failed)
 	receipt.TxHash = tx.Hash()
+	receipt.Results = results
+	receipt.Failure = failure
 
-	// Set the receipt logs and create a bloom for filtering
-	receipt.Logs = statedb.GetLogs(tx.Hash())
+	// Set the receipt logs and create a bloom for filtering. 记录和候选交易的日志
+	// 从记录市场分叉起才计入收据, 之前的收据没有日志
+	if config.IsRecordMarket(header.Number) {
+		receipt.Logs = statedb.GetLogs(tx.Hash())
+	}
+	for _, l := range receipt.Logs {
+		l.BlockNumber = header.Number.Uint64()
+	}
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 
-	return receipt, err
+	return receipt, nil
+}
+
+// checkTxEnvelope ensures the envelope extensions of tx are active in a block
+// with the given number and that the block lies within the validity window of
+// tx, if it has one.
+func checkTxEnvelope(config *params.ChainConfig, tx *types.Transaction, number, time *big.Int) error {
+	if tx.Extended() && !config.IsTxEnvelope(number) {
+		return ErrTxEnvelopeNotActive
+	}
+	w := tx.Window()
+	if w == nil {
+		return nil
+	}
+	if w.NotYetValid(number, time) {
+		return ErrTxNotYetValid
+	}
+	if w.Expired(number, time) {
+		return ErrTxExpired
+	}
+	return nil
+}
+
+// checkTxType ensures transactions of the given type may be included in a
+// block with the given number. Types added by a fork were not known before it
+// and applied as no-ops, so they must not execute on replayed blocks.
+func checkTxType(config *params.ChainConfig, txType types.TxType, number *big.Int) error {
+	var active bool
+	switch txType {
+	case types.BatchConfirmationData:
+		active = config.IsBatchConfirmation(number)
+	case types.RecordTombstone:
+		active = config.IsRecordTombstone(number)
+	case types.MultiOperationData, types.MultisigCreation:
+		active = config.IsTxEnvelope(number)
+	case types.TransferOffer, types.TransferAccept, types.TransferCancel,
+		types.JointOwnershipData, types.JointApprovalData, types.EncryptedConfirmationData,
+		types.RecordRenewal:
+		active = config.IsRecordMarket(number)
+	default:
+		active = true
+	}
+	if !active {
+		return ErrTxTypeNotActive
+	}
+	return nil
+}
+
+// isDataMessage reports whether a transaction type operates on data records.
+func isDataMessage(txType types.TxType) bool {
+	switch txType {
+	case types.ConfirmationData, types.AuthorizationData, types.TransferData, types.BatchConfirmationData,
+		types.TransferOffer, types.TransferAccept, types.TransferCancel,
+		types.JointOwnershipData, types.JointApprovalData, types.EncryptedConfirmationData,
+		types.RecordRenewal:
+		return true
+	}
+	return false
 }
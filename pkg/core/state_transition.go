@@ -20,7 +20,9 @@ import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/core/state"
 	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/crypto"
 	"AQChainRe/pkg/log"
+	"AQChainRe/pkg/params"
 	"AQChainRe/pkg/rlp"
 	"errors"
 	"fmt"
@@ -31,6 +33,21 @@ var (
 	Big0                         = big.NewInt(0)
 	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for gas")
 	ErrInsufficientBalance       = errors.New("insufficient balance for transfer")
+
+	// RecordSoldTopic is the log topic of a priced record transfer. The remaining
+	// topics are the record key, the seller and the buyer, the data holds the
+	// price followed by the royalty paid to the origin.
+	RecordSoldTopic = crypto.Keccak256Hash([]byte("RecordSold(bytes32,address,address,uint256,uint256)"))
+
+	// 数据记录和 poc 交易的日志主题, 其余主题依次为记录键和相关账户, 日志地址为交易发送者
+	RecordConfirmedTopic      = crypto.Keccak256Hash([]byte("RecordConfirmed(bytes32,address)"))              // 记录键, 原始作者
+	RecordTransferredTopic    = crypto.Keccak256Hash([]byte("RecordTransferred(bytes32,address,address)"))    // 记录键, 原拥有者, 新拥有者
+	AuthorizationGrantedTopic = crypto.Keccak256Hash([]byte("AuthorizationGranted(bytes32,address,address)")) // 记录键, 拥有者, 被授权者
+	RecordStatusChangedTopic  = crypto.Keccak256Hash([]byte("RecordStatusChanged(bytes32,address,uint8)"))    // 记录键, 拥有者, 新状态
+	CandidateLoginTopic       = crypto.Keccak256Hash([]byte("CandidateLogin(address)"))                       // 候选人
+	CandidateLogoutTopic      = crypto.Keccak256Hash([]byte("CandidateLogout(address)"))                      // 候选人
+	// 多签账户, 创建者; 日志数据为门限
+	MultisigCreatedTopic = crypto.Keccak256Hash([]byte("MultisigCreated(address,address,uint256)"))
 )
 
 /*
@@ -40,7 +57,7 @@ A state transition is a change made when a transaction is applied to the current
 The state transitioning model does all all the necessary work to work out a valid new state root.
 
 1) Nonce handling
-2) Pre pay gas
+2) Pay the fee to the validator
 3) Create a new state object if the recipient is \0*32
 4) Value transfer
 == If contract creation ==
@@ -58,11 +75,15 @@ type StateTransition struct {
 	value      *big.Int
 	data       []byte
 	statedb    *state.StateDB
+	fee        *big.Int       // Fee of the message under the fee schedule
+	validator  common.Address // Validator of the block the fee is paid to
 }
 
 // Message represents a message sent to a contract.
 type Message interface {
 	From() common.Address
+	// Payer is the account paying the fee, the sponsor of a sponsored message
+	Payer() common.Address
 	//FromFrontier() (common.Address, error)
 	To() *common.Address
 
@@ -89,14 +110,16 @@ func NewStateTransition(msg Message, statedb *state.StateDB) *StateTransition {
 }
 
 // ApplyMessage computes the new state by applying the given message
-// against the old state within the environment.
+// against the old state within the environment. The sender pays fee to the
+// validator of the block.
 //
 // ApplyMessage returns the bytes returned by any EVM execution (if it took place),
 // the gas used (which includes gas refunds) and an error if it failed. An error always
 // indicates a core error meaning that the message would always fail for that particular
 // state and would never be accepted within a block.
-func ApplyMessage(msg Message, statedb *state.StateDB) ([]byte, bool, error) {
+func ApplyMessage(msg Message, statedb *state.StateDB, validator common.Address, fee *big.Int) ([]byte, bool, error) {
 	st := NewStateTransition(msg, statedb)
+	st.validator, st.fee = validator, fee
 	return st.TransitionDb()
 }
 
@@ -108,6 +131,16 @@ func (st *StateTransition) from() common.Address {
 	return f
 }
 
+// payer returns the account paying the fee. The nonce of the sender is still
+// the one used, so a sponsor cannot replay the message.
+func (st *StateTransition) payer() common.Address {
+	p := st.msg.Payer()
+	if !st.statedb.Exist(p) {
+		st.statedb.CreateAccount(p)
+	}
+	return p
+}
+
 func (st *StateTransition) to() common.Address {
 	if st.msg == nil {
 		return common.Address{}
@@ -152,6 +185,10 @@ func (st *StateTransition) TransitionDb() (ret []byte, failed bool, err error) {
 	value := msg.Value()
 	stateDB := st.statedb
 
+	// 支付手续费
+	if err = payFee(stateDB, st.payer(), st.validator, st.fee); err != nil {
+		return nil, false, err
+	}
 	// 增加账户的交易数
 	st.statedb.SetNonce(sender, st.statedb.GetNonce(sender)+1)
 
@@ -220,37 +257,94 @@ func Transfer(db *state.StateDB, sender, recipient common.Address, amount *big.I
 	db.AddBalance(recipient, amount)
 }
 
-func applyPocMessage(pocContext *types.PocContext, msg types.Message) error {
+func applyPocMessage(config *params.ChainConfig, number *big.Int, statedb *state.StateDB, pocContext *types.PocContext, msg types.Message, validator common.Address, fee *big.Int) (bool, error) {
+	// 分叉前候选交易不检查也不消耗 nonce, 重复的登录和登出不算失败
+	pocNonce := config.IsPocNonce(number)
+
+	st := NewStateTransition(msg, statedb)
+	if pocNonce {
+		if err := st.preCheck(); err != nil {
+			return false, err
+		}
+	}
+	sender := st.from()
+	if err := payFee(statedb, st.payer(), validator, fee); err != nil {
+		return false, err
+	}
+	if pocNonce {
+		// 增加账户的交易数, 候选交易不能被重复打包
+		statedb.SetNonce(sender, statedb.GetNonce(sender)+1)
+	}
+
 	switch msg.Type() {
 	case types.LoginCandidate:
+		if pocNonce && pocContext.IsCandidate(msg.From()) {
+			return true, ErrAlreadyCandidate
+		}
 		pocContext.BecomeCandidate(msg.From())
+		addRecordLog(statedb, msg.From(), CandidateLoginTopic, msg.From().Hash())
 	case types.LogoutCandidate:
+		if pocNonce && !pocContext.IsCandidate(msg.From()) {
+			return true, ErrNotCandidate
+		}
 		pocContext.KickoutCandidate(msg.From())
+		addRecordLog(statedb, msg.From(), CandidateLogoutTopic, msg.From().Hash())
 	default:
-		return types.ErrInvalidType
+		return false, types.ErrInvalidType
 	}
-	return nil
+	return false, nil
 }
 
-func ApplyDataMessage(txHash common.Hash, msg Message, statedb *state.StateDB, statedbRecord *state.StateDBRecord) (failed bool, err error) {
+func ApplyDataMessage(config *params.ChainConfig, txHash common.Hash, number *big.Int, msg Message, statedb *state.StateDB, statedbRecord *state.StateDBRecord, validator common.Address, fee *big.Int) (failed bool, err error) {
 	st := NewStateTransition(msg, statedb)
+	st.validator, st.fee = validator, fee
 
 	if err = st.preCheck(); err != nil {
 		return
 	}
+	if err = payFee(statedb, st.payer(), st.validator, st.fee); err != nil {
+		return
+	}
 
 	msg = st.msg
 	sender := st.from()
 	b, _ := rlp.EncodeToBytes(msg.Data())
 	hash := common.BytesToHash(b)
+	// 加密记录的授权和转移交易以内容密钥授予数据指定记录
+	grant := recordKeyGrant(msg.Type(), msg.Data(), hash, statedbRecord)
+	if grant != nil {
+		hash = grant.Record
+	}
 	// 增加账户的交易数
 	st.statedb.SetNonce(sender, st.statedb.GetNonce(sender)+1)
 
+	// 已过期的记录视为不存在. 操作过期记录的交易以失败状态打包, 使清理得以保留
+	switch msg.Type() {
+	case types.AuthorizationData, types.TransferData, types.TransferOffer, types.TransferAccept,
+		types.TransferCancel, types.RecordRenewal:
+		if expireRecord(statedb, statedbRecord, hash, number) {
+			return true, ErrRecordExpired
+		}
+		// 已擦除的记录不能再授权, 转移或续期
+		if statedbRecord.IsErased(hash) {
+			return true, ErrRecordErased
+		}
+	}
+
 	switch msg.Type() {
 	case types.ConfirmationData:
+		record, err := decodeConfirmation(config, number, msg.Data())
+		if err != nil {
+			return true, err
+		}
+		if record.Expiry != 0 && record.Expiry < number.Uint64() {
+			return true, ErrExpiryPassed
+		}
+		hash = record.Key()
+		expireRecord(statedb, statedbRecord, hash, number)
 		// 检查数据唯一性
 		if statedbRecord.Exist(hash) {
-			return true, errors.New("")
+			return true, ErrRecordExists
 		}
 
 		// stateRecord 生成记录
@@ -258,40 +352,280 @@ func ApplyDataMessage(txHash common.Hash, msg Message, statedb *state.StateDB, s
 		obj.SetOrigin(sender)
 		obj.SetOwner(sender)
 		obj.SetTxs([]common.Hash{txHash})
+		// 原始作者设定的版税比例, 之后每次出售都按此比例支付给原始作者
+		if record.Royalty > 0 {
+			statedbRecord.SetRoyalty(hash, record.Royalty)
+		}
+		if record.Expiry != 0 {
+			statedbRecord.SetExpiry(hash, record.Expiry)
+		}
 
 		// 添加账户的记录
 		statedb.AddRecords(sender, hash)
+		logRecordConfirmed(statedb, sender, hash)
 		// 贡献值计算 先直接加2e+18
 		statedb.AddContribution(sender, big.NewInt(2e+18))
 		log.Info("ConfirmationData Add 1e+18 Contribution")
 		log.Info(fmt.Sprintf("Transition Sender %s", sender))
 
+	case types.BatchConfirmationData:
+		batch, err := types.DecodeBatchConfirmation(msg.Data())
+		if err != nil {
+			return true, err
+		}
+		// 批量根和每个叶子都必须是新记录
+		expireRecord(statedb, statedbRecord, batch.Root, number)
+		for _, leaf := range batch.Leaves {
+			expireRecord(statedb, statedbRecord, leaf, number)
+		}
+		if statedbRecord.Exist(batch.Root) {
+			return true, ErrRecordExists
+		}
+		for _, leaf := range batch.Leaves {
+			if statedbRecord.Exist(leaf) {
+				return true, ErrRecordExists
+			}
+		}
+
+		// 批量根本身作为一条记录, 便于通过根找到确权交易
+		obj := statedbRecord.GetOrNewStateObject(batch.Root)
+		obj.SetOrigin(sender)
+		obj.SetOwner(sender)
+		obj.SetTxs([]common.Hash{txHash})
+		statedb.AddRecords(sender, batch.Root)
+		logRecordConfirmed(statedb, sender, batch.Root)
+
+		// 每个叶子成为发送者拥有的独立记录, 并记下所属的默克尔根
+		for _, leaf := range batch.Leaves {
+			obj := statedbRecord.GetOrNewStateObject(leaf)
+			obj.SetOrigin(sender)
+			obj.SetOwner(sender)
+			obj.SetTxs([]common.Hash{txHash})
+			statedbRecord.SetBatchRoot(leaf, batch.Root)
+			statedb.AddRecords(sender, leaf)
+			logRecordConfirmed(statedb, sender, leaf)
+		}
+
+		// 贡献值按叶子计算, 超过上限的叶子不再计入
+		credited := uint64(len(batch.Leaves))
+		if credited > params.BatchContributionLeafCap {
+			credited = params.BatchContributionLeafCap
+		}
+		contribution := new(big.Int).Mul(big.NewInt(2e+18), new(big.Int).SetUint64(credited))
+		statedb.AddContribution(sender, contribution)
+		log.Info("BatchConfirmationData Add Contribution", "leaves", len(batch.Leaves), "credited", credited)
+		log.Info(fmt.Sprintf("Transition Sender %s", sender))
+
+	case types.EncryptedConfirmationData:
+		record, err := types.DecodeEncryptedRecord(msg.Data())
+		if err != nil {
+			return true, err
+		}
+		hash = record.Key()
+		expireRecord(statedb, statedbRecord, hash, number)
+		if statedbRecord.Exist(hash) {
+			return true, ErrRecordExists
+		}
+
+		// 密文保存在交易数据中, 记录只标记为加密
+		obj := statedbRecord.GetOrNewStateObject(hash)
+		obj.SetOrigin(sender)
+		obj.SetOwner(sender)
+		obj.SetTxs([]common.Hash{txHash})
+		statedbRecord.SetEncrypted(hash)
+		if record.Royalty > 0 {
+			statedbRecord.SetRoyalty(hash, record.Royalty)
+		}
+
+		statedb.AddRecords(sender, hash)
+		logRecordConfirmed(statedb, sender, hash)
+		statedb.AddContribution(sender, big.NewInt(2e+18))
+		log.Info("EncryptedConfirmationData Add 2e+18 Contribution")
+		log.Info(fmt.Sprintf("Transition Sender %s", sender))
+
 	case types.AuthorizationData:
+		// 记录市场分叉前明文记录的授权不做检查也不改变状态
+		if grant == nil && !config.IsRecordMarket(number) {
+			break
+		}
+		if !statedbRecord.Exist(hash) {
+			return true, ErrUnknownRecord
+		}
+		if msg.To() == nil || statedbRecord.GetOwner(hash) != sender {
+			return true, ErrNotRecordOwner
+		}
+		// 明文记录的授权不改变状态, 加密记录的授权由拥有者为接收者携带内容密钥
+		if grant != nil {
+			if len(grant.WrappedKey) == 0 {
+				return true, types.ErrEmptyWrappedKey
+			}
+			statedbRecord.AddTxHash(hash, txHash)
+		}
+		logAuthorizationGranted(statedb, sender, hash, st.to())
+
 	case types.TransferData:
-		// 检查是否可以进行转移
-		if statedbRecord.GetOwner(hash).Hash() != sender.Hash() {
-			return true, errors.New("")
+		if !config.IsRecordMarket(number) {
+			return applyLegacyTransfer(config, number, txHash, hash, sender, st.to(), statedb, statedbRecord)
+		}
+		if !statedbRecord.Exist(hash) {
+			return true, ErrUnknownRecord
+		}
+		expireTransferOffer(statedb, statedbRecord, hash, number)
+
+		// 加密记录只能由拥有者赠与并携带内容密钥, 出售需通过转移要约
+		if statedbRecord.IsEncrypted(hash) {
+			if grant == nil || len(grant.WrappedKey) == 0 {
+				return true, types.ErrEmptyWrappedKey
+			}
+			if statedbRecord.GetOwner(hash) != sender || st.to() == sender {
+				return true, ErrNotRecordOwner
+			}
 		}
 
 		// 状态
 		if statedbRecord.GetStatus(hash) != 0 {
-			return true, errors.New("")
+			return true, ErrRecordNotTransferable
 		}
 
-		// 转移拥有者
-		statedbRecord.SetOwner(hash, st.to())
+		owner := statedbRecord.GetOwner(hash)
+		recipient := st.to()
+		price := msg.Value()
+		switch {
+		case owner == sender && recipient == sender:
+			// 拥有者转给自己表示挂出转让价格, 金额为零则撤销出售
+			statedbRecord.SetAskPrice(hash, price)
+			statedbRecord.AddTxHash(hash, txHash)
+			return false, nil
+
+		case owner == sender:
+			// 拥有者直接赠与, 不能附带金额
+			if price.Sign() != 0 {
+				return true, ErrAskPriceMismatch
+			}
+			statedbRecord.SetOwner(hash, recipient)
+
+		default:
+			// 买家购买挂出的记录, 接收者必须是当前拥有者且金额等于挂出价格
+			ask := statedbRecord.GetAskPrice(hash)
+			if ask.Sign() == 0 || recipient != owner {
+				return true, ErrNotRecordOwner
+			}
+			if price.Cmp(ask) != 0 {
+				return true, ErrAskPriceMismatch
+			}
+			if !CanTransfer(statedb, sender, price) {
+				return true, ErrInsufficientBalance
+			}
+			payRecordPrice(statedb, statedbRecord, hash, sender, owner, price)
+			statedbRecord.SetOwner(hash, sender)
+			recipient = sender
+		}
+		statedbRecord.SetAskPrice(hash, new(big.Int))
 
 		// 添加交易记录
 		statedbRecord.AddTxHash(hash, txHash)
 
 		// 为账户添加删除记录
-		statedb.AddRecords(st.to(), txHash)
-		statedb.RemoveRecords(sender, txHash)
+		moveAccountRecord(config, number, statedb, txHash, hash, owner, recipient)
+		logRecordTransferred(statedb, sender, hash, owner, recipient)
 		// 贡献值
 		statedb.AddContribution(sender, big.NewInt(1e+18))
 		log.Info("TransferData Add 1e+18 Contribution")
 		log.Info(fmt.Sprintf("Transition Sender %s", sender))
 
+	case types.TransferOffer:
+		if !statedbRecord.Exist(hash) {
+			return true, ErrUnknownRecord
+		}
+		expireTransferOffer(statedb, statedbRecord, hash, number)
+
+		if statedbRecord.IsEncrypted(hash) && (grant == nil || len(grant.WrappedKey) == 0) {
+			return true, types.ErrEmptyWrappedKey
+		}
+
+		// 只有拥有者可以对正常状态的记录发起转移要约
+		recipient := st.to()
+		if statedbRecord.GetOwner(hash) != sender || recipient == sender {
+			return true, ErrNotRecordOwner
+		}
+		if statedbRecord.GetStatus(hash) != state.RecordNormal {
+			return true, ErrRecordNotTransferable
+		}
+		expiry := number.Uint64() + params.TransferOfferDuration
+		statedbRecord.SetTransferOffer(hash, recipient, msg.Value(), expiry)
+		statedbRecord.SetAskPrice(hash, new(big.Int))
+		statedbRecord.AddTxHash(hash, txHash)
+		statedb.AddPendingOffer(recipient, hash)
+		logRecordStatus(statedb, statedbRecord, sender, hash)
+
+	case types.TransferAccept:
+		if !statedbRecord.Exist(hash) {
+			return true, ErrUnknownRecord
+		}
+		expireTransferOffer(statedb, statedbRecord, hash, number)
+
+		// 只有要约的接收者可以接受, 并支付要约中的价格
+		if statedbRecord.GetStatus(hash) != state.RecordPendingTransfer {
+			return true, ErrNoTransferOffer
+		}
+		owner := statedbRecord.GetOwner(hash)
+		to, price, _ := statedbRecord.GetTransferOffer(hash)
+		if to != sender {
+			return true, ErrNoTransferOffer
+		}
+		if !CanTransfer(statedb, sender, price) {
+			return true, ErrInsufficientBalance
+		}
+		if price.Sign() > 0 {
+			payRecordPrice(statedb, statedbRecord, hash, sender, owner, price)
+		}
+		statedbRecord.ClearTransferOffer(hash)
+		statedbRecord.SetOwner(hash, sender)
+		statedbRecord.AddTxHash(hash, txHash)
+		statedb.RemovePendingOffer(sender, hash)
+		logRecordStatus(statedb, statedbRecord, sender, hash)
+
+		moveAccountRecord(config, number, statedb, txHash, hash, owner, sender)
+		logRecordTransferred(statedb, sender, hash, owner, sender)
+		statedb.AddContribution(owner, big.NewInt(1e+18))
+		log.Info("TransferAccept Add 1e+18 Contribution")
+		log.Info(fmt.Sprintf("Transition Owner %s", owner))
+
+	case types.TransferCancel:
+		if !statedbRecord.Exist(hash) {
+			return true, ErrUnknownRecord
+		}
+		expireTransferOffer(statedb, statedbRecord, hash, number)
+
+		// 只有拥有者可以撤销尚未失效的要约
+		if statedbRecord.GetStatus(hash) != state.RecordPendingTransfer {
+			return true, ErrNoTransferOffer
+		}
+		if statedbRecord.GetOwner(hash) != sender {
+			return true, ErrNotRecordOwner
+		}
+		to, _, _ := statedbRecord.GetTransferOffer(hash)
+		statedbRecord.ClearTransferOffer(hash)
+		statedbRecord.AddTxHash(hash, txHash)
+		statedb.RemovePendingOffer(to, hash)
+		logRecordStatus(statedb, statedbRecord, sender, hash)
+
+	case types.JointOwnershipData:
+		joint, err := types.DecodeJointOwnership(msg.Data())
+		if err != nil {
+			return true, err
+		}
+		return applyJointOwnership(config, txHash, number, sender, joint, statedb, statedbRecord)
+
+	case types.JointApprovalData:
+		proposal, err := types.DecodeRecordProposal(msg.Data())
+		if err != nil {
+			return true, err
+		}
+		return applyJointApproval(config, txHash, number, sender, proposal, statedb, statedbRecord)
+
+	case types.RecordRenewal:
+		return renewRecord(txHash, sender, hash, statedb, statedbRecord)
 	}
 
 	return false, err
@@ -0,0 +1,1404 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// recordTester applies data transactions against in-memory states through
+// ApplyTransaction, dropping the ones that are invalid like the miner does.
+type recordTester struct {
+	statedb       *state.StateDB
+	statedbRecord *state.StateDBRecord
+	pocContext    *types.PocContext
+	config        *params.ChainConfig
+	validator     common.Address // validator the fees are paid to
+	signer        types.Signer
+	nonces        map[common.Address]uint64
+	number        *big.Int
+	logs          []*types.Log      // logs of the last applied transaction
+	failure       types.FailureCode // failure reason of the last applied transaction
+}
+
+func newRecordTester() *recordTester {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedbRecord, _ := state.NewRecord(common.Hash{}, state.NewDatabase(db))
+	pocContext, _ := types.NewPocContext(db)
+	config := *params.TestChainConfig
+	config.RecordTrieBlock = big.NewInt(0)
+	config.RecordMarketBlock = big.NewInt(0)
+	config.BatchConfirmationBlock = big.NewInt(0)
+	config.RecordTombstoneBlock = big.NewInt(0)
+	config.PocNonceBlock = big.NewInt(0)
+	config.TxEnvelopeBlock = big.NewInt(0)
+	config.FailedTxBlock = big.NewInt(0)
+	statedb.EnableRecordTrie()
+	return &recordTester{
+		statedb:       statedb,
+		statedbRecord: statedbRecord,
+		pocContext:    pocContext,
+		config:        &config,
+		signer:        types.MakeSigner(params.TestChainConfig, big.NewInt(1)),
+		nonces:        make(map[common.Address]uint64),
+		number:        big.NewInt(1),
+	}
+}
+
+// apply signs and applies a data transaction, reporting whether it succeeded.
+// Transactions included as failed only keep the fee, the nonce and the expiry
+// cleanup, the reason they failed is kept in failure.
+func (rt *recordTester) apply(key *ecdsa.PrivateKey, txType types.TxType, to common.Address, value *big.Int, payload []byte) bool {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx, _ := types.SignTx(types.NewTransaction(txType, rt.nonces[from], to, value, nil, nil, payload), rt.signer, key)
+	header := &types.Header{Number: rt.number, Time: big.NewInt(1), Validator: rt.validator}
+
+	rt.statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+	rt.statedbRecord.Prepare(tx.Hash(), common.Hash{}, 0)
+	receipt, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx)
+	if err != nil {
+		return false
+	}
+	rt.nonces[from]++
+	rt.failure = receipt.Failure
+	rt.logs = rt.statedb.GetLogs(tx.Hash())
+	return receipt.Status == types.ReceiptStatusSuccessful
+}
+
+// confirmation returns the payload of a ConfirmationData transaction confirming
+// data with the given royalty under the record market rules.
+func confirmation(data []byte, royalty uint64) []byte {
+	payload, _ := (&types.RecordConfirmation{Data: data, Royalty: royalty}).EncodeToBytes()
+	return payload
+}
+
+// Tests that a batch confirmation registers its root and every leaf as records
+// of the sender, credits contribution up to the leaf cap and refuses batches
+// repeating a leaf or reusing an existing record.
+func TestBatchConfirmation(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	leaves := make([]common.Hash, params.BatchContributionLeafCap+8)
+	for i := range leaves {
+		leaves[i] = crypto.Keccak256Hash([]byte(fmt.Sprintf("leaf-%d", i)))
+	}
+	batch, _ := types.NewBatchConfirmation(leaves)
+	payload, _ := batch.EncodeToBytes()
+
+	if !rt.apply(key, types.BatchConfirmationData, common.Address{}, nil, payload) {
+		t.Fatalf("batch confirmation failed")
+	}
+	for _, record := range append([]common.Hash{batch.Root}, leaves...) {
+		if owner := statedbRecord.GetOwner(record); owner != sender {
+			t.Fatalf("record %x owner mismatch: have %x, want %x", record, owner, sender)
+		}
+		if !statedb.HasRecord(sender, record) {
+			t.Fatalf("record %x missing from the sender's records", record)
+		}
+	}
+	if root := statedbRecord.GetBatchRoot(leaves[0]); root != batch.Root {
+		t.Fatalf("leaf batch root mismatch: have %x, want %x", root, batch.Root)
+	}
+	// Only the capped number of leaves is credited
+	want := new(big.Int).Mul(big.NewInt(2e+18), new(big.Int).SetUint64(params.BatchContributionLeafCap))
+	if contribution := statedb.GetContribution(sender); contribution.Cmp(want) != 0 {
+		t.Fatalf("contribution mismatch: have %v, want %v", contribution, want)
+	}
+	// A batch repeating a leaf is invalid, one reusing a confirmed leaf fails
+	duplicate := &types.BatchConfirmation{Leaves: []common.Hash{{0x01}, {0x01}}}
+	duplicate.Root = types.MerkleRoot(duplicate.Leaves)
+	payload, _ = duplicate.EncodeToBytes()
+	if rt.apply(key, types.BatchConfirmationData, common.Address{}, nil, payload) {
+		t.Fatalf("batch with a duplicate leaf accepted")
+	}
+	reused, _ := types.NewBatchConfirmation([]common.Hash{{0x02}, leaves[3]})
+	payload, _ = reused.EncodeToBytes()
+	if rt.apply(key, types.BatchConfirmationData, common.Address{}, nil, payload) {
+		t.Fatalf("batch reusing a confirmed leaf accepted")
+	}
+	if rt.failure != types.FailureDuplicateRecord {
+		t.Fatalf("failure mismatch: have %v, want %v", rt.failure, types.FailureDuplicateRecord)
+	}
+	if statedbRecord.Exist(common.Hash{0x02}) {
+		t.Fatalf("leaf of a failed batch registered")
+	}
+	// Before the batch confirmation fork the type is rejected
+	legacy := *rt.config
+	legacy.BatchConfirmationBlock = big.NewInt(2)
+	fresh, _ := types.NewBatchConfirmation([]common.Hash{{0x03}, {0x04}})
+	payload, _ = fresh.EncodeToBytes()
+	tx, _ := types.SignTx(types.NewTransaction(types.BatchConfirmationData, rt.nonces[sender], common.Address{}, nil, nil, nil, payload), rt.signer, key)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, statedb.Copy(), statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork batch: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+}
+
+// Tests that record transactions from before the record trie and record market
+// forks are replayed into the state roots the chain committed to at the time,
+// including a sender still passing as the owner of a record it transferred,
+// and into receipts without logs.
+func TestLegacyRecordReplay(t *testing.T) {
+	var (
+		db, _            = ethdb.NewMemDatabase()
+		statedb, _       = state.New(common.Hash{}, state.NewDatabase(db))
+		statedbRecord, _ = state.NewRecord(common.Hash{}, state.NewDatabase(db))
+		config           = params.TestChainConfig
+		signer           = types.MakeSigner(config, big.NewInt(1))
+		header           = &types.Header{Number: big.NewInt(1), Time: big.NewInt(10)}
+		key, _           = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		friend           = common.HexToAddress("0x0b")
+		other            = common.HexToAddress("0x0c")
+	)
+	tests := []struct {
+		tx         *types.Transaction
+		root       common.Hash
+		recordRoot common.Hash
+	}{
+		{types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, []byte("doc1")),
+			common.HexToHash("cf854be03ca95e0a5de5044505dddfa1d6c73b6a5ff96f3ad4bf93ab4018a133"),
+			common.HexToHash("cd60cd02fdad0bce2e46421189bf8e978c8bf56802713a35b5e8e2d1ca27e477")},
+		{types.NewTransaction(types.ConfirmationData, 1, common.Address{}, nil, nil, nil, []byte("doc2")),
+			common.HexToHash("0e10b4cefd1dffded2baab5c71b5eb88e8b2845679a61a8dd6414477e1eb3be5"),
+			common.HexToHash("5d4d909637ae6e5820816e3b75f4858e4841230012232b357e873b4428414c88")},
+		{types.NewTransaction(types.TransferData, 2, friend, nil, nil, nil, []byte("doc1")),
+			common.HexToHash("73b67204aa5ccbf92333a0c02976f18919d4054a684bd34450a31f955de0314e"),
+			common.HexToHash("595ccd292309a45a358be473831dadc5d4a19f8d024715e196e684e26e38fc52")},
+		{types.NewTransaction(types.TransferData, 3, other, nil, nil, nil, []byte("doc1")),
+			common.HexToHash("d089333de0cdf4176b3bcef00361c4f2289fb7338d9f0c346c3022506ca6694a"),
+			common.HexToHash("7bffe65d273ce49ad844d233aee7d45e21185b176c7face1302254e63871dfa6")},
+	}
+	for i, test := range tests {
+		tx, _ := types.SignTx(test.tx, signer, key)
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		receipt, err := ApplyTransaction(config, nil, nil, nil, statedb, statedbRecord, header, tx)
+		if err != nil {
+			t.Fatalf("tx %d: failed to apply: %v", i, err)
+		}
+		if len(receipt.Logs) != 0 {
+			t.Errorf("tx %d: legacy receipt has %d logs", i, len(receipt.Logs))
+		}
+		if root := statedb.IntermediateRoot(true); root != test.root {
+			t.Errorf("tx %d: state root mismatch: have %x, want %x", i, root, test.root)
+		}
+		if root := statedbRecord.IntermediateRoot(true); root != test.recordRoot {
+			t.Errorf("tx %d: record root mismatch: have %x, want %x", i, root, test.recordRoot)
+		}
+	}
+}
+
+// Tests that a listed record is sold atomically, paying the royalty fixed at
+// confirmation to the origin and the rest of the price to the current owner.
+func TestPricedRecordTransfer(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	var (
+		originKey, _  = crypto.GenerateKey()
+		buyerKey, _   = crypto.GenerateKey()
+		resellKey, _  = crypto.GenerateKey()
+		origin        = crypto.PubkeyToAddress(originKey.PublicKey)
+		buyer         = crypto.PubkeyToAddress(buyerKey.PublicKey)
+		reseller      = crypto.PubkeyToAddress(resellKey.PublicKey)
+		payload       = []byte("artwork")
+		record        = (&types.RecordConfirmation{Data: payload}).Key()
+		price         = big.NewInt(1000)
+		expectRoyalty = big.NewInt(100)
+	)
+	statedb.AddBalance(buyer, big.NewInt(5000))
+	statedb.AddBalance(reseller, big.NewInt(5000))
+
+	apply := func(key *ecdsa.PrivateKey, txType types.TxType, to common.Address, value *big.Int) bool {
+		return rt.apply(key, txType, to, value, payload)
+	}
+
+	// Confirm with a 10% royalty; rates above the cap fail
+	if rt.apply(originKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, params.MaxRoyalty+1)) {
+		t.Fatalf("confirmation with excessive royalty accepted")
+	}
+	if !rt.apply(originKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 1000)) {
+		t.Fatalf("confirmation failed")
+	}
+	// Buying an unlisted record must fail
+	if apply(buyerKey, types.TransferData, origin, price) {
+		t.Fatalf("unlisted record sold")
+	}
+	// List and sell it, the origin is also the seller so receives everything
+	if !apply(originKey, types.TransferData, origin, price) {
+		t.Fatalf("listing failed")
+	}
+	if apply(buyerKey, types.TransferData, origin, big.NewInt(999)) {
+		t.Fatalf("record sold below the asking price")
+	}
+	if !apply(buyerKey, types.TransferData, origin, price) {
+		t.Fatalf("purchase failed")
+	}
+	if owner := statedbRecord.GetOwner(record); owner != buyer {
+		t.Fatalf("owner mismatch: have %x, want %x", owner, buyer)
+	}
+	if ask := statedbRecord.GetAskPrice(record); ask.Sign() != 0 {
+		t.Fatalf("listing not cleared after sale: %v", ask)
+	}
+	if balance := statedb.GetBalance(origin); balance.Cmp(price) != 0 {
+		t.Fatalf("origin balance mismatch: have %v, want %v", balance, price)
+	}
+	// Resell it, the origin only receives the royalty
+	if !apply(buyerKey, types.TransferData, buyer, price) {
+		t.Fatalf("relisting failed")
+	}
+	if !apply(resellKey, types.TransferData, buyer, price) {
+		t.Fatalf("resale failed")
+	}
+	if balance := statedb.GetBalance(origin); balance.Cmp(new(big.Int).Add(price, expectRoyalty)) != 0 {
+		t.Fatalf("origin balance mismatch: have %v, want %v", balance, new(big.Int).Add(price, expectRoyalty))
+	}
+	if balance, want := statedb.GetBalance(buyer), big.NewInt(5000-1000+900); balance.Cmp(want) != 0 {
+		t.Fatalf("seller balance mismatch: have %v, want %v", balance, want)
+	}
+	if !statedb.HasRecord(reseller, record) || statedb.HasRecord(buyer, record) {
+		t.Fatalf("owned record index not updated")
+	}
+	logs := rt.logs
+	if len(logs) != 2 || logs[0].Topics[0] != RecordSoldTopic || logs[0].Topics[2] != buyer.Hash() || logs[0].Topics[3] != reseller.Hash() {
+		t.Fatalf("sale logs mismatch: %v", logs)
+	}
+	if logs[1].Topics[0] != RecordTransferredTopic || logs[1].Topics[2] != buyer.Hash() || logs[1].Topics[3] != reseller.Hash() {
+		t.Fatalf("transfer log mismatch: %v", logs[1])
+	}
+}
+
+// Tests the offer, accept and cancel flow of two-phase record transfers,
+// including the lazy expiry of offers that were never answered.
+func TestTwoPhaseRecordTransfer(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	var (
+		ownerKey, _  = crypto.GenerateKey()
+		friendKey, _ = crypto.GenerateKey()
+		owner        = crypto.PubkeyToAddress(ownerKey.PublicKey)
+		friend       = crypto.PubkeyToAddress(friendKey.PublicKey)
+		payload      = []byte("contract")
+		record       = (&types.RecordConfirmation{Data: payload}).Key()
+	)
+	statedb.AddBalance(friend, big.NewInt(100))
+
+	apply := func(key *ecdsa.PrivateKey, txType types.TxType, to common.Address, value *big.Int) bool {
+		return rt.apply(key, txType, to, value, payload)
+	}
+	if !rt.apply(ownerKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	// Offer the record, which blocks direct transfers until answered
+	if !apply(ownerKey, types.TransferOffer, friend, big.NewInt(60)) {
+		t.Fatalf("offer failed")
+	}
+	if have := statedb.PendingOffers(friend); len(have) != 1 || have[0] != record {
+		t.Fatalf("pending offers mismatch: %v", have)
+	}
+	if apply(ownerKey, types.TransferData, friend, nil) {
+		t.Fatalf("record with a pending offer transferred directly")
+	}
+	if apply(ownerKey, types.TransferAccept, common.Address{}, nil) {
+		t.Fatalf("offer accepted by the owner")
+	}
+	// Cancel and offer again, the recipient then accepts and pays
+	if !apply(ownerKey, types.TransferCancel, common.Address{}, nil) {
+		t.Fatalf("cancel failed")
+	}
+	if have := statedb.PendingOffers(friend); len(have) != 0 {
+		t.Fatalf("pending offers left after cancel: %v", have)
+	}
+	if !apply(ownerKey, types.TransferOffer, friend, big.NewInt(60)) {
+		t.Fatalf("second offer failed")
+	}
+	if !apply(friendKey, types.TransferAccept, common.Address{}, nil) {
+		t.Fatalf("accept failed")
+	}
+	if have := statedbRecord.GetOwner(record); have != friend {
+		t.Fatalf("owner mismatch: have %x, want %x", have, friend)
+	}
+	if balance := statedb.GetBalance(owner); balance.Cmp(big.NewInt(60)) != 0 {
+		t.Fatalf("seller balance mismatch: have %v, want 60", balance)
+	}
+	if status := statedbRecord.GetStatus(record); status != state.RecordNormal {
+		t.Fatalf("status mismatch: have %d, want %d", status, state.RecordNormal)
+	}
+	// An offer that is never accepted lapses after its expiry block
+	if !apply(friendKey, types.TransferOffer, owner, nil) {
+		t.Fatalf("offer back failed")
+	}
+	rt.number = new(big.Int).SetUint64(1 + params.TransferOfferDuration + 1)
+	if apply(ownerKey, types.TransferAccept, common.Address{}, nil) {
+		t.Fatalf("expired offer accepted")
+	}
+	if !apply(friendKey, types.TransferData, owner, nil) {
+		t.Fatalf("direct transfer after expiry failed")
+	}
+	if have := statedb.PendingOffers(owner); len(have) != 0 {
+		t.Fatalf("expired offer still indexed: %v", have)
+	}
+	// Before the record trie fork the accepted transfer is listed by its hash
+	legacy := newRecordTester()
+	config := *legacy.config
+	config.RecordTrieBlock = big.NewInt(100)
+	legacy.config = &config
+	db, _ := ethdb.NewMemDatabase()
+	legacy.statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+	legacy.statedb.AddBalance(friend, big.NewInt(100))
+
+	if !legacy.apply(ownerKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("legacy confirmation failed")
+	}
+	if !legacy.apply(ownerKey, types.TransferOffer, friend, nil, payload) || !legacy.apply(friendKey, types.TransferAccept, common.Address{}, nil, payload) {
+		t.Fatalf("legacy offer and accept failed")
+	}
+	if records := legacy.statedb.GetRecords(friend); len(records) != 1 || records[0] == record {
+		t.Fatalf("legacy account records mismatch: %x", records)
+	}
+	// Before the record market fork two-phase transfers don't exist
+	config.RecordMarketBlock = big.NewInt(2)
+	tx, _ := types.SignTx(types.NewTransaction(types.TransferOffer, legacy.nonces[owner], friend, nil, nil, nil, payload), legacy.signer, ownerKey)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&config, legacy.pocContext, nil, nil, legacy.statedb, legacy.statedbRecord, header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork offer: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+}
+
+// Tests that a jointly owned record only changes status or owner once enough
+// co-owners approved the same proposal.
+func TestJointRecordApproval(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	owners := make([]common.Address, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	var (
+		outsiderKey, _ = crypto.GenerateKey()
+		buyer          = common.HexToAddress("0x0000000000000000000000000000000000000bbb")
+		payload        = []byte("estate")
+		record         = (&types.RecordConfirmation{Data: payload}).Key()
+	)
+	encode := func(v interface{ EncodeToBytes() ([]byte, error) }) []byte {
+		data, _ := v.EncodeToBytes()
+		return data
+	}
+	if !rt.apply(keys[0], types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	joint := encode(&types.JointOwnership{Record: record, Owners: owners, Threshold: 2})
+	if rt.apply(outsiderKey, types.JointOwnershipData, common.Address{}, nil, joint) {
+		t.Fatalf("record made joint by a non-owner")
+	}
+	if !rt.apply(keys[0], types.JointOwnershipData, common.Address{}, nil, joint) {
+		t.Fatalf("joint ownership failed")
+	}
+	for _, owner := range owners {
+		if !statedb.HasRecord(owner, record) {
+			t.Fatalf("record missing from co-owner %x", owner)
+		}
+	}
+	// The former single owner can no longer transfer directly
+	if rt.apply(keys[0], types.TransferData, buyer, nil, payload) {
+		t.Fatalf("jointly owned record transferred directly")
+	}
+	// Freeze the record with two approvals
+	freeze := encode(&types.RecordProposal{Record: record, Action: types.ProposalStatus, Status: 2})
+	if !rt.apply(keys[1], types.JointApprovalData, common.Address{}, nil, freeze) {
+		t.Fatalf("first approval failed")
+	}
+	if rt.apply(keys[1], types.JointApprovalData, common.Address{}, nil, freeze) {
+		t.Fatalf("duplicate approval accepted")
+	}
+	if rt.apply(outsiderKey, types.JointApprovalData, common.Address{}, nil, freeze) {
+		t.Fatalf("approval by an outsider accepted")
+	}
+	if ids := statedbRecord.GetProposals(record); len(ids) != 1 || statedbRecord.GetStatus(record) != state.RecordNormal {
+		t.Fatalf("proposal state mismatch: %d proposals, status %d", len(ids), statedbRecord.GetStatus(record))
+	}
+	if !rt.apply(keys[2], types.JointApprovalData, common.Address{}, nil, freeze) {
+		t.Fatalf("second approval failed")
+	}
+	if status := statedbRecord.GetStatus(record); status != 2 {
+		t.Fatalf("status mismatch: have %d, want 2", status)
+	}
+	// Unfreeze, then transfer to a single owner which dissolves the joint ownership
+	unfreeze := encode(&types.RecordProposal{Record: record, Action: types.ProposalStatus, Status: state.RecordNormal})
+	rt.apply(keys[0], types.JointApprovalData, common.Address{}, nil, unfreeze)
+	rt.apply(keys[1], types.JointApprovalData, common.Address{}, nil, unfreeze)
+
+	transfer := encode(&types.RecordProposal{Record: record, Action: types.ProposalTransfer, To: buyer})
+	if !rt.apply(keys[0], types.JointApprovalData, common.Address{}, nil, transfer) {
+		t.Fatalf("transfer approval failed")
+	}
+	// A stale approval expires before the second co-owner signs
+	rt.number = new(big.Int).SetUint64(2 + params.RecordProposalDuration)
+	if !rt.apply(keys[2], types.JointApprovalData, common.Address{}, nil, transfer) {
+		t.Fatalf("transfer approval after expiry failed")
+	}
+	if owner := statedbRecord.GetOwner(record); owner != (common.Address{}) {
+		t.Fatalf("expired approval counted, owner %x", owner)
+	}
+	if !rt.apply(keys[1], types.JointApprovalData, common.Address{}, nil, transfer) {
+		t.Fatalf("final transfer approval failed")
+	}
+	if owner := statedbRecord.GetOwner(record); owner != buyer {
+		t.Fatalf("owner mismatch: have %x, want %x", owner, buyer)
+	}
+	if set, _ := statedbRecord.GetJointOwners(record); len(set) != 0 || len(statedbRecord.GetProposals(record)) != 0 {
+		t.Fatalf("joint ownership not dissolved: owners %v", set)
+	}
+	if !statedb.HasRecord(buyer, record) || statedb.HasRecord(owners[0], record) {
+		t.Fatalf("owned record index not updated")
+	}
+	// Before the record trie fork co-owners list the transaction hash
+	legacy := newRecordTester()
+	config := *legacy.config
+	config.RecordTrieBlock = big.NewInt(100)
+	legacy.config = &config
+	db, _ := ethdb.NewMemDatabase()
+	legacy.statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+	// Accounts holding nothing but records were empty and deleted back then
+	legacy.statedb.AddBalance(owners[1], big.NewInt(1))
+
+	if !legacy.apply(keys[0], types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("legacy confirmation failed")
+	}
+	if !legacy.apply(keys[0], types.JointOwnershipData, common.Address{}, nil, joint) {
+		t.Fatalf("legacy joint ownership failed")
+	}
+	if records := legacy.statedb.GetRecords(owners[1]); len(records) != 1 || records[0] == record {
+		t.Fatalf("legacy co-owner records mismatch: %x", records)
+	}
+	// Before the record market fork joint ownership doesn't exist
+	config.RecordMarketBlock = big.NewInt(2)
+	tx, _ := types.SignTx(types.NewTransaction(types.JointApprovalData, 0, common.Address{}, nil, nil, nil, freeze), legacy.signer, keys[1])
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&config, legacy.pocContext, nil, nil, legacy.statedb, legacy.statedbRecord, header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork approval: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+}
+
+// Tests that encrypted records are keyed by their ciphertext digest and can
+// only be authorized or given away with a content key wrapped to the recipient.
+func TestEncryptedRecordGrants(t *testing.T) {
+	rt := newRecordTester()
+	statedbRecord := rt.statedbRecord
+
+	var (
+		ownerKey, _  = crypto.GenerateKey()
+		friendKey, _ = crypto.GenerateKey()
+		friend       = crypto.PubkeyToAddress(friendKey.PublicKey)
+	)
+	sealed, _ := types.SealRecord([]byte("secret"), &ownerKey.PublicKey)
+	payload, _ := sealed.EncodeToBytes()
+	record := sealed.Key()
+
+	if !rt.apply(ownerKey, types.EncryptedConfirmationData, common.Address{}, nil, payload) {
+		t.Fatalf("encrypted confirmation failed")
+	}
+	if !statedbRecord.IsEncrypted(record) || statedbRecord.GetOwner(record) != crypto.PubkeyToAddress(ownerKey.PublicKey) {
+		t.Fatalf("encrypted record not created under the ciphertext digest")
+	}
+	// Before the record market fork encrypted confirmations don't exist
+	legacy := *rt.config
+	legacy.RecordMarketBlock = big.NewInt(2)
+	resealed, _ := types.SealRecord([]byte("other secret"), &ownerKey.PublicKey)
+	data, _ := resealed.EncodeToBytes()
+	tx, _ := types.SignTx(types.NewTransaction(types.EncryptedConfirmationData, rt.nonces[crypto.PubkeyToAddress(ownerKey.PublicKey)], common.Address{}, nil, nil, nil, data), rt.signer, ownerKey)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb.Copy(), statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork encrypted confirmation: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+	if keys := RecordKeys(params.TestChainConfig, common.Big0, types.NewTransaction(types.EncryptedConfirmationData, 0, common.Address{}, nil, nil, nil, payload), statedbRecord); len(keys) != 1 || keys[0] != record {
+		t.Fatalf("record keys mismatch: %v", keys)
+	}
+	key, _ := types.UnwrapContentKey(sealed.WrappedKey, ownerKey)
+	wrapped, _ := types.WrapContentKey(key, &friendKey.PublicKey)
+	grant, _ := (&types.KeyGrant{Record: record, WrappedKey: wrapped}).EncodeToBytes()
+	empty, _ := (&types.KeyGrant{Record: record}).EncodeToBytes()
+
+	// Authorizations need the owner and a wrapped key
+	if rt.apply(friendKey, types.AuthorizationData, friend, nil, grant) {
+		t.Fatalf("authorization by a non-owner accepted")
+	}
+	if rt.apply(ownerKey, types.AuthorizationData, friend, nil, empty) {
+		t.Fatalf("authorization without a wrapped key accepted")
+	}
+	if !rt.apply(ownerKey, types.AuthorizationData, friend, nil, grant) {
+		t.Fatalf("authorization failed")
+	}
+	if txs := statedbRecord.GetRecordTxs(record); len(txs) != 2 {
+		t.Fatalf("authorization not recorded: %v", txs)
+	}
+	// Record keys resolve grants like the state transition: only on encrypted records
+	if keys := RecordKeys(rt.config, common.Big0, types.NewTransaction(types.AuthorizationData, 0, friend, nil, nil, nil, grant), statedbRecord); len(keys) != 1 || keys[0] != record {
+		t.Fatalf("grant record keys mismatch: %x", keys)
+	}
+	plain, _ := (&types.KeyGrant{Record: common.HexToHash("0x01"), WrappedKey: wrapped}).EncodeToBytes()
+	enc, _ := rlp.EncodeToBytes(plain)
+	if keys := RecordKeys(rt.config, common.Big0, types.NewTransaction(types.AuthorizationData, 0, friend, nil, nil, nil, plain), statedbRecord); len(keys) != 1 || keys[0] != common.BytesToHash(enc) {
+		t.Fatalf("plaintext record keys mismatch: %x", keys)
+	}
+	// Transfers need a wrapped key as well
+	if rt.apply(ownerKey, types.TransferData, friend, nil, empty) {
+		t.Fatalf("transfer without a wrapped key accepted")
+	}
+	if !rt.apply(ownerKey, types.TransferData, friend, nil, grant) {
+		t.Fatalf("transfer failed")
+	}
+	if owner := statedbRecord.GetOwner(record); owner != friend {
+		t.Fatalf("owner mismatch: have %x, want %x", owner, friend)
+	}
+}
+
+// Tests that an expiring record can be renewed by its owner for a fee, and is
+// treated as gone once its expiry block passes, freeing the data to be
+// confirmed again.
+func TestRecordExpiry(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	var (
+		ownerKey, _  = crypto.GenerateKey()
+		friendKey, _ = crypto.GenerateKey()
+		owner        = crypto.PubkeyToAddress(ownerKey.PublicKey)
+		friend       = crypto.PubkeyToAddress(friendKey.PublicKey)
+		data         = []byte("lease")
+		record       = (&types.RecordConfirmation{Data: data}).Key()
+		fee          = new(big.Int).SetUint64(params.RecordRenewalFee)
+	)
+	expiring := func(expiry uint64) []byte {
+		payload, _ := (&types.RecordConfirmation{Data: data, Expiry: expiry}).EncodeToBytes()
+		return payload
+	}
+	if keys := RecordKeys(rt.config, rt.number, types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, expiring(10)), statedbRecord); len(keys) != 1 || keys[0] != record {
+		t.Fatalf("expiring record key mismatch: %x", keys)
+	}
+	rt.number = big.NewInt(5)
+	if rt.apply(ownerKey, types.ConfirmationData, common.Address{}, nil, expiring(4)) {
+		t.Fatalf("record confirmed with an expiry in the past")
+	}
+	if !rt.apply(ownerKey, types.ConfirmationData, common.Address{}, nil, expiring(10)) {
+		t.Fatalf("expiring confirmation failed")
+	}
+	if expiry := statedbRecord.GetExpiry(record); expiry != 10 {
+		t.Fatalf("expiry mismatch: have %d, want 10", expiry)
+	}
+	// Only the owner may renew, and only with enough balance for the fee
+	statedb.AddBalance(friend, fee)
+	if rt.apply(friendKey, types.RecordRenewal, common.Address{}, nil, data) {
+		t.Fatalf("record renewed by a non-owner")
+	}
+	if rt.apply(ownerKey, types.RecordRenewal, common.Address{}, nil, data) {
+		t.Fatalf("record renewed without paying the fee")
+	}
+	statedb.AddBalance(owner, fee)
+	if !rt.apply(ownerKey, types.RecordRenewal, common.Address{}, nil, data) {
+		t.Fatalf("renewal failed")
+	}
+	expiry := 10 + params.RecordRenewalPeriod
+	if have := statedbRecord.GetExpiry(record); have != expiry {
+		t.Fatalf("renewed expiry mismatch: have %d, want %d", have, expiry)
+	}
+	if balance := statedb.GetBalance(owner); balance.Sign() != 0 {
+		t.Fatalf("renewal fee not charged: balance %v", balance)
+	}
+	// Before the record market fork renewals don't exist
+	legacy := *rt.config
+	legacy.RecordMarketBlock = big.NewInt(2)
+	tx, _ := types.SignTx(types.NewTransaction(types.RecordRenewal, rt.nonces[owner], common.Address{}, nil, nil, nil, data), rt.signer, ownerKey)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, statedb.Copy(), statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork renewal: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+	// Past the expiry the record can no longer be transferred and is removed,
+	// also by a transfer included before the failed transaction fork
+	rt.number = new(big.Int).SetUint64(expiry + 1)
+	legacy.RecordMarketBlock, legacy.FailedTxBlock = big.NewInt(0), nil
+	tx, _ = types.SignTx(types.NewTransaction(types.TransferData, rt.nonces[owner], friend, nil, nil, nil, data), rt.signer, ownerKey)
+	header = &types.Header{Number: rt.number, Time: big.NewInt(1)}
+	legacyRecord := statedbRecord.Copy()
+	if receipt, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, statedb.Copy(), legacyRecord, header, tx); err != nil || receipt.Failure != types.FailureRecordExpired {
+		t.Fatalf("pre-fork expired transfer: receipt mismatch: %v, %v", receipt, err)
+	}
+	if legacyRecord.Exist(record) {
+		t.Fatalf("expired record kept by a pre-fork transfer")
+	}
+	if rt.apply(ownerKey, types.TransferData, friend, nil, data) {
+		t.Fatalf("expired record transferred")
+	}
+	if rt.failure != types.FailureRecordExpired {
+		t.Fatalf("failure mismatch: have %v, want %v", rt.failure, types.FailureRecordExpired)
+	}
+	if statedbRecord.Exist(record) {
+		t.Fatalf("expired record still exists")
+	}
+	if statedb.HasRecord(owner, record) {
+		t.Fatalf("expired record still owned")
+	}
+	statedbRecord.Finalise(true)
+	if statedbRecord.GetOwner(record) != (common.Address{}) {
+		t.Fatalf("expired record not deleted on finalise")
+	}
+	// The data is free to be confirmed again, without the old expiry
+	if !rt.apply(friendKey, types.ConfirmationData, common.Address{}, nil, confirmation(data, 0)) {
+		t.Fatalf("confirmation of expired data failed")
+	}
+	if have := statedbRecord.GetOwner(record); have != friend {
+		t.Fatalf("owner mismatch: have %x, want %x", have, friend)
+	}
+	if have := statedbRecord.GetExpiry(record); have != 0 {
+		t.Fatalf("expiry carried over: %d", have)
+	}
+	if rt.apply(friendKey, types.RecordRenewal, common.Address{}, nil, data) {
+		t.Fatalf("permanent record renewed")
+	}
+}
+
+// Tests that data-record and poc transactions emit logs carrying the record
+// key and the parties as topics, and that the block bloom matches them.
+func TestRecordLogs(t *testing.T) {
+	rt := newRecordTester()
+
+	var (
+		ownerKey, _  = crypto.GenerateKey()
+		friendKey, _ = crypto.GenerateKey()
+		owner        = crypto.PubkeyToAddress(ownerKey.PublicKey)
+		friend       = crypto.PubkeyToAddress(friendKey.PublicKey)
+		payload      = []byte("report")
+		record       = (&types.RecordConfirmation{Data: payload}).Key()
+	)
+	check := func(event string, topics ...common.Hash) {
+		if len(rt.logs) != 1 {
+			t.Fatalf("%s: have %d logs, want 1", event, len(rt.logs))
+		}
+		if have := rt.logs[0].Topics; len(have) != len(topics) {
+			t.Fatalf("%s: topics mismatch: have %x, want %x", event, have, topics)
+		}
+		for i, topic := range topics {
+			if rt.logs[0].Topics[i] != topic {
+				t.Fatalf("%s: topic %d mismatch: have %x, want %x", event, i, rt.logs[0].Topics[i], topic)
+			}
+		}
+		bloom := types.CreateBloom(types.Receipts{{Logs: rt.logs}})
+		for _, topic := range topics {
+			if !types.BloomLookup(bloom, topic) {
+				t.Fatalf("%s: topic %x missing from bloom", event, topic)
+			}
+		}
+	}
+	if !rt.apply(ownerKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	check("confirm", RecordConfirmedTopic, record, owner.Hash())
+
+	// Only the owner of an existing record may authorize it
+	if rt.apply(ownerKey, types.AuthorizationData, friend, nil, []byte("unknown")) || rt.failure != types.FailureUnknownRecord {
+		t.Fatalf("authorization of an unknown record accepted")
+	}
+	if rt.apply(friendKey, types.AuthorizationData, owner, nil, payload) || rt.failure != types.FailureNotOwner {
+		t.Fatalf("authorization by a non-owner accepted")
+	}
+	if !rt.apply(ownerKey, types.AuthorizationData, friend, nil, payload) {
+		t.Fatalf("authorization failed")
+	}
+	check("authorize", AuthorizationGrantedTopic, record, owner.Hash(), friend.Hash())
+
+	if !rt.apply(ownerKey, types.TransferData, friend, nil, payload) {
+		t.Fatalf("transfer failed")
+	}
+	check("transfer", RecordTransferredTopic, record, owner.Hash(), friend.Hash())
+
+	// Candidate logins and logouts are logged against the candidate
+	db, _ := ethdb.NewMemDatabase()
+	pocContext, _ := types.NewPocContext(db)
+	for _, test := range []struct {
+		txType types.TxType
+		topic  common.Hash
+	}{
+		{types.LoginCandidate, CandidateLoginTopic},
+		{types.LogoutCandidate, CandidateLogoutTopic},
+	} {
+		tx, _ := types.SignTx(types.NewTransaction(test.txType, rt.nonces[friend], common.Address{}, nil, nil, nil, nil), rt.signer, friendKey)
+		msg, _ := tx.AsMessage(rt.signer)
+		rt.statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+		if _, err := applyPocMessage(rt.config, rt.number, rt.statedb, pocContext, msg, rt.validator, nil); err != nil {
+			t.Fatalf("poc message failed: %v", err)
+		}
+		// A replayed poc message is rejected
+		if _, err := applyPocMessage(rt.config, rt.number, rt.statedb, pocContext, msg, rt.validator, nil); err == nil {
+			t.Fatalf("poc message replayed")
+		}
+		rt.nonces[friend]++
+		rt.logs = rt.statedb.GetLogs(tx.Hash())
+		check("poc", test.topic, friend.Hash())
+	}
+	// Before the poc nonce fork poc messages neither check nor consume the nonce
+	legacy := *rt.config
+	legacy.PocNonceBlock = nil
+	nonce := rt.statedb.GetNonce(friend)
+	tx, _ := types.SignTx(types.NewTransaction(types.LogoutCandidate, nonce+5, common.Address{}, nil, nil, nil, nil), rt.signer, friendKey)
+	msg, _ := tx.AsMessage(rt.signer)
+	for i := 0; i < 2; i++ {
+		if failed, err := applyPocMessage(&legacy, rt.number, rt.statedb, pocContext, msg, rt.validator, nil); failed || err != nil {
+			t.Fatalf("legacy poc message %d failed: %v", i, err)
+		}
+	}
+	if have := rt.statedb.GetNonce(friend); have != nonce {
+		t.Fatalf("legacy poc message consumed the nonce: have %d, want %d", have, nonce)
+	}
+}
+
+// Tests that a tombstone issued by the origin, or approved by enough
+// validators, erases a record for good while keeping its provenance.
+func TestRecordTombstone(t *testing.T) {
+	rt := newRecordTester()
+	statedb, statedbRecord := rt.statedb, rt.statedbRecord
+
+	var (
+		originKey, _   = crypto.GenerateKey()
+		friendKey, _   = crypto.GenerateKey()
+		strangerKey, _ = crypto.GenerateKey()
+		origin         = crypto.PubkeyToAddress(originKey.PublicKey)
+		friend         = crypto.PubkeyToAddress(friendKey.PublicKey)
+		payload        = []byte("leaked document")
+		record         = (&types.RecordConfirmation{Data: payload}).Key()
+	)
+	tombstone := func(record common.Hash) []byte {
+		data, _ := (&types.Tombstone{Record: record}).EncodeToBytes()
+		return data
+	}
+	if !rt.apply(originKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	if !rt.apply(originKey, types.TransferOffer, friend, nil, payload) {
+		t.Fatalf("offer failed")
+	}
+	// Only the origin or a validator may erase the record
+	if rt.apply(strangerKey, types.RecordTombstone, common.Address{}, nil, tombstone(record)) {
+		t.Fatalf("record erased by a stranger")
+	}
+	// Before the record tombstone fork records can't be erased
+	legacy := *rt.config
+	legacy.RecordTombstoneBlock = big.NewInt(2)
+	tx, _ := types.SignTx(types.NewTransaction(types.RecordTombstone, rt.nonces[origin], common.Address{}, nil, nil, nil, tombstone(record)), rt.signer, originKey)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, statedb.Copy(), statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork tombstone: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+	if !rt.apply(originKey, types.RecordTombstone, common.Address{}, nil, tombstone(record)) {
+		t.Fatalf("tombstone failed")
+	}
+	if !statedbRecord.IsErased(record) {
+		t.Fatalf("record not erased")
+	}
+	if len(rt.logs) != 1 || rt.logs[0].Topics[0] != RecordStatusChangedTopic {
+		t.Fatalf("status change not logged: %v", rt.logs)
+	}
+	if have := statedb.PendingOffers(friend); len(have) != 0 {
+		t.Fatalf("offer of erased record still indexed: %v", have)
+	}
+	// Provenance is kept, but the record can no longer change hands
+	if statedbRecord.GetOrigin(record) != origin || statedbRecord.GetOwner(record) != origin || len(statedbRecord.GetRecordTxs(record)) != 3 {
+		t.Fatalf("provenance of erased record lost")
+	}
+	for _, txType := range []types.TxType{types.TransferData, types.AuthorizationData, types.TransferOffer, types.TransferAccept, types.ConfirmationData} {
+		data := payload
+		if txType == types.ConfirmationData {
+			data = confirmation(payload, 0)
+		}
+		if rt.apply(originKey, txType, friend, nil, data) {
+			t.Errorf("tx type %d applied to an erased record", txType)
+		}
+	}
+	if rt.apply(originKey, types.RecordTombstone, common.Address{}, nil, tombstone(record)) {
+		t.Fatalf("record erased twice")
+	}
+
+	// A takedown needs the approval of more than two thirds of the validators
+	validatorKeys := make([]*ecdsa.PrivateKey, 3)
+	validators := make([]common.Address, 3)
+	for i := range validatorKeys {
+		validatorKeys[i], _ = crypto.GenerateKey()
+		validators[i] = crypto.PubkeyToAddress(validatorKeys[i].PublicKey)
+	}
+	rt.pocContext.SetValidators(validators)
+
+	other := []byte("defamatory post")
+	otherRecord := (&types.RecordConfirmation{Data: other}).Key()
+	if !rt.apply(friendKey, types.ConfirmationData, common.Address{}, nil, confirmation(other, 0)) {
+		t.Fatalf("second confirmation failed")
+	}
+	for i, key := range validatorKeys {
+		if !rt.apply(key, types.RecordTombstone, common.Address{}, nil, tombstone(otherRecord)) {
+			t.Fatalf("takedown approval %d failed", i)
+		}
+		if i == 0 && rt.apply(key, types.RecordTombstone, common.Address{}, nil, tombstone(otherRecord)) {
+			t.Fatalf("takedown approved twice by the same validator")
+		}
+		if erased := statedbRecord.IsErased(otherRecord); erased != (i == len(validatorKeys)-1) {
+			t.Fatalf("erased after %d approvals: %v", i+1, erased)
+		}
+	}
+	if rt.apply(friendKey, types.AuthorizationData, origin, nil, other) {
+		t.Fatalf("erased record authorized")
+	}
+}
+
+// Tests that from the fee fork on senders pay the base fee of the transaction
+// type plus the payload fee to the validator, and that a sender unable to pay
+// cannot get the transaction included.
+func TestTransactionFee(t *testing.T) {
+	rt := newRecordTester()
+	config := *rt.config
+	config.FeeBlock = big.NewInt(2)
+	config.Fee = &params.FeeConfig{
+		BaseFee:  big.NewInt(100),
+		TypeFees: map[uint8]*big.Int{uint8(types.TransferData): big.NewInt(50)},
+		ByteFee:  big.NewInt(2),
+	}
+	rt.config = &config
+	rt.validator = common.HexToAddress("0x0a")
+
+	var (
+		senderKey, _ = crypto.GenerateKey()
+		poorKey, _   = crypto.GenerateKey()
+		sender       = crypto.PubkeyToAddress(senderKey.PublicKey)
+		friend       = common.HexToAddress("0x0b")
+		payload      = []byte("document")
+	)
+	rt.statedb.AddBalance(sender, big.NewInt(10000))
+	rt.statedb.AddBalance(crypto.PubkeyToAddress(poorKey.PublicKey), big.NewInt(10))
+
+	// No fee is charged before the fork
+	if !rt.apply(senderKey, types.ConfirmationData, common.Address{}, nil, confirmation([]byte("early"), 0)) {
+		t.Fatalf("confirmation before the fork failed")
+	}
+	if balance := rt.statedb.GetBalance(rt.validator); balance.Sign() != 0 {
+		t.Fatalf("fee charged before the fork: %v", balance)
+	}
+
+	rt.number = big.NewInt(2)
+	// The payload fee covers the whole confirmation payload
+	if !rt.apply(senderKey, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	fee := int64(100 + 2*len(confirmation(payload, 0)))
+	if balance := rt.statedb.GetBalance(sender); balance.Cmp(big.NewInt(10000-fee)) != 0 {
+		t.Fatalf("sender balance mismatch: have %v, want %v", balance, 10000-fee)
+	}
+	// Transfers pay their own base fee
+	if !rt.apply(senderKey, types.TransferData, friend, nil, payload) {
+		t.Fatalf("transfer failed")
+	}
+	fee += int64(50 + 2*len(payload))
+	if balance := rt.statedb.GetBalance(rt.validator); balance.Cmp(big.NewInt(fee)) != 0 {
+		t.Fatalf("validator balance mismatch: have %v, want %v", balance, fee)
+	}
+	// A sender short of the fee is rejected
+	if rt.apply(poorKey, types.ConfirmationData, common.Address{}, nil, confirmation([]byte("spam"), 0)) {
+		t.Fatalf("transaction applied without paying the fee")
+	}
+}
+
+// Tests that a transaction with a validity window is only applied to blocks
+// within its block number and time bounds.
+func TestTransactionWindow(t *testing.T) {
+	rt := newRecordTester()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	rt.statedb.AddBalance(from, big.NewInt(1000))
+
+	window := &types.TxWindow{AfterBlock: 2, UntilBlock: 4, UntilTime: 100}
+	tx, _ := types.SignTx(types.NewTransaction(types.Binary, 0, common.HexToAddress("0x0b"), big.NewInt(10), big.NewInt(21000), big.NewInt(0), nil).WithWindow(window), rt.signer, key)
+
+	tests := []struct {
+		number, time int64
+		err          error
+	}{
+		{1, 10, ErrTxNotYetValid},
+		{5, 10, ErrTxExpired},
+		{3, 101, ErrTxExpired},
+		{3, 100, nil},
+	}
+	for i, test := range tests {
+		header := &types.Header{Number: big.NewInt(test.number), Time: big.NewInt(test.time)}
+		if _, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+	// Before the envelope fork the window is not recognised
+	legacy := *rt.config
+	legacy.TxEnvelopeBlock = big.NewInt(4)
+	header := &types.Header{Number: big.NewInt(3), Time: big.NewInt(100)}
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err != ErrTxEnvelopeNotActive {
+		t.Errorf("pre-fork window: error mismatch: have %v, want %v", err, ErrTxEnvelopeNotActive)
+	}
+	// Crossed bounds are rejected outright
+	crossed := types.NewTransaction(types.Binary, 1, common.HexToAddress("0x0b"), nil, nil, nil, nil).WithWindow(&types.TxWindow{AfterBlock: 5, UntilBlock: 4})
+	if err := crossed.Validate(); err != types.ErrInvalidWindow {
+		t.Fatalf("crossed window: error mismatch: have %v, want %v", err, types.ErrInvalidWindow)
+	}
+}
+
+// Tests that the sponsor of a sponsored transaction pays its fee, that the
+// sender's nonce prevents replays and that a sponsor signature is only valid
+// for the transaction it was made for.
+func TestSponsoredTransaction(t *testing.T) {
+	rt := newRecordTester()
+	config := *rt.config
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(100)}
+	rt.config = &config
+
+	var (
+		userKey, _    = crypto.GenerateKey()
+		sponsorKey, _ = crypto.GenerateKey()
+		otherKey, _   = crypto.GenerateKey()
+		user          = crypto.PubkeyToAddress(userKey.PublicKey)
+		sponsor       = crypto.PubkeyToAddress(sponsorKey.PublicKey)
+		header        = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1), Validator: common.HexToAddress("0x0a")}
+	)
+	rt.statedb.AddBalance(sponsor, big.NewInt(1000))
+
+	sponsored := func(nonce uint64, payload string, key *ecdsa.PrivateKey) *types.Transaction {
+		tx := types.NewTransaction(types.ConfirmationData, nonce, common.Address{}, nil, nil, nil, confirmation([]byte(payload), 0)).WithSponsor(sponsor)
+		tx, _ = types.SignTx(tx, rt.signer, userKey)
+		tx, _ = types.SignSponsor(tx, key)
+		return tx
+	}
+	tx := sponsored(0, "document", sponsorKey)
+	if from, _ := types.Sender(rt.signer, tx); from != user {
+		t.Fatalf("sender mismatch: have %x, want %x", from, user)
+	}
+	if payer, _ := types.Sponsor(tx); payer != sponsor {
+		t.Fatalf("sponsor mismatch: have %x, want %x", payer, sponsor)
+	}
+	if _, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err != nil {
+		t.Fatalf("sponsored transaction failed: %v", err)
+	}
+	if balance := rt.statedb.GetBalance(sponsor); balance.Cmp(big.NewInt(900)) != 0 {
+		t.Fatalf("sponsor balance mismatch: have %v, want 900", balance)
+	}
+	if balance := rt.statedb.GetBalance(header.Validator); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("validator balance mismatch: have %v, want 100", balance)
+	}
+	if nonce := rt.statedb.GetNonce(user); nonce != 1 {
+		t.Fatalf("sender nonce mismatch: have %d, want 1", nonce)
+	}
+	if nonce := rt.statedb.GetNonce(sponsor); nonce != 0 {
+		t.Fatalf("sponsor nonce changed: %d", nonce)
+	}
+	// The sender's nonce rules out replaying the transaction
+	if _, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err == nil {
+		t.Fatalf("sponsored transaction replayed")
+	}
+	// Only the declared sponsor can sign, and only for this very transaction
+	if _, err := types.Sponsor(sponsored(1, "other", otherKey)); err != types.ErrSponsorSigMismatch {
+		t.Fatalf("foreign sponsor: error mismatch: have %v, want %v", err, types.ErrSponsorSigMismatch)
+	}
+	unsigned := types.NewTransaction(types.ConfirmationData, 1, common.Address{}, nil, nil, nil, confirmation([]byte("other"), 0)).WithSponsor(sponsor)
+	unsigned, _ = types.SignTx(unsigned, rt.signer, userKey)
+	if _, err := types.Sponsor(unsigned); err != types.ErrMissingSponsorSig {
+		t.Fatalf("unsigned sponsorship: error mismatch: have %v, want %v", err, types.ErrMissingSponsorSig)
+	}
+	sig, _ := crypto.Sign(types.SponsorHash(tx).Bytes(), sponsorKey)
+	reused, _ := unsigned.WithSponsorSignature(sig)
+	if payer, err := types.Sponsor(reused); err == nil && payer == sponsor {
+		t.Fatalf("sponsor signature reused for another transaction")
+	}
+	// Value transfers and candidate transactions cannot be sponsored
+	binary := types.NewTransaction(types.Binary, 1, common.HexToAddress("0x0b"), big.NewInt(1), nil, nil, nil).WithSponsor(sponsor)
+	if err := binary.Validate(); err != types.ErrUnsponsorableType {
+		t.Fatalf("sponsored transfer: error mismatch: have %v, want %v", err, types.ErrUnsponsorableType)
+	}
+}
+
+// Tests that the operations of a multi-operation transaction take effect
+// together or not at all, with a result per operation in the receipt.
+func TestMultiOperation(t *testing.T) {
+	rt := newRecordTester()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		partner = common.HexToAddress("0x0b")
+		header  = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	)
+	rt.statedb.AddBalance(sender, big.NewInt(1000))
+
+	apply := func(ops ...*types.Operation) (*types.Receipt, error) {
+		payload, err := (&types.MultiOperation{Ops: ops}).EncodeToBytes()
+		if err != nil {
+			t.Fatalf("failed to encode operations: %v", err)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(types.MultiOperationData, rt.nonces[sender], common.Address{}, nil, nil, nil, payload), rt.signer, key)
+		receipt, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx)
+		if err == nil {
+			rt.nonces[sender]++
+		}
+		return receipt, err
+	}
+	record := func(data string) common.Hash {
+		return (&types.RecordConfirmation{Data: []byte(data)}).Key()
+	}
+	statuses := func(receipt *types.Receipt) []uint {
+		var statuses []uint
+		for _, result := range receipt.Results {
+			statuses = append(statuses, result.Status)
+		}
+		return statuses
+	}
+	// Register, authorize a partner and pay it in one transaction
+	receipt, err := apply(
+		&types.Operation{Type: types.ConfirmationData, Payload: confirmation([]byte("document"), 0)},
+		&types.Operation{Type: types.AuthorizationData, Recipient: partner, Payload: []byte("document")},
+		&types.Operation{Type: types.Binary, Recipient: partner, Value: big.NewInt(10)},
+	)
+	if err != nil {
+		t.Fatalf("multi-operation failed: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful || fmt.Sprint(statuses(receipt)) != "[1 1 1]" {
+		t.Fatalf("receipt mismatch: status %d, results %v", receipt.Status, statuses(receipt))
+	}
+	if !rt.statedbRecord.Exist(record("document")) || rt.statedb.GetBalance(partner).Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("operations did not take effect")
+	}
+	// A failing operation reverts the earlier ones and skips the later ones
+	receipt, err = apply(
+		&types.Operation{Type: types.ConfirmationData, Payload: confirmation([]byte("draft"), 0)},
+		&types.Operation{Type: types.TransferData, Recipient: partner, Payload: []byte("missing")},
+		&types.Operation{Type: types.Binary, Recipient: partner, Value: big.NewInt(10)},
+	)
+	if err != nil {
+		t.Fatalf("failing multi-operation not included: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed || fmt.Sprint(statuses(receipt)) != "[1 0 2]" {
+		t.Fatalf("receipt mismatch: status %d, results %v", receipt.Status, statuses(receipt))
+	}
+	if receipt.Failure != types.FailureUnknownRecord || receipt.Results[1].Failure != types.FailureUnknownRecord {
+		t.Fatalf("failure mismatch: have %v and %v, want %v", receipt.Failure, receipt.Results[1].Failure, types.FailureUnknownRecord)
+	}
+	if rt.statedbRecord.Exist(record("draft")) || rt.statedb.GetBalance(partner).Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("failed multi-operation not reverted")
+	}
+	if nonce := rt.statedb.GetNonce(sender); nonce != 2 {
+		t.Fatalf("nonce mismatch: have %d, want 2", nonce)
+	}
+	// The results survive both receipt encodings
+	consensus, storage := new(types.Receipt), new(types.ReceiptForStorage)
+	blob, _ := rlp.EncodeToBytes(receipt)
+	if err := rlp.DecodeBytes(blob, consensus); err != nil || fmt.Sprint(statuses(consensus)) != "[1 0 2]" {
+		t.Fatalf("results lost in consensus encoding: %v, %v", err, statuses(consensus))
+	}
+	blob, _ = rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+	if err := rlp.DecodeBytes(blob, storage); err != nil || fmt.Sprint(statuses((*types.Receipt)(storage))) != "[1 0 2]" {
+		t.Fatalf("results lost in storage encoding: %v, %v", err, statuses((*types.Receipt)(storage)))
+	}
+	if consensus.Failure != receipt.Failure || storage.Failure != receipt.Failure || consensus.Results[1].Failure != receipt.Failure {
+		t.Fatalf("failure lost in encoding: consensus %v, storage %v", consensus.Failure, storage.Failure)
+	}
+	// Candidate and nested operations are not allowed
+	for _, op := range []*types.Operation{{Type: types.LoginCandidate}, {Type: types.MultiOperationData}, {Type: types.RecordTombstone}} {
+		if err := (&types.MultiOperation{Ops: []*types.Operation{op}}).Validate(); err != types.ErrInvalidOperation {
+			t.Errorf("operation type %d: error mismatch: have %v, want %v", op.Type, err, types.ErrInvalidOperation)
+		}
+	}
+	// Neither multi-operations nor their operations may precede their forks
+	legacy := *rt.config
+	legacy.RecordMarketBlock = big.NewInt(2)
+	payload, _ := (&types.MultiOperation{Ops: []*types.Operation{{Type: types.TransferOffer, Recipient: partner, Payload: []byte("document")}}}).EncodeToBytes()
+	tx, _ := types.SignTx(types.NewTransaction(types.MultiOperationData, rt.nonces[sender], common.Address{}, nil, nil, nil, payload), rt.signer, key)
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb.Copy(), rt.statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork operation: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+	legacy.TxEnvelopeBlock = big.NewInt(2)
+	payload, _ = (&types.MultiOperation{Ops: []*types.Operation{{Type: types.Binary, Recipient: partner, Value: big.NewInt(1)}}}).EncodeToBytes()
+	tx, _ = types.SignTx(types.NewTransaction(types.MultiOperationData, rt.nonces[sender], common.Address{}, nil, nil, nil, payload), rt.signer, key)
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb.Copy(), rt.statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork multi-operation: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+}
+
+// Tests that a multisig account only sends transactions, such as transfers of
+// the records it owns, signed by at least the threshold of its signers.
+func TestMultisigAccount(t *testing.T) {
+	rt := newRecordTester()
+
+	var (
+		creatorKey, _  = crypto.GenerateKey()
+		outsiderKey, _ = crypto.GenerateKey()
+		keys           = make([]*ecdsa.PrivateKey, 3)
+		signers        = make([]common.Address, 3)
+		creator        = crypto.PubkeyToAddress(creatorKey.PublicKey)
+		friend         = common.HexToAddress("0x0b")
+		payload        = []byte("charter")
+		record         = (&types.RecordConfirmation{Data: payload}).Key()
+		header         = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		signers[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	// Create a 2-of-3 account
+	create, _ := (&types.MultisigAccount{Signers: signers, Threshold: 2}).EncodeToBytes()
+	tx, _ := types.SignTx(types.NewTransaction(types.MultisigCreation, 0, common.Address{}, nil, nil, nil, create), rt.signer, creatorKey)
+	if receipt, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("multisig creation failed: %v", err)
+	}
+	account := types.MultisigAddress(creator, 0)
+	if have, threshold := rt.statedb.GetMultisig(account); threshold != 2 || fmt.Sprint(have) != fmt.Sprint(signers) {
+		t.Fatalf("multisig mismatch: signers %v, threshold %d", have, threshold)
+	}
+	// Creating an account over an existing one fails with the reason
+	rt.statedb.SetMultisig(types.MultisigAddress(creator, 1), signers[:1], 1)
+	tx, _ = types.SignTx(types.NewTransaction(types.MultisigCreation, 1, common.Address{}, nil, nil, nil, create), rt.signer, creatorKey)
+	if receipt, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx); err != nil || receipt.Failure != types.FailureMultisigExists {
+		t.Fatalf("existing multisig: receipt mismatch: %v, %v", receipt, err)
+	}
+	if _, threshold := rt.statedb.GetMultisig(types.MultisigAddress(creator, 1)); threshold != 1 {
+		t.Fatalf("existing multisig overwritten: threshold %d", threshold)
+	}
+	// Before the envelope fork multisig accounts can't be created
+	legacy := *rt.config
+	legacy.TxEnvelopeBlock = big.NewInt(2)
+	tx, _ = types.SignTx(types.NewTransaction(types.MultisigCreation, 2, common.Address{}, nil, nil, nil, create), rt.signer, creatorKey)
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb.Copy(), rt.statedbRecord.Copy(), header, tx); err != ErrTxTypeNotActive {
+		t.Fatalf("pre-fork creation: error mismatch: have %v, want %v", err, ErrTxTypeNotActive)
+	}
+
+	// send signs a transaction of the account with the given keys, the first
+	// one signing the transaction itself
+	send := func(txType types.TxType, to common.Address, signed ...*ecdsa.PrivateKey) error {
+		data := payload
+		if txType == types.ConfirmationData {
+			data = confirmation(payload, 0)
+		}
+		tx := types.NewTransaction(txType, rt.statedb.GetNonce(account), to, nil, nil, nil, data).WithMultisig(account)
+		tx, _ = types.SignTx(tx, rt.signer, signed[0])
+		for _, key := range signed[1:] {
+			tx, _ = types.SignMultisig(tx, rt.signer, key)
+		}
+		if from, err := types.Sender(rt.signer, tx); err == nil && from != account {
+			t.Fatalf("sender mismatch: have %x, want %x", from, account)
+		}
+		_, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx)
+		return err
+	}
+	if err := send(types.ConfirmationData, common.Address{}, keys[0], keys[1]); err != nil {
+		t.Fatalf("multisig confirmation failed: %v", err)
+	}
+	if owner := rt.statedbRecord.GetOwner(record); owner != account {
+		t.Fatalf("owner mismatch: have %x, want %x", owner, account)
+	}
+	// Transfers below the threshold or cosigned by outsiders are invalid
+	if err := send(types.TransferData, friend, keys[2]); err != ErrMultisigQuorum {
+		t.Fatalf("single signer: error mismatch: have %v, want %v", err, ErrMultisigQuorum)
+	}
+	if err := send(types.TransferData, friend, keys[2], outsiderKey); err != ErrUnknownSigner {
+		t.Fatalf("outsider cosigner: error mismatch: have %v, want %v", err, ErrUnknownSigner)
+	}
+	if err := send(types.TransferData, friend, keys[2], keys[2]); err != types.ErrDuplicateSigner {
+		t.Fatalf("repeated signer: error mismatch: have %v, want %v", err, types.ErrDuplicateSigner)
+	}
+	if owner := rt.statedbRecord.GetOwner(record); owner != account {
+		t.Fatalf("record transferred without quorum")
+	}
+	if err := send(types.TransferData, friend, keys[2], keys[0]); err != nil {
+		t.Fatalf("quorum transfer failed: %v", err)
+	}
+	if owner := rt.statedbRecord.GetOwner(record); owner != friend {
+		t.Fatalf("owner mismatch: have %x, want %x", owner, friend)
+	}
+	// Ordinary accounts cannot be spoofed with the multisig extension
+	spoof := types.NewTransaction(types.Binary, 0, friend, big.NewInt(1), nil, nil, nil).WithMultisig(creator)
+	spoof, _ = types.SignTx(spoof, rt.signer, keys[0])
+	if _, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, spoof); err != ErrNotMultisig {
+		t.Fatalf("spoofed account: error mismatch: have %v, want %v", err, ErrNotMultisig)
+	}
+}
+
+// Tests that data and poc transactions failing on the current state are
+// included with a failed receipt carrying the reason, while using up the fee
+// and nonce.
+func TestFailedTransactionReceipt(t *testing.T) {
+	rt := newRecordTester()
+	config := *rt.config
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(100)}
+	rt.config = &config
+
+	var (
+		key, _      = crypto.GenerateKey()
+		otherKey, _ = crypto.GenerateKey()
+		sender      = crypto.PubkeyToAddress(key.PublicKey)
+		other       = crypto.PubkeyToAddress(otherKey.PublicKey)
+		header      = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1), Validator: common.HexToAddress("0x0a")}
+	)
+	rt.statedb.AddBalance(sender, big.NewInt(1000))
+	rt.statedb.AddBalance(other, big.NewInt(1000))
+
+	apply := func(key *ecdsa.PrivateKey, txType types.TxType, to common.Address, payload string) *types.Receipt {
+		from := crypto.PubkeyToAddress(key.PublicKey)
+		data := []byte(payload)
+		if txType == types.ConfirmationData {
+			data = confirmation(data, 0)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(txType, rt.statedb.GetNonce(from), to, nil, nil, nil, data), rt.signer, key)
+		receipt, err := ApplyTransaction(rt.config, rt.pocContext, nil, nil, rt.statedb, rt.statedbRecord, header, tx)
+		if err != nil {
+			t.Fatalf("transaction type %d not included: %v", txType, err)
+		}
+		return receipt
+	}
+	if receipt := apply(key, types.ConfirmationData, common.Address{}, "document"); receipt.Failed() {
+		t.Fatalf("confirmation failed: %v", receipt.Failure)
+	}
+	tests := []struct {
+		key     *ecdsa.PrivateKey
+		txType  types.TxType
+		to      common.Address
+		payload string
+		failure types.FailureCode
+	}{
+		{key, types.ConfirmationData, common.Address{}, "document", types.FailureDuplicateRecord},
+		{key, types.TransferData, other, "missing", types.FailureUnknownRecord},
+		{otherKey, types.TransferData, sender, "document", types.FailureNotOwner},
+		{otherKey, types.TransferOffer, sender, "document", types.FailureNotOwner},
+		{otherKey, types.TransferAccept, common.Address{}, "document", types.FailureNoTransferOffer},
+		{key, types.RecordRenewal, common.Address{}, "document", types.FailureInvalidExpiry},
+		{key, types.LogoutCandidate, common.Address{}, "", types.FailureNotCandidate},
+	}
+	for i, test := range tests {
+		from := crypto.PubkeyToAddress(test.key.PublicKey)
+		nonce, balance := rt.statedb.GetNonce(from), rt.statedb.GetBalance(from)
+		root := rt.statedbRecord.IntermediateRoot(false)
+
+		receipt := apply(test.key, test.txType, test.to, test.payload)
+		if !receipt.Failed() || receipt.Status != types.ReceiptStatusFailed || receipt.Failure != test.failure {
+			t.Errorf("test %d: receipt mismatch: status %d, failure %v, want %v", i, receipt.Status, receipt.Failure, test.failure)
+		}
+		if have := rt.statedbRecord.IntermediateRoot(false); have != root {
+			t.Errorf("test %d: record state changed by failed transaction", i)
+		}
+		if len(receipt.Logs) != 0 {
+			t.Errorf("test %d: failed transaction left logs: %v", i, receipt.Logs)
+		}
+		if have := rt.statedb.GetNonce(from); have != nonce+1 {
+			t.Errorf("test %d: nonce mismatch: have %d, want %d", i, have, nonce+1)
+		}
+		if have, want := rt.statedb.GetBalance(from), new(big.Int).Sub(balance, big.NewInt(100)); have.Cmp(want) != 0 {
+			t.Errorf("test %d: balance mismatch: have %v, want %v", i, have, want)
+		}
+	}
+	if owner := rt.statedbRecord.GetOwner((&types.RecordConfirmation{Data: []byte("document")}).Key()); owner != sender {
+		t.Fatalf("owner changed by failed transactions: %x", owner)
+	}
+	// Before the fork the failing transaction is rejected instead
+	legacy := config
+	legacy.FailedTxBlock = big.NewInt(2)
+	tx, _ := types.SignTx(types.NewTransaction(types.ConfirmationData, rt.statedb.GetNonce(sender), common.Address{}, nil, nil, nil, confirmation([]byte("document"), 0)), rt.signer, key)
+	if _, err := ApplyTransaction(&legacy, rt.pocContext, nil, nil, rt.statedb.Copy(), rt.statedbRecord.Copy(), header, tx); err != ErrRecordExists {
+		t.Fatalf("pre-fork failure: error mismatch: have %v, want %v", err, ErrRecordExists)
+	}
+	// The reason survives storage even without a status
+	receipt := types.NewReceipt(make([]byte, 32), nil, true)
+	receipt.Failure, receipt.GasUsed = types.FailureRecordFrozen, new(big.Int)
+	blob, _ := rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+	stored := new(types.ReceiptForStorage)
+	if err := rlp.DecodeBytes(blob, stored); err != nil || !(*types.Receipt)(stored).Failed() || stored.Failure != types.FailureRecordFrozen {
+		t.Fatalf("failure lost in storage: %v, %v", err, stored.Failure)
+	}
+}
+
+// Tests that unsigned transactions can be dry run against copies of the
+// states, reporting the receipt they would get.
+func TestSimulateTransaction(t *testing.T) {
+	rt := newRecordTester()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		sponsor = common.HexToAddress("0x0b")
+		header  = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+		record  = (&types.RecordConfirmation{Data: []byte("document")}).Key()
+	)
+	simulate := func(tx *types.Transaction) (*types.Receipt, *state.StateDB, *state.StateDBRecord) {
+		statedb, statedbRecord := rt.statedb.Copy(), rt.statedbRecord.Copy()
+		receipt, err := SimulateTransaction(rt.config, rt.pocContext.Copy(), statedb, statedbRecord, header, tx, sender)
+		if err != nil {
+			t.Fatalf("simulation failed: %v", err)
+		}
+		return receipt, statedb, statedbRecord
+	}
+	tx := types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, nil, confirmation([]byte("document"), 0))
+	receipt, statedb, statedbRecord := simulate(tx)
+	if receipt.Failed() || len(receipt.Logs) != 1 || receipt.Logs[0].Topics[0] != RecordConfirmedTopic {
+		t.Fatalf("receipt mismatch: failure %v, logs %v", receipt.Failure, receipt.Logs)
+	}
+	if statedbRecord.GetOwner(record) != sender || statedb.GetContribution(sender).Sign() <= 0 {
+		t.Fatalf("simulated state not updated")
+	}
+	if rt.statedbRecord.Exist(record) || rt.statedb.GetNonce(sender) != 0 {
+		t.Fatalf("simulation modified the original state")
+	}
+	// Once registered the same document would fail
+	if !rt.apply(key, types.ConfirmationData, common.Address{}, nil, confirmation([]byte("document"), 0)) {
+		t.Fatalf("confirmation failed")
+	}
+	receipt, _, _ = simulate(types.NewTransaction(types.ConfirmationData, 1, common.Address{}, nil, nil, nil, confirmation([]byte("document"), 0)))
+	if !receipt.Failed() || receipt.Failure != types.FailureDuplicateRecord {
+		t.Fatalf("failure mismatch: have %v, want %v", receipt.Failure, types.FailureDuplicateRecord)
+	}
+	// Transactions that could not be included at all are reported as errors
+	if _, err := SimulateTransaction(rt.config, rt.pocContext.Copy(), rt.statedb.Copy(), rt.statedbRecord.Copy(), header, tx, sender); err == nil {
+		t.Fatalf("simulation with a used nonce succeeded")
+	}
+	// A declared sponsor pays the fee of the simulated transaction
+	if msg := tx.WithSponsor(sponsor).AsSimulatedMessage(sender); msg.From() != sender || msg.Payer() != sponsor {
+		t.Fatalf("simulated message mismatch: from %x, payer %x", msg.From(), msg.Payer())
+	}
+	// Multisig transactions are checked against the account, the signatures
+	// still missing are left to the caller to report
+	account := common.HexToAddress("0x0c")
+	multisig := types.NewTransaction(types.Binary, 0, sponsor, big.NewInt(0), nil, nil, nil).WithMultisig(account)
+	if _, err := SimulateTransaction(rt.config, rt.pocContext.Copy(), rt.statedb.Copy(), rt.statedbRecord.Copy(), header, multisig, account); err != ErrNotMultisig {
+		t.Fatalf("ordinary account: error mismatch: have %v, want %v", err, ErrNotMultisig)
+	}
+	otherKey, _ := crypto.GenerateKey()
+	rt.statedb.SetMultisig(account, []common.Address{sender, crypto.PubkeyToAddress(otherKey.PublicKey)}, 2)
+	if missing, err := MultisigQuorum(rt.statedb, rt.signer, multisig); err != nil || missing != 2 {
+		t.Fatalf("unsigned quorum mismatch: have %d, %v, want 2", missing, err)
+	}
+	signed, _ := types.SignTx(multisig, rt.signer, key)
+	if missing, err := MultisigQuorum(rt.statedb, rt.signer, signed); err != nil || missing != 1 {
+		t.Fatalf("partial quorum mismatch: have %d, %v, want 1", missing, err)
+	}
+	strangerKey, _ := crypto.GenerateKey()
+	stranger, _ := types.SignTx(multisig, rt.signer, strangerKey)
+	if _, err := SimulateTransaction(rt.config, rt.pocContext.Copy(), rt.statedb.Copy(), rt.statedbRecord.Copy(), header, stranger, account); err != ErrUnknownSigner {
+		t.Fatalf("foreign signer: error mismatch: have %v, want %v", err, ErrUnknownSigner)
+	}
+	if receipt, err := SimulateTransaction(rt.config, rt.pocContext.Copy(), rt.statedb.Copy(), rt.statedbRecord.Copy(), header, multisig, account); err != nil || receipt.Failed() {
+		t.Fatalf("multisig simulation failed: %v", err)
+	}
+}
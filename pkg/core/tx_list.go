@@ -222,15 +222,18 @@ type txList struct {
 	strict bool         // Whether nonces are strictly continuous or not
 	txs    *txSortedMap // Heap indexed sorted hash map of the transactions
 
-	costcap *big.Int // Price of the highest costing transaction (reset only if exceeds balance)
-	gascap  *big.Int // Gas limit of the highest spending transaction (reset only if exceeds block limit)
+	cost    func(*types.Transaction) *big.Int // Cost of a transaction to the balance of the sender
+	costcap *big.Int                          // Price of the highest costing transaction (reset only if exceeds balance)
+	gascap  *big.Int                          // Gas limit of the highest spending transaction (reset only if exceeds block limit)
 }
 
 // newTxList create a new transaction list for maintaining nonce-indexable fast,
-// gapped, sortable transaction lists.
-func newTxList(strict bool) *txList {
+// gapped, sortable transaction lists, measuring the balance a transaction
+// needs with cost.
+func newTxList(strict bool, cost func(*types.Transaction) *big.Int) *txList {
 	return &txList{
 		strict:  strict,
+		cost:    cost,
 		txs:     newTxSortedMap(),
 		costcap: new(big.Int),
 		gascap:  new(big.Int),
@@ -262,7 +265,7 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
-	if cost := tx.Cost(); l.costcap.Cmp(cost) < 0 {
+	if cost := l.cost(tx); l.costcap.Cmp(cost) < 0 {
 		l.costcap = cost
 	}
 	if gas := tx.Gas(); l.gascap.Cmp(gas) < 0 {
@@ -296,7 +299,7 @@ func (l *txList) Filter(costLimit, gasLimit *big.Int) (types.Transactions, types
 	l.gascap = new(big.Int).Set(gasLimit)
 
 	// Filter out all the transactions above the account's funds
-	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas().Cmp(gasLimit) > 0 })
+	removed := l.txs.Filter(func(tx *types.Transaction) bool { return l.cost(tx).Cmp(costLimit) > 0 || tx.Gas().Cmp(gasLimit) > 0 })
 
 	// If the list was strict, filter anything above the lowest nonce
 	var invalids types.Transactions
@@ -363,16 +366,23 @@ func (l *txList) Flatten() types.Transactions {
 	return l.txs.Flatten()
 }
 
+// pricedTx is a transaction of the priced list with its price, computed once
+// when the transaction entered the heap as the price may read the state.
+type pricedTx struct {
+	tx    *types.Transaction
+	price *big.Int
+}
+
 // priceHeap is a heap.Interface implementation over transactions for retrieving
 // price-sorted transactions to discard when the pool fills up.
-type priceHeap []*types.Transaction
+type priceHeap []pricedTx
 
 func (h priceHeap) Len() int           { return len(h) }
-func (h priceHeap) Less(i, j int) bool { return h[i].GasPrice().Cmp(h[j].GasPrice()) < 0 }
+func (h priceHeap) Less(i, j int) bool { return h[i].price.Cmp(h[j].price) < 0 }
 func (h priceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
 
 func (h *priceHeap) Push(x interface{}) {
-	*h = append(*h, x.(*types.Transaction))
+	*h = append(*h, x.(pricedTx))
 }
 
 func (h *priceHeap) Pop() interface{} {
@@ -387,21 +397,23 @@ func (h *priceHeap) Pop() interface{} {
 // contents in a price-incrementing way.
 type txPricedList struct {
 	all    *map[common.Hash]*types.Transaction // Pointer to the map of all transactions
+	price  func(*types.Transaction) *big.Int   // Price transactions are sorted by
 	items  *priceHeap                          // Heap of prices of all the stored transactions
 	stales int                                 // Number of stale price points to (re-heap trigger)
 }
 
-// newTxPricedList creates a new price-sorted transaction heap.
-func newTxPricedList(all *map[common.Hash]*types.Transaction) *txPricedList {
+// newTxPricedList creates a new transaction heap sorted by the given price.
+func newTxPricedList(all *map[common.Hash]*types.Transaction, price func(*types.Transaction) *big.Int) *txPricedList {
 	return &txPricedList{
 		all:   all,
+		price: price,
 		items: new(priceHeap),
 	}
 }
 
 // Put inserts a new transaction into the heap.
 func (l *txPricedList) Put(tx *types.Transaction) {
-	heap.Push(l.items, tx)
+	heap.Push(l.items, pricedTx{tx, l.price(tx)})
 }
 
 // Removed notifies the prices transaction list that an old transaction dropped
@@ -414,11 +426,17 @@ func (l *txPricedList) Removed() {
 		return
 	}
 	// Seems we've reached a critical number of stale transactions, reheap
+	l.Reheap()
+}
+
+// Reheap rebuilds the heap from all the transactions of the pool, dropping the
+// stale ones and pricing the rest anew after their price changed.
+func (l *txPricedList) Reheap() {
 	reheap := make(priceHeap, 0, len(*l.all))
 
 	l.stales, l.items = 0, &reheap
 	for _, tx := range *l.all {
-		*l.items = append(*l.items, tx)
+		*l.items = append(*l.items, pricedTx{tx, l.price(tx)})
 	}
 	heap.Init(l.items)
 }
@@ -427,29 +445,29 @@ func (l *txPricedList) Removed() {
 // from the priced list and returs them for further removal from the entire pool.
 func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, 128) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)  // Local underpriced transactions to keep
+	save := make(priceHeap, 0, 64)           // Local underpriced transactions to keep
 
 	for len(*l.items) > 0 {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if _, ok := (*l.all)[tx.Hash()]; !ok {
+		item := heap.Pop(l.items).(pricedTx)
+		if _, ok := (*l.all)[item.tx.Hash()]; !ok {
 			l.stales--
 			continue
 		}
 		// Stop the discards if we've reached the threshold
-		if tx.GasPrice().Cmp(threshold) >= 0 {
-			save = append(save, tx)
+		if item.price.Cmp(threshold) >= 0 {
+			save = append(save, item)
 			break
 		}
 		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
+		if local.containsTx(item.tx) {
+			save = append(save, item)
 		} else {
-			drop = append(drop, tx)
+			drop = append(drop, item.tx)
 		}
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
+	for _, item := range save {
+		heap.Push(l.items, item)
 	}
 	return drop
 }
@@ -463,8 +481,8 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 	}
 	// Discard stale price points if found at the heap start
 	for len(*l.items) > 0 {
-		head := []*types.Transaction(*l.items)[0]
-		if _, ok := (*l.all)[head.Hash()]; !ok {
+		head := (*l.items)[0]
+		if _, ok := (*l.all)[head.tx.Hash()]; !ok {
 			l.stales--
 			heap.Pop(l.items)
 			continue
@@ -476,33 +494,33 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
 		return false
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	cheapest := (*l.items)[0]
+	return cheapest.price.Cmp(l.price(tx)) >= 0
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
 // priced list and returns them for further removal from the entire pool.
 func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
+	save := make(priceHeap, 0, 64)             // Local underpriced transactions to keep
 
 	for len(*l.items) > 0 && count > 0 {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if _, ok := (*l.all)[tx.Hash()]; !ok {
+		item := heap.Pop(l.items).(pricedTx)
+		if _, ok := (*l.all)[item.tx.Hash()]; !ok {
 			l.stales--
 			continue
 		}
 		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
+		if local.containsTx(item.tx) {
+			save = append(save, item)
 		} else {
-			drop = append(drop, tx)
+			drop = append(drop, item.tx)
 			count--
 		}
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
+	for _, item := range save {
+		heap.Push(l.items, item)
 	}
 	return drop
 }
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/crypto"
 	"math/big"
@@ -35,7 +36,7 @@ func TestStrictTxListAdd(t *testing.T) {
 		txs[i] = transaction(uint64(i), new(big.Int), key)
 	}
 	// Insert the transactions in a random order
-	list := newTxList(true)
+	list := newTxList(true, (*types.Transaction).Cost)
 	for _, v := range rand.Perm(len(txs)) {
 		list.Add(txs[v], DefaultTxPoolConfig.PriceBump)
 	}
@@ -49,3 +50,37 @@ func TestStrictTxListAdd(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the priced list prices every transaction once when it enters the
+// heap instead of on every comparison.
+func TestTxPricedListCachesPrices(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	all := make(map[common.Hash]*types.Transaction)
+	calls := 0
+	list := newTxPricedList(&all, func(tx *types.Transaction) *big.Int {
+		calls++
+		return tx.GasPrice()
+	})
+	for i := 0; i < 64; i++ {
+		tx := pricedTransaction(uint64(i), new(big.Int), big.NewInt(int64(rand.Intn(1000))), key)
+		all[tx.Hash()] = tx
+		list.Put(tx)
+	}
+	if calls != len(all) {
+		t.Fatalf("price calls mismatch after insertion: have %d, want %d", calls, len(all))
+	}
+	drop := list.Discard(16, newAccountSet(types.HomesteadSigner{}))
+	for i := 1; i < len(drop); i++ {
+		if drop[i-1].GasPrice().Cmp(drop[i].GasPrice()) > 0 {
+			t.Fatalf("transaction %d discarded out of price order", i)
+		}
+	}
+	if calls != len(all) {
+		t.Fatalf("price calls mismatch after discarding: have %d, want %d", calls, len(all))
+	}
+	list.Reheap()
+	if calls != 2*len(all) {
+		t.Fatalf("price calls mismatch after reheap: have %d, want %d", calls, 2*len(all))
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"math/big"
+	"time"
+)
+
+// TxPolicy orders transactions for block inclusion and for eviction from a
+// full pool. Gas prices mean nothing on this chain, so the policy decides what
+// is worth including first and what is dropped last.
+type TxPolicy interface {
+	// Priority returns the priority of a transaction sent by from. Higher
+	// priorities are included first and evicted last.
+	Priority(tx *types.Transaction, from common.Address) *big.Int
+}
+
+// defaultTxPolicy weighs the fee of a transaction per kilobyte of block space
+// it takes by the contribution of its sender and by the time it has been
+// waiting. Blocks are bounded by their size rather than gas, so a large
+// transaction paying a high absolute fee should not crowd out several small
+// ones paying more in total.
+type defaultTxPolicy struct {
+	config   *params.ChainConfig
+	number   *big.Int
+	statedb  *state.StateDB
+	arrivals func(common.Hash) time.Time
+	now      func() time.Time
+}
+
+// NewDefaultTxPolicy returns the default policy for transactions of block
+// number, reading contributions from statedb and the time a transaction was
+// first seen from arrivals. Before the fee fork the gas price stands in for
+// the fee.
+func NewDefaultTxPolicy(config *params.ChainConfig, number *big.Int, statedb *state.StateDB, arrivals func(common.Hash) time.Time) TxPolicy {
+	return &defaultTxPolicy{
+		config:   config,
+		number:   number,
+		statedb:  statedb,
+		arrivals: arrivals,
+		now:      time.Now,
+	}
+}
+
+// Priority implements TxPolicy. The fee per kilobyte is raised by one percent
+// per unit of contribution of the sender and one percent per period waited,
+// each capped.
+func (p *defaultTxPolicy) Priority(tx *types.Transaction, from common.Address) *big.Int {
+	base := tx.GasPrice()
+	if p.config.IsFee(p.number) {
+		base = new(big.Int).Mul(tx.Fee(p.config, p.number), big.NewInt(1024))
+		base.Div(base, big.NewInt(int64(tx.Size())))
+	}
+	// 贡献值每达到一个单位提高 1%, 最多提高 TxPriorityContributionCap %
+	boost := new(big.Int).Div(p.statedb.GetContribution(from), new(big.Int).SetUint64(params.TxPriorityContributionUnit))
+	if boost.Cmp(new(big.Int).SetUint64(params.TxPriorityContributionCap)) > 0 {
+		boost.SetUint64(params.TxPriorityContributionCap)
+	}
+	// 等待时间每满一个周期提高 1%, 最多提高 TxPriorityAgeCap %
+	if arrived := p.arrivals(tx.Hash()); !arrived.IsZero() && p.now().After(arrived) {
+		periods := uint64(p.now().Sub(arrived)/time.Second) / params.TxPriorityAgePeriod
+		if periods > params.TxPriorityAgeCap {
+			periods = params.TxPriorityAgeCap
+		}
+		boost.Add(boost, new(big.Int).SetUint64(periods))
+	}
+	priority := new(big.Int).Mul(base, boost.Add(boost, big.NewInt(100)))
+	return priority.Div(priority, big.NewInt(100))
+}
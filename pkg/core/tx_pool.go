@@ -45,6 +45,10 @@ var (
 	// ErrInvalidSender is returned if the transaction contains an invalid signature.
 	ErrInvalidSender = errors.New("invalid sender")
 
+	// ErrInvalidSponsor is returned if a sponsored transaction lacks a valid
+	// signature of its sponsor.
+	ErrInvalidSponsor = errors.New("invalid sponsor")
+
 	// ErrNonceTooLow is returned if the nonce of a transaction is lower than the
 	// one present in the local chain.
 	ErrNonceTooLow = errors.New("nonce too low")
@@ -100,6 +104,8 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	StateRecordAt(root common.Hash) (*state.StateDBRecord, error)
+	PocContextAt(header *types.Header) (*types.PocContext, error)
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -176,18 +182,25 @@ type TxPool struct {
 	signer       types.Signer
 	mu           sync.RWMutex
 
-	currentState  *state.StateDB      // Current state in the blockchain head
-	pendingState  *state.ManagedState // Pending state tracking virtual nonces
-	currentMaxGas *big.Int            // Current gas limit for transaction caps
+	currentState  *state.StateDB       // Current state in the blockchain head
+	currentRecord *state.StateDBRecord // Current record state in the blockchain head
+	currentPoc    *types.PocContext    // Current poc context in the blockchain head
+	currentNumber *big.Int             // Number of the block the pool is validating for
+	currentTime   *big.Int             // Timestamp of the blockchain head
+	policy        TxPolicy             // Ordering policy for evicting transactions
+	pendingState  *state.ManagedState  // Pending state tracking virtual nonces
+	currentMaxGas *big.Int             // Current gas limit for transaction caps
 
 	locals  *accountSet // Set of local transaction to exepmt from evicion rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
-	pending map[common.Address]*txList         // All currently processable transactions
-	queue   map[common.Address]*txList         // Queued but non-processable transactions
-	beats   map[common.Address]time.Time       // Last heartbeat from each known account
-	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
-	priced  *txPricedList                      // All transactions sorted by price
+	pending   map[common.Address]*txList         // All currently processable transactions
+	queue     map[common.Address]*txList         // Queued but non-processable transactions
+	beats     map[common.Address]time.Time       // Last heartbeat from each known account
+	all       map[common.Hash]*types.Transaction // All transactions to allow lookups
+	arrivals  map[common.Hash]time.Time          // Time each transaction was first seen
+	sponsored map[common.Address]*big.Int        // Fees each sponsor has committed to for the pooled transactions
+	priced    *txPricedList                      // All transactions sorted by priority
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -210,11 +223,13 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		arrivals:    make(map[common.Hash]time.Time),
+		sponsored:   make(map[common.Address]*big.Int),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
-	pool.priced = newTxPricedList(&pool.all)
+	pool.priced = newTxPricedList(&pool.all, pool.txPriority)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// If local transactions and journaling is enabled, load from disk
@@ -388,7 +403,31 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 		log.Error("Failed to reset txpool state", "err", err)
 		return
 	}
+	statedbRecord, err := pool.chain.StateRecordAt(newHead.RecordRoot)
+	if err != nil {
+		log.Error("Failed to reset txpool record state", "err", err)
+		return
+	}
+	pocContext, err := pool.chain.PocContextAt(newHead)
+	if err != nil {
+		log.Error("Failed to reset txpool poc context", "err", err)
+		return
+	}
 	pool.currentState = statedb
+	pool.currentRecord = statedbRecord
+	pool.currentPoc = pocContext
+	pool.currentNumber = new(big.Int).Add(newHead.Number, common.Big1)
+	pool.currentTime = new(big.Int).Set(newHead.Time)
+	pool.policy = NewDefaultTxPolicy(pool.chainconfig, pool.currentNumber, statedb, pool.arrival)
+
+	// 新区块改变了发送者的贡献值, 交易已等待的时间也在增加, 按新的优先级重新排序
+	for hash := range pool.arrivals {
+		if pool.all[hash] == nil {
+			delete(pool.arrivals, hash)
+		}
+	}
+	pool.priced.Reheap()
+	pool.resetSponsored()
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
@@ -396,6 +435,12 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	pool.addTxsLocked(reinject, false)
 
+	// 有效期已过的交易不可能再被打包, 每个新区块都清理一次
+	pool.dropExpired()
+
+	// 新区块可能花掉了赞助者的余额, 付不起的赞助交易不再保留
+	pool.dropUnfundedSponsored()
+
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
 	// have been invalidated because of another transaction (e.g.
@@ -448,8 +493,11 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	defer pool.mu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price, pool.locals) {
-		pool.removeTx(tx.Hash())
+	// 手续费生效后交易按手续费排序, 最低 gas 价格不再适用
+	if !pool.chainconfig.IsFee(pool.currentNumber) {
+		for _, tx := range pool.priced.Cap(price, pool.locals) {
+			pool.removeTx(tx.Hash())
+		}
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
@@ -555,22 +603,162 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
-	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	if !local && !pool.chainconfig.IsFee(pool.currentNumber) && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		return ErrUnderpriced
 	}
+	// 分叉新增的交易类型在分叉前不能打包
+	if err := checkTxType(pool.chainconfig, tx.Type(), pool.currentNumber); err != nil {
+		return err
+	}
+	// 信封扩展 (有效期, 赞助者, 多签) 从分叉起才能打包
+	if tx.Extended() && !pool.chainconfig.IsTxEnvelope(pool.currentNumber) {
+		return ErrTxEnvelopeNotActive
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
 	}
 	// Transactor should have enough funds to cover the costs
-	// cost == V + GP * GL
-	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
+	// cost == fee + spent value, or V + GP * GL before the fee fork
+	if pool.currentState.GetBalance(from).Cmp(pool.txCost(tx)) < 0 {
 		return ErrInsufficientFunds
 	}
+	// 赞助交易的手续费由赞助者支付, 检查赞助者的签名和余额
+	if tx.Sponsored() {
+		sponsor, err := types.Sponsor(tx)
+		if err != nil {
+			return ErrInvalidSponsor
+		}
+		// 赞助者要付清池中所有由其赞助的交易, 被替换的交易除外
+		cost := new(big.Int).Add(pool.sponsoredCost(sponsor, tx), tx.Fee(pool.chainconfig, pool.currentNumber))
+		if pool.currentState.GetBalance(sponsor).Cmp(cost) < 0 {
+			return ErrInsufficientFunds
+		}
+	}
+	// 多签账户发送的交易需要达到门限数量的签名者签名
+	if err := checkMultisig(pool.currentState, pool.signer, tx); err != nil {
+		return err
+	}
+	// 有效期已过的交易不再接收, 尚未生效的交易留在池中等待
+	if w := tx.Window(); w != nil && w.Expired(pool.currentNumber, pool.currentTime) {
+		return ErrTxExpired
+	}
+	// 按交易类型检查数据和候选者交易能否在发送者前面的交易之后执行
+	if err := pool.pendingTypedStateAt(from, tx.Nonce()).validate(tx); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// txCost returns the balance a transaction needs from its sender in the block
+// the pool validates for.
+func (pool *TxPool) txCost(tx *types.Transaction) *big.Int {
+	from, _ := types.Sender(pool.signer, tx)
+	return TxCost(pool.chainconfig, pool.currentNumber, tx, from)
+}
+
+// txPriority returns the priority transactions are evicted by when the pool
+// fills up.
+func (pool *TxPool) txPriority(tx *types.Transaction) *big.Int {
+	if pool.policy == nil {
+		return tx.GasPrice()
+	}
+	from, _ := types.Sender(pool.signer, tx)
+	return pool.policy.Priority(tx, from)
+}
+
+// sponsoredCost returns the fees sponsor has committed to pay for the pooled
+// transactions it sponsors, leaving out the one tx would replace.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) sponsoredCost(sponsor common.Address, tx *types.Transaction) *big.Int {
+	cost := new(big.Int)
+	if total := pool.sponsored[sponsor]; total != nil {
+		cost.Set(total)
+	}
+	from, _ := types.Sender(pool.signer, tx)
+	for _, list := range []*txList{pool.pending[from], pool.queue[from]} {
+		if list == nil {
+			continue
+		}
+		if old := list.txs.Get(tx.Nonce()); old != nil && old.Sponsored() {
+			if addr, err := types.Sponsor(old); err == nil && addr == sponsor {
+				cost.Sub(cost, old.Fee(pool.chainconfig, pool.currentNumber))
+			}
+		}
+	}
+	return cost
+}
+
+// addSponsored adds the fee of a transaction entering the pool to the total of
+// its sponsor.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) addSponsored(tx *types.Transaction) {
+	if !tx.Sponsored() {
+		return
+	}
+	sponsor, err := types.Sponsor(tx)
+	if err != nil {
+		return
+	}
+	total := pool.sponsored[sponsor]
+	if total == nil {
+		total = new(big.Int)
+		pool.sponsored[sponsor] = total
+	}
+	total.Add(total, tx.Fee(pool.chainconfig, pool.currentNumber))
+}
+
+// removeSponsored takes the fee of a transaction leaving the pool off the total
+// of its sponsor.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) removeSponsored(tx *types.Transaction) {
+	if !tx.Sponsored() {
+		return
+	}
+	sponsor, err := types.Sponsor(tx)
+	if err != nil {
+		return
+	}
+	total := pool.sponsored[sponsor]
+	if total == nil {
+		return
+	}
+	if total.Sub(total, tx.Fee(pool.chainconfig, pool.currentNumber)); total.Sign() <= 0 {
+		delete(pool.sponsored, sponsor)
+	}
+}
+
+// resetSponsored recomputes the totals of the sponsors, as the fees of the
+// pooled transactions may change with the block they are validated for.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) resetSponsored() {
+	pool.sponsored = make(map[common.Address]*big.Int)
+	for _, tx := range pool.all {
+		pool.addSponsored(tx)
+	}
+}
+
+// arrival returns the time a transaction was first seen by the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) arrival(hash common.Hash) time.Time {
+	return pool.arrivals[hash]
+}
+
+// Arrival returns the time a transaction was first seen by the pool, or the
+// zero time if it is not pooled.
+func (pool *TxPool) Arrival(hash common.Hash) time.Time {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.arrivals[hash]
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -617,9 +805,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		if old != nil {
 			delete(pool.all, old.Hash())
 			pool.priced.Removed()
+			pool.removeSponsored(old)
 		}
 		pool.all[tx.Hash()] = tx
+		pool.arrivals[tx.Hash()] = time.Now()
 		pool.priced.Put(tx)
+		pool.addSponsored(tx)
 		pool.journalTx(from, tx)
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -651,7 +842,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	// Try to insert the transaction into the future queue
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if pool.queue[from] == nil {
-		pool.queue[from] = newTxList(false)
+		pool.queue[from] = newTxList(false, pool.txCost)
 	}
 	inserted, old := pool.queue[from].Add(tx, pool.config.PriceBump)
 	if !inserted {
@@ -662,8 +853,15 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	if old != nil {
 		delete(pool.all, old.Hash())
 		pool.priced.Removed()
+		pool.removeSponsored(old)
+	}
+	if pool.all[hash] == nil {
+		pool.addSponsored(tx)
 	}
 	pool.all[hash] = tx
+	if _, ok := pool.arrivals[hash]; !ok {
+		pool.arrivals[hash] = time.Now()
+	}
 	pool.priced.Put(tx)
 	return old != nil, nil
 }
@@ -686,7 +884,7 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.Transaction) {
 	// Try to insert the transaction into the pending queue
 	if pool.pending[addr] == nil {
-		pool.pending[addr] = newTxList(true)
+		pool.pending[addr] = newTxList(true, pool.txCost)
 	}
 	list := pool.pending[addr]
 
@@ -695,6 +893,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		// An older transaction was better, discard this
 		delete(pool.all, hash)
 		pool.priced.Removed()
+		pool.removeSponsored(tx)
 
 		return
 	}
@@ -702,12 +901,14 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if old != nil {
 		delete(pool.all, old.Hash())
 		pool.priced.Removed()
+		pool.removeSponsored(old)
 
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all[hash] == nil {
 		pool.all[hash] = tx
 		pool.priced.Put(tx)
+		pool.addSponsored(tx)
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
@@ -839,6 +1040,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	// Remove it from the list of known transactions
 	delete(pool.all, hash)
 	pool.priced.Removed()
+	pool.removeSponsored(tx)
 
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
@@ -869,6 +1071,49 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	}
 }
 
+// dropExpired removes all transactions whose validity window has closed before
+// the block the pool is validating for.
+func (pool *TxPool) dropExpired() {
+	for hash, tx := range pool.all {
+		if w := tx.Window(); w != nil && w.Expired(pool.currentNumber, pool.currentTime) {
+			log.Trace("Removed expired transaction", "hash", hash)
+			pool.removeTx(hash)
+		}
+	}
+}
+
+// dropUnfundedSponsored removes the sponsored transactions whose sponsors can
+// no longer pay the fees of all the transactions they sponsor in the pool,
+// keeping the ones of the highest priority that the balance covers.
+func (pool *TxPool) dropUnfundedSponsored() {
+	sponsored := make(map[common.Address]types.Transactions)
+	for _, tx := range pool.all {
+		if !tx.Sponsored() {
+			continue
+		}
+		sponsor, err := types.Sponsor(tx)
+		if err != nil {
+			continue
+		}
+		sponsored[sponsor] = append(sponsored[sponsor], tx)
+	}
+	for sponsor, txs := range sponsored {
+		sort.Slice(txs, func(i, j int) bool {
+			return pool.txPriority(txs[i]).Cmp(pool.txPriority(txs[j])) > 0
+		})
+		balance := new(big.Int).Set(pool.currentState.GetBalance(sponsor))
+		for _, tx := range txs {
+			fee := tx.Fee(pool.chainconfig, pool.currentNumber)
+			if balance.Cmp(fee) < 0 {
+				log.Trace("Removed unfunded sponsored transaction", "hash", tx.Hash(), "sponsor", sponsor)
+				pool.removeTx(tx.Hash())
+				continue
+			}
+			balance.Sub(balance, fee)
+		}
+	}
+}
+
 // promoteExecutables moves transactions that have become processable from the
 // future queue to the set of pending transactions. During this process, all
 // invalidated transactions (low nonce, low balance) are deleted.
@@ -892,6 +1137,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			log.Trace("Removed old queued transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.removeSponsored(tx)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -900,10 +1146,25 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.removeSponsored(tx)
 		}
-		// Gather all executable transactions and promote them
-		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
+		// Gather all executable transactions and promote them, dropping the first
+		// one that fails its typed checks after the transactions before it
+		typed := pool.pendingTypedStateAt(addr, pool.pendingState.GetNonce(addr))
+		ready := list.Ready(pool.pendingState.GetNonce(addr))
+		for i, tx := range ready {
 			hash := tx.Hash()
+			if err := typed.validate(tx); err != nil {
+				log.Trace("Removed invalidated queued transaction", "hash", hash, "err", err)
+				delete(pool.all, hash)
+				pool.priced.Removed()
+				pool.removeSponsored(tx)
+				for _, tx := range ready[i+1:] {
+					list.Add(tx, pool.config.PriceBump)
+				}
+				break
+			}
+			typed.apply(tx)
 			log.Trace("Promoting queued transaction", "hash", hash)
 			pool.promoteTx(addr, hash, tx)
 		}
@@ -913,6 +1174,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				hash := tx.Hash()
 				delete(pool.all, hash)
 				pool.priced.Removed()
+				pool.removeSponsored(tx)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -956,6 +1218,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							hash := tx.Hash()
 							delete(pool.all, hash)
 							pool.priced.Removed()
+							pool.removeSponsored(tx)
 
 							// Update the account nonce to the dropped transaction
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
@@ -978,6 +1241,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 						hash := tx.Hash()
 						delete(pool.all, hash)
 						pool.priced.Removed()
+						pool.removeSponsored(tx)
 
 						// Update the account nonce to the dropped transaction
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -1044,6 +1308,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.removeSponsored(tx)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1052,6 +1317,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.removeSponsored(tx)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
@@ -1066,6 +1332,27 @@ func (pool *TxPool) demoteUnexecutables() {
 				pool.enqueueTx(hash, tx)
 			}
 		}
+		// The record state and the poc context of the new head may invalidate data
+		// and candidate transactions, drop the first one failing its typed checks
+		typed := pool.pendingTypedState(addr)
+		for _, tx := range list.Flatten() {
+			if err := typed.validate(tx); err != nil {
+				hash := tx.Hash()
+				log.Trace("Removed invalidated pending transaction", "hash", hash, "err", err)
+				delete(pool.all, hash)
+				pool.priced.Removed()
+				pool.removeSponsored(tx)
+
+				_, invalids := list.Remove(tx)
+				for _, tx := range invalids {
+					hash := tx.Hash()
+					log.Trace("Demoting pending transaction", "hash", hash)
+					pool.enqueueTx(hash, tx)
+				}
+				break
+			}
+			typed.apply(tx)
+		}
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
 			delete(pool.pending, addr)
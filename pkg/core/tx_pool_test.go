@@ -24,6 +24,7 @@ import (
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/event"
 	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
 	"crypto/ecdsa"
 	"fmt"
 	"io/ioutil"
@@ -63,6 +64,16 @@ func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
 	return bc.statedb, nil
 }
 
+func (bc *testBlockChain) StateRecordAt(common.Hash) (*state.StateDBRecord, error) {
+	db, _ := ethdb.NewMemDatabase()
+	return state.NewRecord(common.Hash{}, state.NewDatabase(db))
+}
+
+func (bc *testBlockChain) PocContextAt(*types.Header) (*types.PocContext, error) {
+	db, _ := ethdb.NewMemDatabase()
+	return types.NewPocContext(db)
+}
+
 func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
 	return bc.chainHeadFeed.Subscribe(ch)
 }
@@ -77,12 +88,18 @@ func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.Pri
 }
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(params.TestChainConfig)
+}
+
+// setupTxPoolWithConfig creates a pool on an empty state validating by the
+// rules of the given chain config.
+func setupTxPoolWithConfig(config *params.ChainConfig) (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
 
 	key, _ := crypto.GenerateKey()
-	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	pool := NewTxPool(testTxPoolConfig, config, blockchain)
 
 	return pool, key
 }
@@ -100,6 +117,24 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the sponsor totals match the sponsored transactions in the pool
+	sponsored := make(map[common.Address]*big.Int)
+	for _, tx := range pool.all {
+		if sponsor, err := types.Sponsor(tx); tx.Sponsored() && err == nil {
+			if sponsored[sponsor] == nil {
+				sponsored[sponsor] = new(big.Int)
+			}
+			sponsored[sponsor].Add(sponsored[sponsor], tx.Fee(pool.chainconfig, pool.currentNumber))
+		}
+	}
+	if len(sponsored) != len(pool.sponsored) {
+		return fmt.Errorf("sponsor count mismatch: have %d, want %d", len(pool.sponsored), len(sponsored))
+	}
+	for sponsor, total := range sponsored {
+		if have := pool.sponsored[sponsor]; have == nil || have.Cmp(total) != 0 {
+			return fmt.Errorf("sponsored fees of %x mismatch: have %v, want %v", sponsor, have, total)
+		}
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -328,6 +363,445 @@ func TestTransactionNegativeValue(t *testing.T) {
 	}
 }
 
+// Tests that data and candidate transactions which would fail against the head
+// state are rejected by the pool with an error naming the reason.
+func TestTransactionTypedValidation(t *testing.T) {
+	t.Parallel()
+
+	config := *params.TestChainConfig
+	config.RecordMarketBlock = big.NewInt(0)
+	config.RecordTombstoneBlock = big.NewInt(0)
+	pool, key := setupTxPoolWithConfig(&config)
+	defer pool.Stop()
+
+	var (
+		otherKey, _ = crypto.GenerateKey()
+		from        = crypto.PubkeyToAddress(key.PublicKey)
+		other       = crypto.PubkeyToAddress(otherKey.PublicKey)
+		payload     = []byte("document")
+		record      = (&types.RecordConfirmation{Data: payload}).Key()
+	)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+	pool.currentState.AddBalance(other, big.NewInt(1000000))
+	pool.currentRecord.SetOrigin(record, from)
+	pool.currentRecord.SetOwner(record, from)
+	pool.currentPoc.BecomeCandidate(from)
+
+	tombstone, _ := (&types.Tombstone{Record: record}).EncodeToBytes()
+	multi, _ := (&types.MultiOperation{Ops: []*types.Operation{{Type: types.Binary, Recipient: other, Value: big.NewInt(1)}}}).EncodeToBytes()
+	tests := []struct {
+		key     *ecdsa.PrivateKey
+		txType  types.TxType
+		to      common.Address
+		value   *big.Int
+		payload []byte
+		err     error
+	}{
+		{key, types.ConfirmationData, common.Address{}, nil, confirmation(payload, 0), ErrRecordExists},
+		{otherKey, types.TransferData, other, nil, payload, ErrNotRecordOwner},
+		{otherKey, types.TransferData, from, nil, []byte("unknown"), ErrUnknownRecord},
+		{key, types.AuthorizationData, common.Address{}, nil, payload, types.ErrNoRecipient},
+		{key, types.TransferData, other, big.NewInt(1), payload, ErrAskPriceMismatch},
+		{otherKey, types.TransferAccept, common.Address{}, nil, payload, ErrNoTransferOffer},
+		{otherKey, types.RecordTombstone, common.Address{}, nil, tombstone, ErrNotTakedownApprover},
+		{key, types.LoginCandidate, common.Address{}, nil, nil, ErrAlreadyCandidate},
+		{otherKey, types.LogoutCandidate, common.Address{}, nil, nil, ErrNotCandidate},
+		{key, types.BatchConfirmationData, common.Address{}, nil, payload, ErrTxTypeNotActive},
+		{key, types.MultiOperationData, common.Address{}, nil, multi, ErrTxTypeNotActive},
+	}
+	for i, test := range tests {
+		value := test.value
+		if value == nil {
+			value = new(big.Int)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(test.txType, 0, test.to, value, big.NewInt(100), big.NewInt(1), test.payload), types.HomesteadSigner{}, test.key)
+		if err := pool.AddRemote(tx); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+	// Valid data transactions still enter the pool
+	tx, _ := types.SignTx(types.NewTransaction(types.TransferData, 0, other, new(big.Int), big.NewInt(100), big.NewInt(1), payload), types.HomesteadSigner{}, key)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("valid transfer rejected: %v", err)
+	}
+}
+
+// Tests that before the record market fork the pool checks transfers by the
+// legacy rules, letting only the owner of a normal record transfer it.
+func TestTransactionLegacyTransferValidation(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		otherKey, _ = crypto.GenerateKey()
+		from        = crypto.PubkeyToAddress(key.PublicKey)
+		other       = crypto.PubkeyToAddress(otherKey.PublicKey)
+		payload     = []byte("document")
+		pending     = []byte("pending document")
+		record      = (&types.RecordConfirmation{Data: payload}).Key()
+		offered     = (&types.RecordConfirmation{Data: pending}).Key()
+	)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+	pool.currentState.AddBalance(other, big.NewInt(1000000))
+	pool.currentRecord.SetOwner(record, from)
+	pool.currentRecord.SetAskPrice(record, big.NewInt(1))
+	pool.currentRecord.SetOwner(offered, other)
+	pool.currentRecord.SetTransferOffer(offered, from, new(big.Int), 100)
+
+	transfer := func(key *ecdsa.PrivateKey, to common.Address, value int64, payload []byte) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(types.TransferData, 0, to, big.NewInt(value), big.NewInt(100), big.NewInt(1), payload), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// Ask prices don't let anyone buy the record yet
+	if err := pool.AddRemote(transfer(otherKey, from, 1, payload)); err != ErrNotRecordOwner {
+		t.Fatalf("purchase error mismatch: have %v, want %v", err, ErrNotRecordOwner)
+	}
+	if err := pool.AddRemote(transfer(otherKey, from, 0, pending)); err != ErrRecordNotTransferable {
+		t.Fatalf("pending transfer error mismatch: have %v, want %v", err, ErrRecordNotTransferable)
+	}
+	// The owner may transfer with a value attached
+	if err := pool.AddRemote(transfer(key, other, 1, payload)); err != nil {
+		t.Fatalf("legacy transfer rejected: %v", err)
+	}
+}
+
+// Tests that the typed checks of a transaction take the sender's pooled
+// transactions before it into account, and that pending transactions are
+// checked again against a new head.
+func TestTransactionTypedValidationPending(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		otherKey, _ = crypto.GenerateKey()
+		from        = crypto.PubkeyToAddress(key.PublicKey)
+		other       = crypto.PubkeyToAddress(otherKey.PublicKey)
+	)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+	pool.currentState.AddBalance(other, big.NewInt(1000000))
+
+	tx := func(key *ecdsa.PrivateKey, nonce uint64, txType types.TxType, payload []byte) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(txType, nonce, common.Address{}, new(big.Int), big.NewInt(100), big.NewInt(1), payload), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// A record confirmed or a login by an earlier pooled transaction of the
+	// sender counts, whether the earlier transaction is pending or queued
+	if err := pool.AddRemote(tx(key, 0, types.ConfirmationData, []byte("document"))); err != nil {
+		t.Fatalf("failed to add confirmation: %v", err)
+	}
+	if err := pool.AddRemote(tx(key, 1, types.ConfirmationData, []byte("document"))); err != ErrRecordExists {
+		t.Fatalf("repeated confirmation error mismatch: have %v, want %v", err, ErrRecordExists)
+	}
+	if err := pool.AddRemote(tx(key, 1, types.LoginCandidate, nil)); err != nil {
+		t.Fatalf("failed to add login: %v", err)
+	}
+	if err := pool.AddRemote(tx(key, 2, types.LoginCandidate, nil)); err != ErrAlreadyCandidate {
+		t.Fatalf("repeated login error mismatch: have %v, want %v", err, ErrAlreadyCandidate)
+	}
+	// A pending confirmation of a record that the new head holds already is
+	// dropped, and the transactions after it are queued again
+	confirm := tx(otherKey, 0, types.ConfirmationData, []byte("leaked"))
+	if err := pool.AddRemote(confirm); err != nil {
+		t.Fatalf("failed to add confirmation: %v", err)
+	}
+	if err := pool.AddRemote(tx(otherKey, 1, types.Binary, nil)); err != nil {
+		t.Fatalf("failed to add transfer: %v", err)
+	}
+	pool.mu.Lock()
+	pool.currentRecord.SetOwner((&types.RecordConfirmation{Data: []byte("leaked")}).Key(), from)
+	pool.demoteUnexecutables()
+	pool.mu.Unlock()
+
+	if pool.Get(confirm.Hash()) != nil {
+		t.Fatalf("invalidated confirmation left in the pool")
+	}
+	if pending, queued := pool.pending[other], pool.queue[other]; pending != nil || queued == nil || queued.Len() != 1 {
+		t.Fatalf("transactions after the invalidated confirmation not queued")
+	}
+}
+
+// Tests that from the fee fork on the pool requires the fee and the spent
+// value instead of the vestigial gas cost, and evicts by fee.
+func TestTransactionFeeCost(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
+
+	config := *params.TestChainConfig
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(1000), ByteFee: big.NewInt(10)}
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	payload := []byte("document")
+	fee := int64(1000 + 10*len(payload))
+
+	// The gas price is ignored
+	pool.currentState.AddBalance(from, big.NewInt(fee-1))
+	tx, _ := types.SignTx(types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, big.NewInt(100000), big.NewInt(0), payload), types.HomesteadSigner{}, key)
+	if err := pool.AddRemote(tx); err != ErrInsufficientFunds {
+		t.Fatalf("transaction short of the fee: error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+	pool.currentState.AddBalance(from, big.NewInt(1))
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("transaction paying the fee rejected: %v", err)
+	}
+	if cost := pool.txCost(tx); cost.Cmp(big.NewInt(fee)) != 0 {
+		t.Fatalf("cost mismatch: have %v, want %v", cost, fee)
+	}
+	// Value transfers spend their value on top of the fee
+	transfer, _ := types.SignTx(types.NewTransaction(types.Binary, 1, common.Address{}, big.NewInt(5), big.NewInt(100000), big.NewInt(0), nil), types.HomesteadSigner{}, key)
+	if cost := pool.txCost(transfer); cost.Cmp(big.NewInt(1005)) != 0 {
+		t.Fatalf("transfer cost mismatch: have %v, want %v", cost, 1005)
+	}
+	if price, want := pool.txPriority(tx), fee*1024/int64(tx.Size()); price.Cmp(big.NewInt(want)) != 0 {
+		t.Fatalf("eviction price mismatch: have %v, want %v", price, want)
+	}
+}
+
+// Tests that the pool charges the fee of a sponsored transaction to the
+// sponsor, admitting senders without any balance.
+func TestSponsoredTransactionCost(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
+
+	config := *params.TestChainConfig
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(1000)}
+	config.TxEnvelopeBlock = big.NewInt(0)
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	userKey, _ := crypto.GenerateKey()
+	sponsorKey, _ := crypto.GenerateKey()
+	sponsor := crypto.PubkeyToAddress(sponsorKey.PublicKey)
+
+	tx := types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, big.NewInt(100000), big.NewInt(0), []byte("document")).WithSponsor(sponsor)
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, userKey)
+	if err := pool.AddRemote(tx); err != ErrInvalidSponsor {
+		t.Fatalf("unsigned sponsorship: error mismatch: have %v, want %v", err, ErrInvalidSponsor)
+	}
+	tx, _ = types.SignSponsor(tx, sponsorKey)
+	if err := pool.AddRemote(tx); err != ErrInsufficientFunds {
+		t.Fatalf("sponsor short of the fee: error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+	pool.currentState.AddBalance(sponsor, big.NewInt(1000))
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("sponsored transaction rejected: %v", err)
+	}
+	if cost := pool.txCost(tx); cost.Sign() != 0 {
+		t.Fatalf("sender charged for a sponsored transaction: %v", cost)
+	}
+	// The sponsor has to cover every transaction it sponsors in the pool
+	otherKey, _ := crypto.GenerateKey()
+	other := types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, big.NewInt(100000), big.NewInt(0), []byte("contract")).WithSponsor(sponsor)
+	other, _ = types.SignTx(other, types.HomesteadSigner{}, otherKey)
+	other, _ = types.SignSponsor(other, sponsorKey)
+	if err := pool.AddRemote(other); err != ErrInsufficientFunds {
+		t.Fatalf("sponsor short of both fees: error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+	pool.currentState.AddBalance(sponsor, big.NewInt(1000))
+	if err := pool.AddRemote(other); err != nil {
+		t.Fatalf("second sponsored transaction rejected: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Once the sponsor can only pay one of them, a reset drops the other
+	pool.currentState.SubBalance(sponsor, big.NewInt(1000))
+	pool.mu.Lock()
+	pool.dropUnfundedSponsored()
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending+queued != 1 {
+		t.Fatalf("pooled transactions mismatch: have %d, want 1", pending+queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the pool only accepts transactions of a multisig account signed
+// by at least the threshold of its signers.
+func TestMultisigTransactionQuorum(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
+
+	config := *params.TestChainConfig
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(1000)}
+	config.TxEnvelopeBlock = big.NewInt(0)
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	firstKey, _ := crypto.GenerateKey()
+	secondKey, _ := crypto.GenerateKey()
+	account := common.HexToAddress("0x0c")
+	signers := []common.Address{crypto.PubkeyToAddress(firstKey.PublicKey), crypto.PubkeyToAddress(secondKey.PublicKey)}
+	pool.currentState.AddBalance(account, big.NewInt(1000))
+
+	tx := types.NewTransaction(types.Binary, 0, common.HexToAddress("0x0b"), big.NewInt(0), big.NewInt(100000), big.NewInt(0), nil).WithMultisig(account)
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, firstKey)
+	if err := pool.AddRemote(tx); err != ErrNotMultisig {
+		t.Fatalf("ordinary account: error mismatch: have %v, want %v", err, ErrNotMultisig)
+	}
+	pool.currentState.SetMultisig(account, signers, 2)
+	if err := pool.AddRemote(tx); err != ErrMultisigQuorum {
+		t.Fatalf("single signer: error mismatch: have %v, want %v", err, ErrMultisigQuorum)
+	}
+	tx, _ = types.SignMultisig(tx, types.HomesteadSigner{}, secondKey)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("quorum transaction rejected: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatch: have %d, want 1", pending)
+	}
+}
+
+// Tests that the default ordering policy ranks transactions by their fee per
+// kilobyte, raised by the contribution of the sender and the time waited, each
+// capped.
+func TestDefaultTxPolicy(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	config := *params.TestChainConfig
+	config.FeeBlock = big.NewInt(0)
+	config.Fee = &params.FeeConfig{BaseFee: big.NewInt(1000)}
+
+	var (
+		now      = time.Now()
+		arrivals = make(map[common.Hash]time.Time)
+		key, _   = crypto.GenerateKey()
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+	)
+	policy := NewDefaultTxPolicy(&config, big.NewInt(1), statedb, func(hash common.Hash) time.Time { return arrivals[hash] }).(*defaultTxPolicy)
+	policy.now = func() time.Time { return now }
+
+	tx := types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, big.NewInt(1), []byte("document"))
+	period := time.Duration(params.TxPriorityAgePeriod) * time.Second
+	base := 1000 * 1024 / int64(tx.Size())
+
+	tests := []struct {
+		contribution int64 // units of contribution of the sender
+		waited       time.Duration
+		percent      int64 // priority in percent of the fee per kilobyte
+	}{
+		{0, 0, 100},
+		{5, 0, 105},
+		{5, 3 * period, 108},
+		{1000, 0, 200},
+		{0, 1000 * period, 200},
+		{1000, 1000 * period, 300},
+	}
+	for i, test := range tests {
+		statedb.SetContribution(from, new(big.Int).Mul(big.NewInt(test.contribution), new(big.Int).SetUint64(params.TxPriorityContributionUnit)))
+		arrivals[tx.Hash()] = now.Add(-test.waited)
+		if priority, want := policy.Priority(tx, from), base*test.percent/100; priority.Cmp(big.NewInt(want)) != 0 {
+			t.Errorf("test %d: priority mismatch: have %v, want %v", i, priority, want)
+		}
+	}
+	// A larger transaction paying the same fee ranks lower
+	statedb.SetContribution(from, new(big.Int))
+	delete(arrivals, tx.Hash())
+	large := types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, big.NewInt(1), make([]byte, 1024))
+	if policy.Priority(large, from).Cmp(policy.Priority(tx, from)) >= 0 {
+		t.Errorf("large transaction not ranked below the small one")
+	}
+	// Before the fee fork the gas price stands in for the fee
+	config.FeeBlock = big.NewInt(2)
+	statedb.SetContribution(from, new(big.Int))
+	delete(arrivals, tx.Hash())
+	if priority := policy.Priority(tx, from); priority.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("pre-fork priority mismatch: have %v, want 1", priority)
+	}
+}
+
+// Tests that expired transactions are rejected by the pool and dropped once a
+// new head closes their validity window.
+func TestTransactionWindowExpiry(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
+
+	config := *params.TestChainConfig
+	config.TxEnvelopeBlock = big.NewInt(1)
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+
+	windowed := func(nonce uint64, window *types.TxWindow) *types.Transaction {
+		tx := types.NewTransaction(types.Binary, nonce, common.Address{}, big.NewInt(100), big.NewInt(100000), big.NewInt(1), nil)
+		tx, _ = types.SignTx(tx.WithWindow(window), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// Windows are rejected until the envelope fork
+	config.TxEnvelopeBlock = big.NewInt(2)
+	if err := pool.AddRemote(windowed(0, &types.TxWindow{AfterBlock: 1})); err != ErrTxEnvelopeNotActive {
+		t.Fatalf("pre-fork window: error mismatch: have %v, want %v", err, ErrTxEnvelopeNotActive)
+	}
+	config.TxEnvelopeBlock = big.NewInt(1)
+
+	// The pool validates for block 1
+	if err := pool.AddRemote(windowed(0, &types.TxWindow{AfterBlock: 1})); err != nil {
+		t.Fatalf("open window rejected: %v", err)
+	}
+	closing := windowed(1, &types.TxWindow{UntilBlock: 1})
+	if err := pool.AddRemote(closing); err != nil {
+		t.Fatalf("window closing at the next block rejected: %v", err)
+	}
+	pool.currentNumber = big.NewInt(2)
+	if err := pool.AddRemote(windowed(2, &types.TxWindow{UntilBlock: 1})); err != ErrTxExpired {
+		t.Fatalf("expired transaction: error mismatch: have %v, want %v", err, ErrTxExpired)
+	}
+	// A new head drops the transactions that can no longer be included
+	pool.mu.Lock()
+	pool.dropExpired()
+	pool.mu.Unlock()
+
+	if pool.Get(closing.Hash()) != nil {
+		t.Fatalf("expired transaction not dropped")
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 1 and 0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// The window survives encoding and is signed, changing it changes the sender
+	blob, _ := rlp.EncodeToBytes(closing)
+	decoded := new(types.Transaction)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatalf("failed to decode windowed transaction: %v", err)
+	}
+	if decoded.Hash() != closing.Hash() || *decoded.Window() != *closing.Window() {
+		t.Fatalf("window lost in encoding: have %+v, want %+v", decoded.Window(), closing.Window())
+	}
+	tampered := closing.WithWindow(&types.TxWindow{UntilBlock: 100})
+	if sender, _ := types.Sender(types.HomesteadSigner{}, tampered); sender == from {
+		t.Fatalf("window changed without invalidating the signature")
+	}
+}
+
 func TestTransactionChainFork(t *testing.T) {
 	t.Parallel()
 
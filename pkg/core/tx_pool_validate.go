@@ -0,0 +1,381 @@
+package core
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+	"math/big"
+)
+
+var (
+	// ErrRecordExists is returned if a confirmation names a record that
+	// already exists.
+	ErrRecordExists = errors.New("record already exists")
+
+	// ErrUnknownRecord is returned if a transaction operates on a record that
+	// does not exist or has expired.
+	ErrUnknownRecord = errors.New("unknown record")
+
+	// ErrNotRecordOwner is returned if the sender may not transfer, offer or
+	// grant access to the record.
+	ErrNotRecordOwner = errors.New("sender is not the owner of the record")
+
+	// ErrRecordNotTransferable is returned if the record has a status other
+	// than the one the transaction requires, e.g. a pending transfer offer.
+	ErrRecordNotTransferable = errors.New("record status does not allow the transaction")
+
+	// ErrNoTransferOffer is returned if an offer is accepted or cancelled that
+	// is not open to the sender.
+	ErrNoTransferOffer = errors.New("no open transfer offer for the sender")
+
+	// ErrAskPriceMismatch is returned if a purchase does not pay the asking
+	// price of a listed record.
+	ErrAskPriceMismatch = errors.New("value does not match the asking price of the record")
+
+	// ErrAlreadyCandidate is returned if an existing candidate logs in again.
+	ErrAlreadyCandidate = errors.New("account is already a candidate")
+
+	// ErrNotCandidate is returned if an account that is not a candidate logs out.
+	ErrNotCandidate = errors.New("account is not a candidate")
+)
+
+// pendingTypedState is the state the typed checks of a sender's transaction
+// run against: the head states with the sender's pooled transactions of lower
+// nonces applied. The head states are only copied and the preceding
+// transactions only applied once a data or candidate transaction is checked.
+type pendingTypedState struct {
+	pool      *TxPool
+	from      common.Address
+	preceding types.Transactions // Transactions of the sender not yet applied
+
+	statedb       *state.StateDB
+	statedbRecord *state.StateDBRecord
+	pocContext    *types.PocContext
+}
+
+// pendingTypedState returns the head states of a sender's typed checks, with
+// none of its pooled transactions applied yet.
+func (pool *TxPool) pendingTypedState(from common.Address) *pendingTypedState {
+	return &pendingTypedState{
+		pool:          pool,
+		from:          from,
+		statedb:       pool.currentState,
+		statedbRecord: pool.currentRecord,
+		pocContext:    pool.currentPoc,
+	}
+}
+
+// pendingTypedStateAt returns the states a transaction of from with the given
+// nonce is checked against, applying the sender's pooled transactions from the
+// head nonce up to it.
+func (pool *TxPool) pendingTypedStateAt(from common.Address, nonce uint64) *pendingTypedState {
+	s := pool.pendingTypedState(from)
+	next := pool.currentState.GetNonce(from)
+	for _, list := range []*txList{pool.pending[from], pool.queue[from]} {
+		if list == nil {
+			continue
+		}
+		for _, tx := range list.Flatten() {
+			if tx.Nonce() == next && next < nonce {
+				s.apply(tx)
+				next++
+			}
+		}
+	}
+	return s
+}
+
+// apply adds the next transaction of the sender in nonce order to the state.
+func (s *pendingTypedState) apply(tx *types.Transaction) {
+	s.preceding = append(s.preceding, tx)
+}
+
+// validate runs the typed checks of the sender's next transaction against the
+// state. It does not apply the transaction.
+func (s *pendingTypedState) validate(tx *types.Transaction) error {
+	// 转账交易只检查结构, 不需要执行前面的交易
+	if tx.Type() != types.Binary && len(s.preceding) > 0 {
+		if s.statedb == s.pool.currentState {
+			s.statedb, s.statedbRecord, s.pocContext = s.statedb.Copy(), s.statedbRecord.Copy(), s.pocContext.Copy()
+		}
+		header := &types.Header{Number: s.pool.currentNumber, Time: s.pool.currentTime}
+		for i, prev := range s.preceding {
+			// 前面的交易无法执行时, 后面的交易也无法执行
+			if _, err := SimulateTransaction(s.pool.chainconfig, s.pocContext, s.statedb, s.statedbRecord, header, prev, s.from); err != nil {
+				s.preceding = s.preceding[i:]
+				return err
+			}
+		}
+		s.preceding = nil
+	}
+	return s.pool.validateTypedTx(tx, s.from, s.statedb, s.statedbRecord, s.pocContext)
+}
+
+// validateTypedTx checks a transaction against the rules of its type and the
+// given pending states, so that data and candidate transactions which would
+// fail in a block are rejected before entering the pool.
+func (pool *TxPool) validateTypedTx(tx *types.Transaction, from common.Address, statedb *state.StateDB, statedbRecord *state.StateDBRecord, pocContext *types.PocContext) error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	number := pool.currentNumber.Uint64()
+
+	// exists reports whether a record exists and has not expired by the next block
+	exists := func(record common.Hash) bool {
+		return statedbRecord.Exist(record) && !statedbRecord.IsExpired(record, number)
+	}
+	// status returns the record status, treating lapsed transfer offers as gone
+	status := func(record common.Hash) uint8 {
+		current := statedbRecord.GetStatus(record)
+		if current == state.RecordPendingTransfer {
+			if _, _, expiry := statedbRecord.GetTransferOffer(record); number > expiry {
+				return state.RecordNormal
+			}
+		}
+		return current
+	}
+
+	b, _ := rlp.EncodeToBytes(tx.Data())
+	hash := common.BytesToHash(b)
+	grant := recordKeyGrant(tx.Type(), tx.Data(), hash, statedbRecord)
+	if grant != nil {
+		hash = grant.Record
+	}
+
+	switch tx.Type() {
+	case types.ConfirmationData:
+		record, err := decodeConfirmation(pool.chainconfig, pool.currentNumber, tx.Data())
+		if err != nil {
+			return err
+		}
+		if record.Expiry != 0 && record.Expiry < number {
+			return ErrExpiryPassed
+		}
+		if exists(record.Key()) {
+			return ErrRecordExists
+		}
+
+	case types.EncryptedConfirmationData:
+		record, err := types.DecodeEncryptedRecord(tx.Data())
+		if err != nil {
+			return err
+		}
+		if exists(record.Key()) {
+			return ErrRecordExists
+		}
+
+	case types.BatchConfirmationData:
+		batch, err := types.DecodeBatchConfirmation(tx.Data())
+		if err != nil {
+			return err
+		}
+		if exists(batch.Root) {
+			return ErrRecordExists
+		}
+		for _, leaf := range batch.Leaves {
+			if exists(leaf) {
+				return ErrRecordExists
+			}
+		}
+
+	case types.AuthorizationData:
+		if statedbRecord.IsErased(hash) {
+			return ErrRecordErased
+		}
+		if grant != nil && len(grant.WrappedKey) == 0 {
+			return types.ErrEmptyWrappedKey
+		}
+		if grant != nil || pool.chainconfig.IsRecordMarket(pool.currentNumber) {
+			if statedbRecord.GetOwner(hash) != from {
+				return ErrNotRecordOwner
+			}
+		}
+
+	case types.TransferData:
+		if !exists(hash) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(hash) {
+			return ErrRecordErased
+		}
+		if status(hash) != state.RecordNormal {
+			return ErrRecordNotTransferable
+		}
+		owner := statedbRecord.GetOwner(hash)
+		if !pool.chainconfig.IsRecordMarket(pool.currentNumber) {
+			// 市场分叉前只有拥有者可以转出, 与 applyLegacyTransfer 一致
+			if owner != from {
+				return ErrNotRecordOwner
+			}
+			break
+		}
+		if statedbRecord.IsEncrypted(hash) {
+			if grant == nil || len(grant.WrappedKey) == 0 {
+				return types.ErrEmptyWrappedKey
+			}
+			if owner != from || *tx.To() == from {
+				return ErrNotRecordOwner
+			}
+		}
+		switch {
+		case owner == from && *tx.To() != from && tx.Value().Sign() != 0:
+			return ErrAskPriceMismatch
+		case owner != from:
+			// 非拥有者只能按挂出价格向拥有者购买
+			ask := statedbRecord.GetAskPrice(hash)
+			if ask.Sign() == 0 || *tx.To() != owner {
+				return ErrNotRecordOwner
+			}
+			if tx.Value().Cmp(ask) != 0 {
+				return ErrAskPriceMismatch
+			}
+		}
+
+	case types.TransferOffer:
+		if !exists(hash) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(hash) {
+			return ErrRecordErased
+		}
+		if statedbRecord.IsEncrypted(hash) && (grant == nil || len(grant.WrappedKey) == 0) {
+			return types.ErrEmptyWrappedKey
+		}
+		if statedbRecord.GetOwner(hash) != from || *tx.To() == from {
+			return ErrNotRecordOwner
+		}
+		if status(hash) != state.RecordNormal {
+			return ErrRecordNotTransferable
+		}
+
+	case types.TransferAccept, types.TransferCancel:
+		if !exists(hash) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(hash) {
+			return ErrRecordErased
+		}
+		if status(hash) != state.RecordPendingTransfer {
+			return ErrNoTransferOffer
+		}
+		to, price, _ := statedbRecord.GetTransferOffer(hash)
+		if tx.Type() == types.TransferCancel {
+			if statedbRecord.GetOwner(hash) != from {
+				return ErrNotRecordOwner
+			}
+			break
+		}
+		if to != from {
+			return ErrNoTransferOffer
+		}
+		if statedb.GetBalance(from).Cmp(new(big.Int).Add(pool.txCost(tx), price)) < 0 {
+			return ErrInsufficientFunds
+		}
+
+	case types.JointOwnershipData:
+		joint, err := types.DecodeJointOwnership(tx.Data())
+		if err != nil {
+			return err
+		}
+		if !exists(joint.Record) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(joint.Record) {
+			return ErrRecordErased
+		}
+		if statedbRecord.GetOwner(joint.Record) != from {
+			return ErrNotRecordOwner
+		}
+		if status(joint.Record) != state.RecordNormal {
+			return ErrRecordNotTransferable
+		}
+
+	case types.JointApprovalData:
+		proposal, err := types.DecodeRecordProposal(tx.Data())
+		if err != nil {
+			return err
+		}
+		if !exists(proposal.Record) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(proposal.Record) {
+			return ErrRecordErased
+		}
+		owners, _ := statedbRecord.GetJointOwners(proposal.Record)
+		if !containsAddress(owners, from) {
+			return ErrNotJointOwner
+		}
+		if statedbRecord.HasApproved(proposal.Record, proposal.ID(), from) {
+			return ErrAlreadyApproved
+		}
+
+	case types.RecordRenewal:
+		if !exists(hash) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(hash) {
+			return ErrRecordErased
+		}
+		owners, _ := statedbRecord.GetJointOwners(hash)
+		if statedbRecord.GetOwner(hash) != from && !containsAddress(owners, from) {
+			return ErrNotRecordOwner
+		}
+		if statedbRecord.GetExpiry(hash) == 0 {
+			return ErrRecordNotExpiring
+		}
+		fee := new(big.Int).SetUint64(params.RecordRenewalFee)
+		if statedb.GetBalance(from).Cmp(new(big.Int).Add(pool.txCost(tx), fee)) < 0 {
+			return ErrInsufficientFunds
+		}
+
+	case types.RecordTombstone:
+		tombstone, err := types.DecodeTombstone(tx.Data())
+		if err != nil {
+			return err
+		}
+		if !exists(tombstone.Record) {
+			return ErrUnknownRecord
+		}
+		if statedbRecord.IsErased(tombstone.Record) {
+			return ErrRecordErased
+		}
+		if statedbRecord.GetOrigin(tombstone.Record) != from {
+			validators, err := pocContext.GetValidators()
+			if err != nil || !containsAddress(validators, from) {
+				return ErrNotTakedownApprover
+			}
+			if statedbRecord.HasApprovedTakedown(tombstone.Record, from) {
+				return ErrAlreadyApproved
+			}
+		}
+
+	case types.MultiOperationData:
+		// 操作依赖前面操作的结果, 只在打包时整体执行, 结构已由 Validate 检查
+		multi, err := types.DecodeMultiOperation(tx.Data())
+		if err != nil {
+			return err
+		}
+		for _, op := range multi.Ops {
+			if err := checkTxType(pool.chainconfig, op.Type, pool.currentNumber); err != nil {
+				return err
+			}
+		}
+
+	case types.MultisigCreation:
+		// 账户地址由发送者和 nonce 派生, 签名者集合已由 Validate 检查
+
+	case types.LoginCandidate:
+		if pocContext.IsCandidate(from) {
+			return ErrAlreadyCandidate
+		}
+
+	case types.LogoutCandidate:
+		if !pocContext.IsCandidate(from) {
+			return ErrNotCandidate
+		}
+	}
+	return nil
+}
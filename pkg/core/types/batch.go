@@ -0,0 +1,160 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/rlp"
+	"errors"
+)
+
+var (
+	ErrEmptyBatch         = errors.New("batch confirmation has no leaves")
+	ErrBatchRootMismatch  = errors.New("batch confirmation root mismatch")
+	ErrBatchDuplicateLeaf = errors.New("batch confirmation has duplicate leaves")
+	ErrInvalidLeafIndex   = errors.New("invalid batch leaf index")
+)
+
+// 树节点哈希的域分隔前缀, 防止叶子和内部节点混淆
+var (
+	merkleLeafPrefix = []byte{0x00}
+	merkleNodePrefix = []byte{0x01}
+)
+
+// BatchConfirmation 批量确权交易的数据, Root 为所有叶子的默克尔根
+// 每个叶子是一条记录的摘要, 上链后成为发送者拥有的独立记录
+type BatchConfirmation struct {
+	Root   common.Hash
+	Leaves []common.Hash
+}
+
+// NewBatchConfirmation 根据记录摘要构造批量确权数据
+func NewBatchConfirmation(leaves []common.Hash) (*BatchConfirmation, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	return &BatchConfirmation{
+		Root:   MerkleRoot(leaves),
+		Leaves: leaves,
+	}, nil
+}
+
+// DecodeBatchConfirmation 解析交易数据并校验默克尔根
+func DecodeBatchConfirmation(payload []byte) (*BatchConfirmation, error) {
+	batch := new(BatchConfirmation)
+	if err := rlp.DecodeBytes(payload, batch); err != nil {
+		return nil, err
+	}
+	if err := batch.Validate(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Validate checks that the batch is non-empty, that no leaf is repeated and
+// that the root commits to exactly the given leaves.
+func (b *BatchConfirmation) Validate() error {
+	if len(b.Leaves) == 0 {
+		return ErrEmptyBatch
+	}
+	seen := make(map[common.Hash]struct{}, len(b.Leaves))
+	for _, leaf := range b.Leaves {
+		if _, ok := seen[leaf]; ok {
+			return ErrBatchDuplicateLeaf
+		}
+		seen[leaf] = struct{}{}
+	}
+	if MerkleRoot(b.Leaves) != b.Root {
+		return ErrBatchRootMismatch
+	}
+	return nil
+}
+
+// EncodeToBytes returns the transaction payload of the batch.
+func (b *BatchConfirmation) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(b)
+}
+
+func merkleLeaf(leaf common.Hash) common.Hash {
+	return crypto.Keccak256Hash(merkleLeafPrefix, leaf[:])
+}
+
+func merkleNode(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash(merkleNodePrefix, left[:], right[:])
+}
+
+// merkleLevels builds every level of the tree, leaf hashes first. An odd node
+// at the end of a level is promoted to the next level unchanged.
+func merkleLevels(leaves []common.Hash) [][]common.Hash {
+	level := make([]common.Hash, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeaf(leaf)
+	}
+	levels := [][]common.Hash{level}
+	for len(level) > 1 {
+		next := make([]common.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, merkleNode(level[i], level[i+1]))
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// MerkleRoot returns the root of the binary merkle tree over leaves.
+func MerkleRoot(leaves []common.Hash) common.Hash {
+	if len(leaves) == 0 {
+		return common.Hash{}
+	}
+	levels := merkleLevels(leaves)
+	return levels[len(levels)-1][0]
+}
+
+// MerkleProof returns the sibling hashes needed to prove the inclusion of
+// leaves[index] in MerkleRoot(leaves), ordered from the bottom of the tree.
+func MerkleProof(leaves []common.Hash, index int) ([]common.Hash, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrInvalidLeafIndex
+	}
+	var proof []common.Hash
+	for _, level := range merkleLevels(leaves) {
+		if len(level) == 1 {
+			break
+		}
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that leaf sits at index in a tree of size leaves
+// with the given root.
+func VerifyMerkleProof(root, leaf common.Hash, index, size int, proof []common.Hash) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+	hash := merkleLeaf(leaf)
+	for width := size; width > 1; width = (width + 1) / 2 {
+		sibling := index ^ 1
+		if sibling < width {
+			if len(proof) == 0 {
+				return false
+			}
+			if index%2 == 0 {
+				hash = merkleNode(hash, proof[0])
+			} else {
+				hash = merkleNode(proof[0], hash)
+			}
+			proof = proof[1:]
+		}
+		index /= 2
+	}
+	return len(proof) == 0 && hash == root
+}
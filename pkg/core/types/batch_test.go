@@ -0,0 +1,62 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLeaves(n int) []common.Hash {
+	leaves := make([]common.Hash, n)
+	for i := range leaves {
+		leaves[i] = crypto.Keccak256Hash([]byte{byte(i), byte(i >> 8)})
+	}
+	return leaves
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 4, 5, 7, 8, 33} {
+		leaves := testLeaves(size)
+		root := MerkleRoot(leaves)
+		for i, leaf := range leaves {
+			proof, err := MerkleProof(leaves, i)
+			assert.Nil(t, err)
+			assert.True(t, VerifyMerkleProof(root, leaf, i, size, proof), "size %d index %d", size, i)
+
+			// a proof must not verify for another position or leaf
+			if size > 1 {
+				assert.False(t, VerifyMerkleProof(root, leaf, (i+1)%size, size, proof))
+				assert.False(t, VerifyMerkleProof(root, leaves[(i+1)%size], i, size, proof))
+			}
+		}
+	}
+}
+
+func TestBatchConfirmationDecode(t *testing.T) {
+	batch, err := NewBatchConfirmation(testLeaves(5))
+	assert.Nil(t, err)
+	payload, err := batch.EncodeToBytes()
+	assert.Nil(t, err)
+
+	decoded, err := DecodeBatchConfirmation(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, batch.Root, decoded.Root)
+	assert.Equal(t, batch.Leaves, decoded.Leaves)
+
+	// tampered root
+	batch.Root[0] ^= 0xff
+	payload, _ = batch.EncodeToBytes()
+	_, err = DecodeBatchConfirmation(payload)
+	assert.Equal(t, ErrBatchRootMismatch, err)
+
+	// duplicate leaves
+	leaves := testLeaves(2)
+	leaves[1] = leaves[0]
+	batch = &BatchConfirmation{Root: MerkleRoot(leaves), Leaves: leaves}
+	assert.Equal(t, ErrBatchDuplicateLeaf, batch.Validate())
+
+	_, err = NewBatchConfirmation(nil)
+	assert.Equal(t, ErrEmptyBatch, err)
+}
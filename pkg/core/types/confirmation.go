@@ -0,0 +1,44 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+)
+
+var ErrEmptyConfirmation = errors.New("confirmation has no record data")
+
+// RecordConfirmation 记录市场分叉后确权交易的数据, 携带记录内容及其条款. 记录的键与
+// 以 Data 为数据的普通确权交易相同, 之后的授权和转移交易直接使用 Data 作为交易数据.
+type RecordConfirmation struct {
+	Data    []byte
+	Royalty uint64 // 之后每次出售支付给原始作者的版税比例 (万分比)
+	Expiry  uint64 // 记录有效的最后一个区块高度, 为 0 时永久有效
+}
+
+// DecodeRecordConfirmation 解析确权交易的数据并检查条款
+func DecodeRecordConfirmation(payload []byte) (*RecordConfirmation, error) {
+	record := new(RecordConfirmation)
+	if err := rlp.DecodeBytes(payload, record); err != nil {
+		return nil, err
+	}
+	if len(record.Data) == 0 {
+		return nil, ErrEmptyConfirmation
+	}
+	if record.Royalty > params.MaxRoyalty {
+		return nil, ErrInvalidRoyalty
+	}
+	return record, nil
+}
+
+// Key returns the record key, derived from the data like a plain confirmation.
+func (r *RecordConfirmation) Key() common.Hash {
+	b, _ := rlp.EncodeToBytes(r.Data)
+	return common.BytesToHash(b)
+}
+
+// EncodeToBytes returns the transaction payload of the confirmation.
+func (r *RecordConfirmation) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(r)
+}
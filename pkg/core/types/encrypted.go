@@ -0,0 +1,149 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/crypto/ecies"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	ErrEmptyCiphertext = errors.New("encrypted record has no ciphertext")
+	ErrEmptyWrappedKey = errors.New("missing wrapped content key")
+	ErrInvalidContent  = errors.New("invalid encrypted record content")
+)
+
+// ContentKeyLength is the size of the symmetric key encrypting a record payload.
+const ContentKeyLength = 32
+
+// EncryptedRecord 加密确权交易的数据. 记录内容用对称的内容密钥加密,
+// 内容密钥再用 ECIES 加密给拥有者的公钥. 记录的键为密文的摘要.
+type EncryptedRecord struct {
+	Ciphertext []byte
+	WrappedKey []byte
+	Royalty    uint64 // 之后每次出售支付给原始作者的版税比例 (万分比)
+}
+
+// DecodeEncryptedRecord 解析加密确权交易的数据
+func DecodeEncryptedRecord(payload []byte) (*EncryptedRecord, error) {
+	record := new(EncryptedRecord)
+	if err := rlp.DecodeBytes(payload, record); err != nil {
+		return nil, err
+	}
+	if len(record.Ciphertext) == 0 {
+		return nil, ErrEmptyCiphertext
+	}
+	if len(record.WrappedKey) == 0 {
+		return nil, ErrEmptyWrappedKey
+	}
+	if record.Royalty > params.MaxRoyalty {
+		return nil, ErrInvalidRoyalty
+	}
+	return record, nil
+}
+
+// Key returns the record key, the digest of the ciphertext.
+func (r *EncryptedRecord) Key() common.Hash {
+	return crypto.Keccak256Hash(r.Ciphertext)
+}
+
+// EncodeToBytes returns the transaction payload of the encrypted record.
+func (r *EncryptedRecord) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(r)
+}
+
+// KeyGrant 加密记录的授权和转移交易的数据, 携带为新的一方重新加密的内容密钥
+type KeyGrant struct {
+	Record     common.Hash
+	WrappedKey []byte
+}
+
+// DecodeKeyGrant 解析授权或转移加密记录的交易数据
+func DecodeKeyGrant(payload []byte) (*KeyGrant, error) {
+	grant := new(KeyGrant)
+	if err := rlp.DecodeBytes(payload, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// EncodeToBytes returns the transaction payload of the key grant.
+func (g *KeyGrant) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(g)
+}
+
+// SealRecord encrypts a record payload under a fresh content key and wraps
+// the content key to the owner's public key.
+func SealRecord(plaintext []byte, owner *ecdsa.PublicKey) (*EncryptedRecord, error) {
+	key := make([]byte, ContentKeyLength)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	ciphertext, err := encryptContent(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := WrapContentKey(key, owner)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedRecord{Ciphertext: ciphertext, WrappedKey: wrapped}, nil
+}
+
+// OpenRecord decrypts the ciphertext of a record with its content key.
+func OpenRecord(ciphertext []byte, key []byte) ([]byte, error) {
+	aead, err := contentCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	size := aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, ErrInvalidContent
+	}
+	return aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+}
+
+// WrapContentKey encrypts a content key to a public key with ECIES.
+func WrapContentKey(key []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), key, nil, nil)
+}
+
+// UnwrapContentKey decrypts a wrapped content key with the matching private key.
+func UnwrapContentKey(wrapped []byte, prv *ecdsa.PrivateKey) ([]byte, error) {
+	key, err := ecies.ImportECDSA(prv).Decrypt(rand.Reader, wrapped, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ContentKeyLength {
+		return nil, ErrInvalidContent
+	}
+	return key, nil
+}
+
+// encryptContent seals the payload with AES-GCM, prefixing the random nonce.
+func encryptContent(key []byte, plaintext []byte) ([]byte, error) {
+	aead, err := contentCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func contentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"AQChainRe/pkg/crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealRecord(t *testing.T) {
+	owner, _ := crypto.GenerateKey()
+	friend, _ := crypto.GenerateKey()
+	plaintext := []byte("confidential draft")
+
+	record, err := SealRecord(plaintext, &owner.PublicKey)
+	assert.Nil(t, err)
+	assert.NotEqual(t, plaintext, record.Ciphertext)
+
+	payload, err := record.EncodeToBytes()
+	assert.Nil(t, err)
+	decoded, err := DecodeEncryptedRecord(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, record.Key(), decoded.Key())
+
+	// Only the owner can unwrap the content key
+	_, err = UnwrapContentKey(record.WrappedKey, friend)
+	assert.NotNil(t, err)
+	key, err := UnwrapContentKey(record.WrappedKey, owner)
+	assert.Nil(t, err)
+
+	// Rewrap the key to the friend, who can then decrypt the content
+	wrapped, err := WrapContentKey(key, &friend.PublicKey)
+	assert.Nil(t, err)
+	friendKey, err := UnwrapContentKey(wrapped, friend)
+	assert.Nil(t, err)
+	opened, err := OpenRecord(decoded.Ciphertext, friendKey)
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	// Tampered ciphertexts are rejected
+	decoded.Ciphertext[len(decoded.Ciphertext)-1] ^= 0xff
+	_, err = OpenRecord(decoded.Ciphertext, key)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/common/hexutil"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+)
+
+var ErrInvalidExtension = errors.New("invalid transaction envelope extension")
+
+// 交易信封的可选扩展类型
+const (
+	windowExtension   uint8 = iota + 1 // 有效期
+	sponsorExtension                   // 赞助者支付手续费
+	multisigExtension                  // 多签账户发送
+)
+
+// txExtension 交易信封的可选扩展, 编码在签名之后. 每种扩展至多出现一次,
+// 按类型升序排列. 没有扩展的交易编码和哈希都与原来相同. 新的交易字段都作为
+// 扩展加入信封, 带扩展的交易从 TxEnvelopeBlock 分叉起才能进入交易池和区块.
+type txExtension struct {
+	Kind uint8         `json:"kind"`
+	Data hexutil.Bytes `json:"data"`
+}
+
+// Extended reports whether the transaction carries any envelope extension.
+// Such transactions are only valid from the transaction envelope fork on.
+func (tx *Transaction) Extended() bool {
+	return len(tx.data.Extensions) > 0
+}
+
+// extension decodes the extension of the given kind into val, reporting
+// whether the transaction carries it.
+func (tx *Transaction) extension(kind uint8, val interface{}) bool {
+	for _, ext := range tx.data.Extensions {
+		if ext.Kind == kind {
+			return rlp.DecodeBytes(ext.Data, val) == nil
+		}
+	}
+	return false
+}
+
+// withExtension returns a copy of tx with the extension of the given kind set
+// to val, or removed if val is nil.
+func (tx *Transaction) withExtension(kind uint8, val interface{}) *Transaction {
+	cpy := &Transaction{data: tx.data}
+	cpy.data.Extensions = nil
+	for _, ext := range tx.data.Extensions {
+		if ext.Kind != kind {
+			cpy.data.Extensions = append(cpy.data.Extensions, ext)
+		}
+	}
+	if val == nil {
+		return cpy
+	}
+	data, _ := rlp.EncodeToBytes(val)
+	i := 0
+	for i < len(cpy.data.Extensions) && cpy.data.Extensions[i].Kind < kind {
+		i++
+	}
+	exts := append([]*txExtension{}, cpy.data.Extensions[:i]...)
+	exts = append(exts, &txExtension{Kind: kind, Data: data})
+	cpy.data.Extensions = append(exts, cpy.data.Extensions[i:]...)
+	return cpy
+}
+
+// validateExtensions checks that the extensions are known, ordered, unique
+// and well formed.
+func (tx *Transaction) validateExtensions() error {
+	var last uint8
+	for _, ext := range tx.data.Extensions {
+		if ext.Kind <= last {
+			return ErrInvalidExtension
+		}
+		last = ext.Kind
+
+		switch ext.Kind {
+		case windowExtension:
+			var w TxWindow
+			if err := rlp.DecodeBytes(ext.Data, &w); err != nil {
+				return ErrInvalidWindow
+			}
+			if err := w.Validate(); err != nil {
+				return err
+			}
+		case sponsorExtension:
+			var sp TxSponsor
+			if err := rlp.DecodeBytes(ext.Data, &sp); err != nil {
+				return ErrInvalidSponsor
+			}
+			if err := sp.validate(tx); err != nil {
+				return err
+			}
+		case multisigExtension:
+			var ms TxMultisig
+			if err := rlp.DecodeBytes(ext.Data, &ms); err != nil || ms.Account == (common.Address{}) {
+				return ErrInvalidMultisig
+			}
+			if uint64(len(ms.Sigs)) >= params.MaxMultisigSigners {
+				return ErrInvalidMultisig
+			}
+		default:
+			return ErrInvalidExtension
+		}
+	}
+	return nil
+}
+
+// withExtensions appends the signed parts of the extensions of tx to the
+// fields signed by the sender, so they cannot be changed without
+// invalidating the signature. The signatures of the sponsor and of the other
+// multisig signers are not part of it.
+func withExtensions(tx *Transaction, fields []interface{}) []interface{} {
+	if w := tx.Window(); w != nil {
+		fields = append(fields, w)
+	}
+	if sponsor, ok := tx.SponsorAddress(); ok {
+		fields = append(fields, sponsor)
+	}
+	if account, ok := tx.MultisigAccount(); ok {
+		fields = append(fields, account)
+	}
+	return fields
+}
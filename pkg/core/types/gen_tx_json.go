@@ -27,6 +27,7 @@ func (t txdata) MarshalJSON() ([]byte, error) {
 		R            *hexutil.Big    `json:"r" gencodec:"required"`
 		S            *hexutil.Big    `json:"s" gencodec:"required"`
 		Hash         *common.Hash    `json:"hash" rlp:"-"`
+		Extensions   []*txExtension  `json:"extensions,omitempty" rlp:"tail"`
 	}
 	var enc txdata
 	enc.Type = t.Type
@@ -40,6 +41,7 @@ func (t txdata) MarshalJSON() ([]byte, error) {
 	enc.R = (*hexutil.Big)(t.R)
 	enc.S = (*hexutil.Big)(t.S)
 	enc.Hash = t.Hash
+	enc.Extensions = t.Extensions
 	return json.Marshal(&enc)
 }
 
@@ -57,6 +59,7 @@ func (t *txdata) UnmarshalJSON(input []byte) error {
 		R            *hexutil.Big    `json:"r" gencodec:"required"`
 		S            *hexutil.Big    `json:"s" gencodec:"required"`
 		Hash         *common.Hash    `json:"hash" rlp:"-"`
+		Extensions   []*txExtension  `json:"extensions,omitempty" rlp:"tail"`
 	}
 	var dec txdata
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -104,5 +107,8 @@ func (t *txdata) UnmarshalJSON(input []byte) error {
 	if dec.Hash != nil {
 		t.Hash = dec.Hash
 	}
+	if dec.Extensions != nil {
+		t.Extensions = dec.Extensions
+	}
 	return nil
 }
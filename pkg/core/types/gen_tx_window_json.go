@@ -0,0 +1,54 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package types
+
+import (
+	"encoding/json"
+
+	"AQChainRe/pkg/common/hexutil"
+)
+
+var _ = (*txWindowMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (t TxWindow) MarshalJSON() ([]byte, error) {
+	type TxWindow struct {
+		AfterBlock hexutil.Uint64 `json:"validAfter"`
+		UntilBlock hexutil.Uint64 `json:"validUntil"`
+		AfterTime  hexutil.Uint64 `json:"validAfterTime"`
+		UntilTime  hexutil.Uint64 `json:"validUntilTime"`
+	}
+	var enc TxWindow
+	enc.AfterBlock = hexutil.Uint64(t.AfterBlock)
+	enc.UntilBlock = hexutil.Uint64(t.UntilBlock)
+	enc.AfterTime = hexutil.Uint64(t.AfterTime)
+	enc.UntilTime = hexutil.Uint64(t.UntilTime)
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (t *TxWindow) UnmarshalJSON(input []byte) error {
+	type TxWindow struct {
+		AfterBlock *hexutil.Uint64 `json:"validAfter"`
+		UntilBlock *hexutil.Uint64 `json:"validUntil"`
+		AfterTime  *hexutil.Uint64 `json:"validAfterTime"`
+		UntilTime  *hexutil.Uint64 `json:"validUntilTime"`
+	}
+	var dec TxWindow
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.AfterBlock != nil {
+		t.AfterBlock = uint64(*dec.AfterBlock)
+	}
+	if dec.UntilBlock != nil {
+		t.UntilBlock = uint64(*dec.UntilBlock)
+	}
+	if dec.AfterTime != nil {
+		t.AfterTime = uint64(*dec.AfterTime)
+	}
+	if dec.UntilTime != nil {
+		t.UntilTime = uint64(*dec.UntilTime)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+)
+
+var (
+	ErrInvalidThreshold    = errors.New("joint ownership threshold out of range")
+	ErrTooManyJointOwners  = errors.New("too many joint owners")
+	ErrDuplicateJointOwner = errors.New("joint owner listed twice")
+	ErrInvalidProposal     = errors.New("invalid record proposal")
+)
+
+// 共有记录提案的动作
+const (
+	ProposalTransfer uint8 = iota + 1 // 将记录转移给单一拥有者, 共有关系随之解除
+	ProposalStatus                    // 修改记录状态
+)
+
+// JointOwnership 共有记录交易的数据, 由记录的唯一拥有者将记录转为 M-of-N 共有
+type JointOwnership struct {
+	Record    common.Hash
+	Owners    []common.Address
+	Threshold uint64
+}
+
+// DecodeJointOwnership 解析交易数据并校验共有人及门限
+func DecodeJointOwnership(payload []byte) (*JointOwnership, error) {
+	joint := new(JointOwnership)
+	if err := rlp.DecodeBytes(payload, joint); err != nil {
+		return nil, err
+	}
+	if err := joint.Validate(); err != nil {
+		return nil, err
+	}
+	return joint, nil
+}
+
+// Validate checks that the owner set is non-empty, bounded and free of
+// duplicates, and that the threshold is reachable.
+func (j *JointOwnership) Validate() error {
+	if uint64(len(j.Owners)) > params.MaxJointOwners {
+		return ErrTooManyJointOwners
+	}
+	if j.Threshold == 0 || j.Threshold > uint64(len(j.Owners)) {
+		return ErrInvalidThreshold
+	}
+	seen := make(map[common.Address]struct{}, len(j.Owners))
+	for _, owner := range j.Owners {
+		if _, ok := seen[owner]; ok || owner == (common.Address{}) {
+			return ErrDuplicateJointOwner
+		}
+		seen[owner] = struct{}{}
+	}
+	return nil
+}
+
+// EncodeToBytes returns the transaction payload of the joint ownership.
+func (j *JointOwnership) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(j)
+}
+
+// RecordProposal 共有人批准交易的数据, 相同内容的批准累计到同一个提案上
+type RecordProposal struct {
+	Record common.Hash
+	Action uint8
+	To     common.Address // ProposalTransfer 的接收者
+	Status uint8          // ProposalStatus 的新状态
+}
+
+// DecodeRecordProposal 解析交易数据并校验提案动作
+func DecodeRecordProposal(payload []byte) (*RecordProposal, error) {
+	proposal := new(RecordProposal)
+	if err := rlp.DecodeBytes(payload, proposal); err != nil {
+		return nil, err
+	}
+	switch proposal.Action {
+	case ProposalTransfer:
+		if proposal.To == (common.Address{}) || proposal.Status != 0 {
+			return nil, ErrInvalidProposal
+		}
+	case ProposalStatus:
+		if proposal.To != (common.Address{}) {
+			return nil, ErrInvalidProposal
+		}
+	default:
+		return nil, ErrInvalidProposal
+	}
+	return proposal, nil
+}
+
+// EncodeToBytes returns the transaction payload approving the proposal.
+func (p *RecordProposal) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(p)
+}
+
+// ID returns the identifier approvals of the same proposal are counted under.
+func (p *RecordProposal) ID() common.Hash {
+	return rlpHash(p)
+}
+
+// Pack encodes the action of the proposal into a single storage slot:
+// the action, the status and the 20 byte recipient.
+func (p *RecordProposal) Pack() common.Hash {
+	var packed common.Hash
+	packed[0] = p.Action
+	packed[1] = p.Status
+	copy(packed[common.HashLength-common.AddressLength:], p.To[:])
+	return packed
+}
+
+// UnpackRecordProposal restores a proposal of a record from its storage slot.
+func UnpackRecordProposal(record common.Hash, packed common.Hash) *RecordProposal {
+	return &RecordProposal{
+		Record: record,
+		Action: packed[0],
+		Status: packed[1],
+		To:     common.BytesToAddress(packed[common.HashLength-common.AddressLength:]),
+	}
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/params"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJointOwnershipValidate(t *testing.T) {
+	a, b := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+
+	assert.Nil(t, (&JointOwnership{Owners: []common.Address{a, b}, Threshold: 2}).Validate())
+	assert.Equal(t, ErrInvalidThreshold, (&JointOwnership{Owners: []common.Address{a, b}, Threshold: 3}).Validate())
+	assert.Equal(t, ErrInvalidThreshold, (&JointOwnership{Owners: []common.Address{a, b}}).Validate())
+	assert.Equal(t, ErrDuplicateJointOwner, (&JointOwnership{Owners: []common.Address{a, a}, Threshold: 1}).Validate())
+	assert.Equal(t, ErrDuplicateJointOwner, (&JointOwnership{Owners: []common.Address{a, {}}, Threshold: 1}).Validate())
+
+	owners := make([]common.Address, params.MaxJointOwners+1)
+	assert.Equal(t, ErrTooManyJointOwners, (&JointOwnership{Owners: owners, Threshold: 1}).Validate())
+}
+
+func TestRecordProposalEncoding(t *testing.T) {
+	record := common.HexToHash("0xabcd")
+	for _, proposal := range []*RecordProposal{
+		{Record: record, Action: ProposalTransfer, To: common.HexToAddress("0x0102030405060708091011121314151617181920")},
+		{Record: record, Action: ProposalStatus, Status: 7},
+	} {
+		payload, err := proposal.EncodeToBytes()
+		assert.Nil(t, err)
+		decoded, err := DecodeRecordProposal(payload)
+		assert.Nil(t, err)
+		assert.Equal(t, proposal, decoded)
+		assert.Equal(t, proposal, UnpackRecordProposal(record, proposal.Pack()))
+		assert.Equal(t, proposal.ID(), decoded.ID())
+	}
+	// Transfers need a recipient and status changes must not carry one
+	for _, proposal := range []*RecordProposal{
+		{Record: record, Action: ProposalTransfer},
+		{Record: record, Action: ProposalStatus, To: common.HexToAddress("0x01")},
+		{Record: record, Action: 9},
+	} {
+		payload, _ := proposal.EncodeToBytes()
+		_, err := DecodeRecordProposal(payload)
+		assert.Equal(t, ErrInvalidProposal, err)
+	}
+}
@@ -0,0 +1,104 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrEmptyMultiOperation = errors.New("multi-operation has no operations")
+	ErrTooManyOperations   = errors.New("multi-operation exceeds the operation limit")
+	ErrInvalidOperation    = errors.New("operation type not allowed in a multi-operation")
+)
+
+// Operation 多操作交易中的一个操作, 字段与同类型的单独交易相同.
+// 零地址的接收者表示没有接收者.
+type Operation struct {
+	Type      TxType
+	Recipient common.Address
+	Value     *big.Int
+	Payload   []byte
+}
+
+// MultiOperation 多操作交易的数据. 操作按顺序执行, 使用发送者的同一个 nonce,
+// 任一操作失败则所有操作的修改都回滚, 交易以失败状态打包.
+type MultiOperation struct {
+	Ops []*Operation
+}
+
+// DecodeMultiOperation 解析多操作交易数据并检查每个操作
+func DecodeMultiOperation(payload []byte) (*MultiOperation, error) {
+	multi := new(MultiOperation)
+	if err := rlp.DecodeBytes(payload, multi); err != nil {
+		return nil, err
+	}
+	if err := multi.Validate(); err != nil {
+		return nil, err
+	}
+	return multi, nil
+}
+
+// Validate checks the number of operations and each operation against the
+// rules of its transaction type. Only transfers and data operations on
+// records are allowed; candidate, tombstone and nested multi-operations are
+// not.
+func (m *MultiOperation) Validate() error {
+	if len(m.Ops) == 0 {
+		return ErrEmptyMultiOperation
+	}
+	if uint64(len(m.Ops)) > params.MaxOperations {
+		return ErrTooManyOperations
+	}
+	for _, op := range m.Ops {
+		switch op.Type {
+		case Binary:
+			if op.Recipient == (common.Address{}) {
+				return ErrNoRecipient
+			}
+		case ConfirmationData, AuthorizationData, TransferData, BatchConfirmationData,
+			TransferOffer, TransferAccept, TransferCancel, JointOwnershipData, JointApprovalData,
+			EncryptedConfirmationData, RecordRenewal:
+		default:
+			return ErrInvalidOperation
+		}
+		if op.Value != nil && op.Value.Sign() < 0 {
+			return ErrInvalidOperation
+		}
+		if err := op.Transaction(0).Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeToBytes returns the transaction payload of the multi-operation.
+func (m *MultiOperation) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(m)
+}
+
+// Transaction returns the operation as an unsigned transaction with the given
+// nonce, the form the rules of its type are written against.
+func (op *Operation) Transaction(nonce uint64) *Transaction {
+	return NewTransaction(op.Type, nonce, op.Recipient, op.Value, nil, nil, op.Payload)
+}
+
+// AsMessage returns the operation as a message sent by from. Operations share
+// the nonce of the enclosing transaction, which is checked once for all of
+// them, so the message skips the nonce check.
+func (op *Operation) AsMessage(from common.Address, nonce uint64) Message {
+	tx := op.Transaction(nonce)
+	return Message{
+		from:     from,
+		payer:    from,
+		to:       tx.To(),
+		nonce:    nonce,
+		amount:   tx.Value(),
+		price:    new(big.Int),
+		gasLimit: new(big.Int),
+		data:     tx.Data(),
+		txType:   op.Type,
+	}
+}
@@ -0,0 +1,158 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInvalidMultisig = errors.New("invalid multisig signer set or threshold")
+	ErrDuplicateSigner = errors.New("multisig transaction signed twice by the same signer")
+)
+
+// MultisigAccount 多签账户创建交易的数据. 账户地址由创建者地址和 nonce 派生,
+// 没有私钥, 只能由不少于门限数量的签名者共同签名的交易操作.
+type MultisigAccount struct {
+	Signers   []common.Address
+	Threshold uint64
+}
+
+// DecodeMultisigAccount 解析多签账户创建交易数据
+func DecodeMultisigAccount(payload []byte) (*MultisigAccount, error) {
+	account := new(MultisigAccount)
+	if err := rlp.DecodeBytes(payload, account); err != nil {
+		return nil, err
+	}
+	if err := account.Validate(); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Validate checks that the signers are distinct and non-zero, at most
+// MaxMultisigSigners, and that the threshold is reachable.
+func (m *MultisigAccount) Validate() error {
+	if len(m.Signers) == 0 || uint64(len(m.Signers)) > params.MaxMultisigSigners {
+		return ErrInvalidMultisig
+	}
+	if m.Threshold == 0 || m.Threshold > uint64(len(m.Signers)) {
+		return ErrInvalidMultisig
+	}
+	seen := make(map[common.Address]struct{}, len(m.Signers))
+	for _, signer := range m.Signers {
+		if signer == (common.Address{}) {
+			return ErrInvalidMultisig
+		}
+		if _, ok := seen[signer]; ok {
+			return ErrInvalidMultisig
+		}
+		seen[signer] = struct{}{}
+	}
+	return nil
+}
+
+// EncodeToBytes returns the transaction payload of the multisig account.
+func (m *MultisigAccount) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(m)
+}
+
+// MultisigAddress returns the address of the multisig account created by
+// creator with the given nonce.
+func MultisigAddress(creator common.Address, nonce uint64) common.Address {
+	return crypto.CreateAddress(creator, nonce)
+}
+
+// TxMultisig 多签扩展. 交易的发送者为 Account, 交易本身的签名是一个签名者的签名,
+// 其余签名者的签名放在 Sigs 中. 所有签名者对同一个哈希签名, 哈希包含 Account.
+type TxMultisig struct {
+	Account common.Address
+	Sigs    []*TxSignature
+}
+
+// TxSignature 一个签名者的签名值, 格式与交易签名相同
+type TxSignature struct {
+	V *big.Int
+	R *big.Int
+	S *big.Int
+}
+
+// WithMultisig returns a copy of the unsigned transaction sent by the multisig
+// account. The account is covered by the signatures, so it has to be set
+// before signing.
+func (tx *Transaction) WithMultisig(account common.Address) *Transaction {
+	return tx.withExtension(multisigExtension, &TxMultisig{Account: account, Sigs: []*TxSignature{}})
+}
+
+// MultisigAccount returns the multisig account sending the transaction, if
+// it is a multisig transaction.
+func (tx *Transaction) MultisigAccount() (common.Address, bool) {
+	ms := new(TxMultisig)
+	if !tx.extension(multisigExtension, ms) {
+		return common.Address{}, false
+	}
+	return ms.Account, true
+}
+
+// SignMultisig adds the signature of another signer of the multisig account
+// using the given signer and private key.
+func SignMultisig(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	h := s.Hash(tx)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithMultisigSignature(s, sig)
+}
+
+// WithMultisigSignature returns a new transaction with the signature of
+// another signer of the multisig account added. The signature needs to be
+// formatted as described in the yellow paper (v+27).
+func (tx *Transaction) WithMultisigSignature(signer Signer, sig []byte) (*Transaction, error) {
+	ms := new(TxMultisig)
+	if !tx.extension(multisigExtension, ms) {
+		return nil, ErrInvalidMultisig
+	}
+	r, s, v, err := signer.SignatureValues(tx, sig)
+	if err != nil {
+		return nil, err
+	}
+	ms.Sigs = append(ms.Sigs, &TxSignature{V: v, R: r, S: s})
+	return tx.withExtension(multisigExtension, ms), nil
+}
+
+// MultisigSigners returns the distinct signers of a multisig transaction: the
+// signer of the transaction signature followed by the signers of the added
+// signatures. Whether they reach the threshold of the account depends on the
+// state and is checked by the transaction pool and when applying.
+func MultisigSigners(signer Signer, tx *Transaction) ([]common.Address, error) {
+	ms := new(TxMultisig)
+	if !tx.extension(multisigExtension, ms) {
+		return nil, ErrInvalidMultisig
+	}
+	first, err := signer.Sender(tx)
+	if err != nil {
+		return nil, err
+	}
+	signers := []common.Address{first}
+	for _, sig := range ms.Sigs {
+		// 签名哈希不包含签名值, 换上其他签名者的签名即可恢复其地址
+		cpy := &Transaction{data: tx.data}
+		cpy.data.V, cpy.data.R, cpy.data.S = sig.V, sig.R, sig.S
+		addr, err := signer.Sender(cpy)
+		if err != nil {
+			return nil, err
+		}
+		for _, known := range signers {
+			if known == addr {
+				return nil, ErrDuplicateSigner
+			}
+		}
+		signers = append(signers, addr)
+	}
+	return signers, nil
+}
@@ -343,3 +343,8 @@ func (pc *PocContext) GetCandidates() ([]common.Address, error) {
 	}
 	return candidates, nil
 }
+
+// IsCandidate reports whether the account is registered as a candidate.
+func (pc *PocContext) IsCandidate(addr common.Address) bool {
+	return pc.candidateTrie.Get(addr.Bytes()) != nil
+}
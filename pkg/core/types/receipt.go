@@ -40,8 +40,60 @@ const (
 
 	// ReceiptStatusSuccessful is the status code of a transaction if execution succeeded.
 	ReceiptStatusSuccessful = uint(1)
+
+	// OperationStatusSkipped is the status code of an operation of a
+	// multi-operation transaction that did not run because an earlier one failed.
+	OperationStatusSkipped = uint(2)
 )
 
+// FailureCode is the machine-readable reason a transaction included in a block
+// failed. Failed transactions still pay the fee and use up their nonce.
+type FailureCode uint
+
+const (
+	FailureNone                FailureCode = iota // 交易成功
+	FailureUnknown                                // 未分类的失败
+	FailureInsufficientBalance                    // 余额不足以支付转账金额, 价格或续期费用
+	FailureInvalidPayload                         // 交易数据无效
+	FailureDuplicateRecord                        // 记录已存在
+	FailureUnknownRecord                          // 记录不存在
+	FailureRecordExpired                          // 记录已过期
+	FailureRecordErased                           // 记录已擦除
+	FailureNotOwner                               // 发送者不是记录的拥有者或共有人
+	FailureRecordFrozen                           // 记录状态不允许该操作
+	FailureNoTransferOffer                        // 没有发给发送者的转移要约
+	FailureInvalidValue                           // 金额与挂出价格不符, 或赠与附带了金额
+	FailureNotApprover                            // 发送者无权批准
+	FailureAlreadyApproved                        // 发送者已经批准过
+	FailureInvalidExpiry                          // 过期区块已过, 或记录不会过期
+	FailureAlreadyCandidate                       // 账户已经是候选者
+	FailureNotCandidate                           // 账户不是候选者
+	FailureMultisigExists                         // 多签账户已存在
+)
+
+var failureNames = []string{
+	"none", "unknown", "insufficient balance", "invalid payload", "duplicate record", "unknown record",
+	"record expired", "record erased", "not owner", "record frozen", "no transfer offer", "invalid value",
+	"not approver", "already approved", "invalid expiry", "already candidate", "not candidate",
+	"multisig exists",
+}
+
+// String implements the Stringer interface.
+func (c FailureCode) String() string {
+	if int(c) < len(failureNames) {
+		return failureNames[c]
+	}
+	return fmt.Sprintf("failure %d", uint(c))
+}
+
+// OperationResult is the result of one operation of a multi-operation
+// transaction. Operations that succeeded before a failing one were reverted
+// with it, the status of the receipt tells whether they took effect.
+type OperationResult struct {
+	Status  uint
+	Failure FailureCode // 失败操作的原因, 收据的失败原因取自它
+}
+
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
@@ -51,6 +103,11 @@ type Receipt struct {
 	Bloom             Bloom    `json:"logsBloom"         gencodec:"required"`
 	Logs              []*Log   `json:"logs"              gencodec:"required"`
 
+	// 多操作交易每个操作的结果, 其他交易为空
+	Results []*OperationResult `json:"results"`
+	// 失败交易的原因, 成功的交易为 FailureNone
+	Failure FailureCode `json:"failure"`
+
 	// Implementation fields (don't reorder!)
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
@@ -70,6 +127,15 @@ type receiptRLP struct {
 	PostStateOrStatus []byte
 	Bloom             Bloom
 	Logs              []*Log
+	Outcome           []*receiptOutcome `rlp:"tail"`
+}
+
+// receiptOutcome holds the failure reason and operation results of a receipt.
+// It follows the other fields only when one of them is set, so receipts of
+// transactions without them keep their encoding.
+type receiptOutcome struct {
+	Failure FailureCode
+	Results []*OperationResult
 }
 
 type receiptStorageRLP struct {
@@ -79,6 +145,7 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           *big.Int
+	Outcome           []*receiptOutcome `rlp:"tail"`
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -92,10 +159,16 @@ func NewReceipt(root []byte,rootRecord []byte, failed bool) *Receipt {
 	return r
 }
 
+// Failed reports whether the transaction was included as failed. Receipts
+// with a post state don't keep the status, only the failure reason.
+func (r *Receipt) Failed() bool {
+	return r.Failure != FailureNone || (len(r.PostState) == 0 && r.Status == ReceiptStatusFailed)
+}
+
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
 // into an RLP stream. If no post state is present, byzantium fork is assumed.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.Bloom, r.Logs})
+	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.Bloom, r.Logs, r.outcome()})
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
@@ -109,7 +182,7 @@ func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
 		return err
 	}
 	r.Bloom, r.Logs = dec.Bloom, dec.Logs
-	return nil
+	return r.setOutcome(dec.Outcome)
 }
 
 func (r *Receipt) setStatus(postStateOrStatus []byte) error {
@@ -126,6 +199,24 @@ func (r *Receipt) setStatus(postStateOrStatus []byte) error {
 	return nil
 }
 
+func (r *Receipt) outcome() []*receiptOutcome {
+	if r.Failure == FailureNone && len(r.Results) == 0 {
+		return nil
+	}
+	return []*receiptOutcome{{Failure: r.Failure, Results: r.Results}}
+}
+
+func (r *Receipt) setOutcome(outcome []*receiptOutcome) error {
+	switch len(outcome) {
+	case 0:
+	case 1:
+		r.Failure, r.Results = outcome[0].Failure, outcome[0].Results
+	default:
+		return fmt.Errorf("invalid receipt outcome count %d", len(outcome))
+	}
+	return nil
+}
+
 func (r *Receipt) statusEncoding() []byte {
 	if len(r.PostState) == 0 {
 		if r.Status == ReceiptStatusFailed {
@@ -158,6 +249,7 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 		ContractAddress:   r.ContractAddress,
 		Logs:              make([]*LogForStorage, len(r.Logs)),
 		GasUsed:           r.GasUsed,
+		Outcome:           (*Receipt)(r).outcome(),
 	}
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
@@ -183,7 +275,7 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
-	return nil
+	return (*Receipt)(r).setOutcome(dec.Outcome)
 }
 
 // Receipts is a wrapper around a Receipt array to implement DerivableList.
@@ -0,0 +1,135 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInvalidSponsor     = errors.New("invalid transaction sponsor")
+	ErrUnsponsorableType  = errors.New("only data transactions can be sponsored")
+	ErrMissingSponsorSig  = errors.New("missing sponsor signature")
+	ErrSponsorSigMismatch = errors.New("sponsor signature does not match the declared sponsor")
+)
+
+// TxSponsor 赞助者扩展. 发送者签名时指定赞助者地址, 赞助者再对发送者签好的
+// 交易签名, 手续费由赞助者支付. 交易仍使用发送者的 nonce, 不能被重放.
+type TxSponsor struct {
+	Address common.Address
+
+	// Sponsor signature values, V is 27 or 28
+	V *big.Int
+	R *big.Int
+	S *big.Int
+}
+
+// validate checks that the transaction may be sponsored at all.
+func (sp *TxSponsor) validate(tx *Transaction) error {
+	switch tx.Type() {
+	case Binary, LoginCandidate, LogoutCandidate:
+		return ErrUnsponsorableType
+	}
+	if sp.Address == (common.Address{}) {
+		return ErrInvalidSponsor
+	}
+	return nil
+}
+
+// WithSponsor returns a copy of the unsigned transaction with its fees paid by
+// sponsor. The sponsor address is covered by the signature of the sender, so
+// it has to be set before the sender signs.
+func (tx *Transaction) WithSponsor(sponsor common.Address) *Transaction {
+	return tx.withExtension(sponsorExtension, &TxSponsor{Address: sponsor, V: new(big.Int), R: new(big.Int), S: new(big.Int)})
+}
+
+// Sponsored reports whether the fees of the transaction are paid by a sponsor.
+func (tx *Transaction) Sponsored() bool {
+	_, ok := tx.SponsorAddress()
+	return ok
+}
+
+// SponsorAddress returns the sponsor declared by the sender, without checking
+// the signature of the sponsor.
+func (tx *Transaction) SponsorAddress() (common.Address, bool) {
+	sp := new(TxSponsor)
+	if !tx.extension(sponsorExtension, sp) {
+		return common.Address{}, false
+	}
+	return sp.Address, true
+}
+
+// SponsorHash returns the hash signed by the sponsor: the hash signed by the
+// sender together with the signature of the sender. A sponsor signature is
+// therefore only valid for this very transaction, on the chain the sender
+// signed it for.
+func SponsorHash(tx *Transaction) common.Hash {
+	v, r, s := tx.RawSignatureValues()
+	return rlpHash([]interface{}{deriveSigner(v).Hash(tx), v, r, s})
+}
+
+// SignSponsor signs the sponsorship of a transaction already signed by its
+// sender using the given private key.
+func SignSponsor(tx *Transaction, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	h := SponsorHash(tx)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSponsorSignature(sig)
+}
+
+// WithSponsorSignature returns a new transaction with the given sponsor
+// signature, in the [R || S || V] format where V is 0 or 1.
+func (tx *Transaction) WithSponsorSignature(sig []byte) (*Transaction, error) {
+	if len(sig) != 65 {
+		return nil, ErrInvalidSig
+	}
+	sp := new(TxSponsor)
+	if !tx.extension(sponsorExtension, sp) {
+		return nil, ErrInvalidSponsor
+	}
+	sp.R = new(big.Int).SetBytes(sig[:32])
+	sp.S = new(big.Int).SetBytes(sig[32:64])
+	sp.V = new(big.Int).SetBytes([]byte{sig[64] + 27})
+
+	return tx.withExtension(sponsorExtension, sp), nil
+}
+
+// Sponsor returns the address paying the fees of a sponsored transaction,
+// derived from the signature of the sponsor. It fails if the transaction is
+// not sponsored or the signature does not belong to the declared sponsor.
+//
+// Sponsor caches the address, the sponsor hash does not depend on a signer.
+func Sponsor(tx *Transaction) (common.Address, error) {
+	if sponsor := tx.sponsor.Load(); sponsor != nil {
+		return sponsor.(common.Address), nil
+	}
+	sp := new(TxSponsor)
+	if !tx.extension(sponsorExtension, sp) {
+		return common.Address{}, ErrInvalidSponsor
+	}
+	if sp.V.Sign() == 0 && sp.R.Sign() == 0 && sp.S.Sign() == 0 {
+		return common.Address{}, ErrMissingSponsorSig
+	}
+	addr, err := recoverPlain(SponsorHash(tx), sp.R, sp.S, sp.V, true)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if addr != sp.Address {
+		return common.Address{}, ErrSponsorSigMismatch
+	}
+	tx.sponsor.Store(addr)
+	return addr, nil
+}
+
+// Payer returns the address paying the fees of the transaction: the sponsor
+// of a sponsored transaction, the sender otherwise.
+func Payer(signer Signer, tx *Transaction) (common.Address, error) {
+	if tx.Sponsored() {
+		return Sponsor(tx)
+	}
+	return Sender(signer, tx)
+}
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/rlp"
+	"errors"
+)
+
+var ErrEmptyTombstone = errors.New("tombstone names no record")
+
+// Tombstone 墓碑交易的数据, 只携带记录的键, 不再重复记录的内容
+type Tombstone struct {
+	Record common.Hash
+}
+
+// DecodeTombstone 解析墓碑交易数据
+func DecodeTombstone(payload []byte) (*Tombstone, error) {
+	tombstone := new(Tombstone)
+	if err := rlp.DecodeBytes(payload, tombstone); err != nil {
+		return nil, err
+	}
+	if tombstone.Record == (common.Hash{}) {
+		return nil, ErrEmptyTombstone
+	}
+	return tombstone, nil
+}
+
+// EncodeToBytes returns the transaction payload of the tombstone.
+func (t *Tombstone) EncodeToBytes() ([]byte, error) {
+	return rlp.EncodeToBytes(t)
+}
@@ -20,6 +20,7 @@ import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/common/hexutil"
 	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/params"
 	"AQChainRe/pkg/rlp"
 	"container/heap"
 	"errors"
@@ -44,6 +45,25 @@ const (
 	ConfirmationData
 	AuthorizationData
 	TransferData
+	// 批量确权
+	BatchConfirmationData
+	// 两阶段转移: 拥有者发起转移要约, 接收者接受, 拥有者撤销
+	TransferOffer
+	TransferAccept
+	TransferCancel
+	// 多人共有记录: 拥有者设定共有人及门限, 共有人批准转移或状态修改提案
+	JointOwnershipData
+	JointApprovalData
+	// 加密确权: 数据为密文和加密给拥有者的内容密钥
+	EncryptedConfirmationData
+	// 有效期: 确权数据中设定失效区块, 续期交易支付费用延长有效期
+	RecordRenewal
+	// 墓碑: 原始作者或多数验证者批准后将记录标记为已擦除, 保留来源信息
+	RecordTombstone
+	// 多操作: 按顺序原子执行的一组数据和转账操作, 任一操作失败则全部回滚
+	MultiOperationData
+	// 多签账户: 创建由多个签名者按门限共同控制的账户
+	MultisigCreation
 )
 
 var (
@@ -52,6 +72,10 @@ var (
 	ErrInvalidType    = errors.New("invalid transaction types")
 	ErrInvalidAddress = errors.New("invalid transaction payload address")
 	ErrInvalidAction  = errors.New("invalid transaction payload action")
+	ErrInvalidRoyalty = errors.New("royalty exceeds the maximum rate")
+	ErrNonZeroValue   = errors.New("transaction value should be 0")
+	ErrNoRecipient    = errors.New("receipient was required")
+	ErrNonEmptyData   = errors.New("payload should be empty")
 )
 
 // deriveSigner makes a *best* guess about which signer to use.
@@ -66,9 +90,10 @@ func deriveSigner(V *big.Int) Signer {
 type Transaction struct {
 	data txdata
 	// caches
-	hash atomic.Value
-	size atomic.Value
-	from atomic.Value
+	hash    atomic.Value
+	size    atomic.Value
+	from    atomic.Value
+	sponsor atomic.Value
 }
 
 type txdata struct {
@@ -87,6 +112,9 @@ type txdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash *common.Hash `json:"hash" rlp:"-"`
+
+	// 可选的信封扩展 (有效期, 赞助者), 编码在签名之后
+	Extensions []*txExtension `json:"extensions,omitempty" rlp:"tail"`
 }
 
 type txdataMarshaling struct {
@@ -147,20 +175,57 @@ func (tx *Transaction) ChainId() *big.Int {
 	return deriveChainId(tx.data.V)
 }
 
+// WithWindow returns a copy of the unsigned transaction that is only valid
+// within w. The window is covered by the signature, so it has to be set
+// before signing.
+func (tx *Transaction) WithWindow(w *TxWindow) *Transaction {
+	if w == nil {
+		return tx.withExtension(windowExtension, nil)
+	}
+	return tx.withExtension(windowExtension, w)
+}
+
+// Window returns the validity window of the transaction, or nil if it is
+// valid in any block.
+func (tx *Transaction) Window() *TxWindow {
+	w := new(TxWindow)
+	if !tx.extension(windowExtension, w) {
+		return nil
+	}
+	return w
+}
+
 // Valid the transaction when the types isn't the binary
 func (tx *Transaction) Validate() error {
+	if err := tx.validateExtensions(); err != nil {
+		return err
+	}
 	if tx.Type() != Binary {
-		if tx.Type() != TransferData {
-			if tx.Value().Uint64() != 0 {
-				return errors.New("transaction value should be 0")
+		// 转移交易和转移要约的金额为购买价格
+		if tx.Type() != TransferData && tx.Type() != TransferOffer {
+			if tx.Value().Sign() != 0 {
+				return ErrNonZeroValue
 			}
 		}
-		if tx.To() == nil && tx.Type() != LoginCandidate && tx.Type() != LogoutCandidate && tx.Type() != ConfirmationData && tx.Type() != ConfirmationData {
-			return errors.New("receipient was required")
+		if tx.To() == nil && tx.Type() != LoginCandidate && tx.Type() != LogoutCandidate && tx.Type() != ConfirmationData && tx.Type() != BatchConfirmationData &&
+			tx.Type() != TransferAccept && tx.Type() != TransferCancel && tx.Type() != JointOwnershipData && tx.Type() != JointApprovalData &&
+			tx.Type() != EncryptedConfirmationData && tx.Type() != RecordRenewal &&
+			tx.Type() != RecordTombstone && tx.Type() != MultiOperationData && tx.Type() != MultisigCreation {
+			return ErrNoRecipient
+		}
+		if tx.Type() == MultiOperationData {
+			if _, err := DecodeMultiOperation(tx.Data()); err != nil {
+				return err
+			}
+		}
+		if tx.Type() == MultisigCreation {
+			if _, err := DecodeMultisigAccount(tx.Data()); err != nil {
+				return err
+			}
 		}
 		if tx.Type() == LoginCandidate || tx.Type() == LogoutCandidate {
 			if len(tx.Data()) > 0 {
-				return errors.New("payload should be empty")
+				return ErrNonEmptyData
 			}
 		}
 	}
@@ -271,7 +336,31 @@ func (tx *Transaction) Size() common.StorageSize {
 //
 // XXX Rename message to something less arbitrary?
 func (tx *Transaction) AsMessage(s Signer) (Message, error) {
-	msg := Message{
+	msg := tx.message()
+
+	var err error
+	msg.from, err = Sender(s, tx)
+	if err != nil {
+		return msg, err
+	}
+	msg.payer, err = Payer(s, tx)
+	return msg, err
+}
+
+// AsSimulatedMessage returns the message of a transaction sent by from
+// without recovering any signature, to dry run transactions before they are
+// signed. The fee is paid by the declared sponsor, if there is one.
+func (tx *Transaction) AsSimulatedMessage(from common.Address) Message {
+	msg := tx.message()
+	msg.from, msg.payer = from, from
+	if sponsor, ok := tx.SponsorAddress(); ok {
+		msg.payer = sponsor
+	}
+	return msg
+}
+
+func (tx *Transaction) message() Message {
+	return Message{
 		nonce:      tx.data.AccountNonce,
 		price:      new(big.Int).Set(tx.data.Price),
 		gasLimit:   new(big.Int).Set(tx.data.GasLimit),
@@ -281,10 +370,6 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 		txType:     tx.data.Type,
 		checkNonce: true,
 	}
-
-	var err error
-	msg.from, err = Sender(s, tx)
-	return msg, err
 }
 
 // WithSignature returns a new transaction with the given signature.
@@ -299,6 +384,17 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	return cpy, nil
 }
 
+// WithoutPayload returns a copy of the transaction with its payload removed,
+// as kept in block bodies after the payload of an erased record was pruned.
+// The copy reports the given hash of the original transaction, which it no
+// longer hashes to itself.
+func (tx *Transaction) WithoutPayload(hash common.Hash) *Transaction {
+	cpy := &Transaction{data: tx.data}
+	cpy.data.Payload = nil
+	cpy.hash.Store(hash)
+	return cpy
+}
+
 // Cost returns amount + gasprice * gaslimit.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.data.Price, tx.data.GasLimit)
@@ -306,6 +402,44 @@ func (tx *Transaction) Cost() *big.Int {
 	return total
 }
 
+// Fee returns the fee the sender pays to the block validator under the fee
+// schedule of config at block number, zero before the fee fork.
+func (tx *Transaction) Fee(config *params.ChainConfig, number *big.Int) *big.Int {
+	if !config.IsFee(number) {
+		return new(big.Int)
+	}
+	return config.Fee.Fee(uint8(tx.data.Type), int(tx.PayloadSize()))
+}
+
+// Spent returns the part of the value that leaves the balance of the sender
+// from. The value of a listing or a transfer offer is the asking price paid
+// later by the buyer.
+func (tx *Transaction) Spent(from common.Address) *big.Int {
+	switch tx.data.Type {
+	case TransferOffer:
+		return new(big.Int)
+	case MultiOperationData:
+		// 多操作交易花费各个操作花费之和
+		spent := new(big.Int)
+		if multi, err := DecodeMultiOperation(tx.data.Payload); err == nil {
+			for _, op := range multi.Ops {
+				spent.Add(spent, op.Transaction(tx.data.AccountNonce).Spent(from))
+			}
+		}
+		return spent
+	case TransferData:
+		if tx.data.Recipient == nil || *tx.data.Recipient == from {
+			return new(big.Int)
+		}
+	}
+	return new(big.Int).Set(tx.data.Amount)
+}
+
+// PayloadSize returns the number of payload bytes the transaction carries.
+func (tx *Transaction) PayloadSize() uint64 {
+	return uint64(len(tx.data.Payload))
+}
+
 func (tx *Transaction) RawSignatureValues() (*big.Int, *big.Int, *big.Int) {
 	return tx.data.V, tx.data.R, tx.data.S
 }
@@ -426,12 +560,35 @@ func (s *TxByPrice) Pop() interface{} {
 	return x
 }
 
+// txHeads is a heap of the next transaction of each account, highest priced
+// first by the given price function.
+type txHeads struct {
+	txs   Transactions
+	price func(*Transaction) *big.Int
+}
+
+func (h txHeads) Len() int           { return len(h.txs) }
+func (h txHeads) Less(i, j int) bool { return h.price(h.txs[i]).Cmp(h.price(h.txs[j])) > 0 }
+func (h txHeads) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeads) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*Transaction))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
 // TransactionsByPriceAndNonce represents a set of transactions that can return
 // transactions in a profit-maximising sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
 type TransactionsByPriceAndNonce struct {
 	txs    map[common.Address]Transactions // Per account nonce-sorted list of transactions
-	heads  TxByPrice                       // Next transaction for each unique account (price heap)
+	heads  *txHeads                        // Next transaction for each unique account (price heap)
 	signer Signer                          // Signer for the set of transactions
 }
 
@@ -441,15 +598,24 @@ type TransactionsByPriceAndNonce struct {
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
 func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transactions) *TransactionsByPriceAndNonce {
+	return NewTransactionsByPriorityAndNonce(signer, txs, (*Transaction).GasPrice)
+}
+
+// NewTransactionsByPriorityAndNonce creates a transaction set that retrieves
+// the transactions of the highest priority first, in a nonce-honouring way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func NewTransactionsByPriorityAndNonce(signer Signer, txs map[common.Address]Transactions, priority func(*Transaction) *big.Int) *TransactionsByPriceAndNonce {
 	// Initialize a price based heap with the head transactions
-	heads := make(TxByPrice, 0, len(txs))
+	heads := &txHeads{txs: make(Transactions, 0, len(txs)), price: priority}
 	for _, accTxs := range txs {
-		heads = append(heads, accTxs[0])
+		heads.txs = append(heads.txs, accTxs[0])
 		// Ensure the sender address is from the signer
 		acc, _ := Sender(signer, accTxs[0])
 		txs[acc] = accTxs[1:]
 	}
-	heap.Init(&heads)
+	heap.Init(heads)
 
 	// Assemble and return the transaction set
 	return &TransactionsByPriceAndNonce{
@@ -461,20 +627,20 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 
 // Peek returns the next transaction by price.
 func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
-	if len(t.heads) == 0 {
+	if len(t.heads.txs) == 0 {
 		return nil
 	}
-	return t.heads[0]
+	return t.heads.txs[0]
 }
 
 // Shift replaces the current best head with the next one from the same account.
 func (t *TransactionsByPriceAndNonce) Shift() {
-	acc, _ := Sender(t.signer, t.heads[0])
+	acc, _ := Sender(t.signer, t.heads.txs[0])
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
-		t.heads[0], t.txs[acc] = txs[0], txs[1:]
-		heap.Fix(&t.heads, 0)
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
 	} else {
-		heap.Pop(&t.heads)
+		heap.Pop(t.heads)
 	}
 }
 
@@ -482,7 +648,7 @@ func (t *TransactionsByPriceAndNonce) Shift() {
 // the same account. This should be used when a transaction cannot be executed
 // and hence all subsequent ones should be discarded from the same account.
 func (t *TransactionsByPriceAndNonce) Pop() {
-	heap.Pop(&t.heads)
+	heap.Pop(t.heads)
 }
 
 // Message is a fully derived transaction and implements core.Message
@@ -491,6 +657,7 @@ func (t *TransactionsByPriceAndNonce) Pop() {
 type Message struct {
 	to                      *common.Address
 	from                    common.Address
+	payer                   common.Address // 支付手续费的账户, 赞助交易为赞助者
 	nonce                   uint64
 	amount, price, gasLimit *big.Int
 	data                    []byte
@@ -501,6 +668,7 @@ type Message struct {
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount, gasLimit, price *big.Int, data []byte, checkNonce bool) Message {
 	return Message{
 		from:       from,
+		payer:      from,
 		to:         to,
 		nonce:      nonce,
 		amount:     amount,
@@ -511,12 +679,13 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount, g
 	}
 }
 
-func (m Message) From() common.Address { return m.from }
-func (m Message) To() *common.Address  { return m.to }
-func (m Message) GasPrice() *big.Int   { return m.price }
-func (m Message) Value() *big.Int      { return m.amount }
-func (m Message) Gas() *big.Int        { return m.gasLimit }
-func (m Message) Nonce() uint64        { return m.nonce }
-func (m Message) Data() []byte         { return m.data }
-func (m Message) CheckNonce() bool     { return m.checkNonce }
-func (m Message) Type() TxType         { return m.txType }
+func (m Message) From() common.Address  { return m.from }
+func (m Message) Payer() common.Address { return m.payer }
+func (m Message) To() *common.Address   { return m.to }
+func (m Message) GasPrice() *big.Int    { return m.price }
+func (m Message) Value() *big.Int       { return m.amount }
+func (m Message) Gas() *big.Int         { return m.gasLimit }
+func (m Message) Nonce() uint64         { return m.nonce }
+func (m Message) Data() []byte          { return m.data }
+func (m Message) CheckNonce() bool      { return m.checkNonce }
+func (m Message) Type() TxType          { return m.txType }
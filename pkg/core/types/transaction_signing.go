@@ -84,6 +84,13 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	if err != nil {
 		return common.Address{}, err
 	}
+	// 多签交易的发送者是多签账户, 其余签名者的签名也都必须有效
+	if account, ok := tx.MultisigAccount(); ok {
+		if _, err := MultisigSigners(signer, tx); err != nil {
+			return common.Address{}, err
+		}
+		addr = account
+	}
 	tx.from.Store(sigCache{signer: signer, from: addr})
 	return addr, nil
 }
@@ -153,7 +160,7 @@ func (s EIP155Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (s EIP155Signer) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(withExtensions(tx, []interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
 		tx.data.GasLimit,
@@ -161,7 +168,7 @@ func (s EIP155Signer) Hash(tx *Transaction) common.Hash {
 		tx.data.Amount,
 		tx.data.Payload,
 		s.chainId, uint(0), uint(0),
-	})
+	}))
 }
 
 // HomesteadTransaction implements TransactionInterface using the
@@ -205,14 +212,14 @@ func (fs FrontierSigner) SignatureValues(tx *Transaction, sig []byte) (r, s, v *
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (fs FrontierSigner) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(withExtensions(tx, []interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
 		tx.data.GasLimit,
 		tx.data.Recipient,
 		tx.data.Amount,
 		tx.data.Payload,
-	})
+	}))
 }
 
 func (fs FrontierSigner) Sender(tx *Transaction) (common.Address, error) {
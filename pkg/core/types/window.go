@@ -0,0 +1,52 @@
+package types
+
+import (
+	"AQChainRe/pkg/common/hexutil"
+	"errors"
+	"math/big"
+)
+
+//go:generate gencodec -type TxWindow -field-override txWindowMarshaling -out gen_tx_window_json.go
+
+var ErrInvalidWindow = errors.New("invalid transaction validity window")
+
+// TxWindow 交易的有效期. 交易只能被打包进区块号和区块时间都在范围内的区块,
+// 为 0 的边界表示不限制. 有效期是签名内容的一部分, 广播之后无法修改.
+type TxWindow struct {
+	AfterBlock uint64 `json:"validAfter"`     // 最早可打包的区块号
+	UntilBlock uint64 `json:"validUntil"`     // 最晚可打包的区块号
+	AfterTime  uint64 `json:"validAfterTime"` // 最早可打包的区块时间
+	UntilTime  uint64 `json:"validUntilTime"` // 最晚可打包的区块时间
+}
+
+type txWindowMarshaling struct {
+	AfterBlock hexutil.Uint64
+	UntilBlock hexutil.Uint64
+	AfterTime  hexutil.Uint64
+	UntilTime  hexutil.Uint64
+}
+
+// Validate checks that the bounds of the window do not cross.
+func (w *TxWindow) Validate() error {
+	if w.UntilBlock != 0 && w.AfterBlock > w.UntilBlock {
+		return ErrInvalidWindow
+	}
+	if w.UntilTime != 0 && w.AfterTime > w.UntilTime {
+		return ErrInvalidWindow
+	}
+	return nil
+}
+
+// NotYetValid reports whether a block with the given number and time is
+// before the window.
+func (w *TxWindow) NotYetValid(number, time *big.Int) bool {
+	return (w.AfterBlock != 0 && number.Cmp(new(big.Int).SetUint64(w.AfterBlock)) < 0) ||
+		(w.AfterTime != 0 && time.Cmp(new(big.Int).SetUint64(w.AfterTime)) < 0)
+}
+
+// Expired reports whether a block with the given number and time is past
+// the window. Later blocks are past it too.
+func (w *TxWindow) Expired(number, time *big.Int) bool {
+	return (w.UntilBlock != 0 && number.Cmp(new(big.Int).SetUint64(w.UntilBlock)) > 0) ||
+		(w.UntilTime != 0 && time.Cmp(new(big.Int).SetUint64(w.UntilTime)) > 0)
+}
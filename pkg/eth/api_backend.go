@@ -108,6 +108,25 @@ func (b *EthApiBackend) StateRecordAndHeaderByNumber(ctx context.Context, blockN
 	return stateDbRecord, header, err
 }
 
+func (b *EthApiBackend) PocContextAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.PocContext, *types.Header, error) {
+	// 待打包区块的 poc 上下文只有矿工知道, 尚未生成时使用链头的上下文
+	if blockNr == rpc.PendingBlockNumber {
+		block, _, _ := b.eth.miner.Pending()
+		if pocContext := block.PocCtx(); pocContext != nil {
+			return pocContext.Copy(), block.Header(), nil
+		}
+		pocContext, err := b.eth.BlockChain().PocContextAt(b.eth.blockchain.CurrentBlock().Header())
+		return pocContext, block.Header(), err
+	}
+	// Otherwise resolve the block number and return its poc context
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	pocContext, err := b.eth.BlockChain().PocContextAt(header)
+	return pocContext, header, err
+}
+
 func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.eth.blockchain.GetBlockByHash(blockHash), nil
 }
@@ -116,6 +135,11 @@ func (b *EthApiBackend) GetReceipts(ctx context.Context, blockHash common.Hash)
 	return core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash)), nil
 }
 
+func (b *EthApiBackend) GetRecordHistory(ctx context.Context, key common.Hash) ([]core.RecordHistoryEntry, bool, error) {
+	history, pending := recordHistory(b.eth.chainDb, b.eth.chainConfig, b.eth.recordHistoryIndexer, b.eth.blockchain, key)
+	return history, pending, nil
+}
+
 func (b *EthApiBackend) GetTd(blockHash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(blockHash)
 }
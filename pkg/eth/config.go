@@ -26,6 +26,7 @@ import (
 	"math/big"
 	"os"
 	"os/user"
+	"time"
 )
 
 // DefaultConfig contains default settings for use on the Ethereum main net.
@@ -36,6 +37,8 @@ var DefaultConfig = Config{
 	DatabaseCache: 128,
 	GasPrice:      big.NewInt(18 * params.Shannon),
 
+	MinerBuildTime: 10 * time.Second,
+
 	TxPool: core.DefaultTxPoolConfig,
 }
 
@@ -74,6 +77,10 @@ type Config struct {
 	MinerThreads int            `toml:",omitempty"`
 	ExtraData    []byte         `toml:",omitempty"`
 
+	// Maximum time the miner spends filling a block, kept well below the slot
+	// so that the sealed block reaches the next validator in time
+	MinerBuildTime time.Duration `toml:",omitempty"`
+
 	GasPrice *big.Int
 
 	// Transaction pool options
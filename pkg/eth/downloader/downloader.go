@@ -1455,7 +1455,7 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	if err := d.syncState(b.Root()).Wait(); err != nil {
 		return err
 	}
-	if err := d.syncPocContextState(b.Header().PocContext); err != nil {
+	if err := d.syncPivotState(b.Header()).Wait(); err != nil {
 		return err
 	}
 	log.Debug("Committing fast sync pivot as new head", "number", b.Number(), "hash", b.Hash())
@@ -1465,23 +1465,6 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	return d.blockchain.FastSyncCommitHead(b.Hash())
 }
 
-// Todo: sync poc context in concurrent
-func (d *Downloader) syncPocContextState(context *types.PocContextProto) error {
-	roots := []common.Hash{
-		context.CandidateHash,
-		context.ContributionHash,
-		context.LatestTxHash,
-		context.EpochHash,
-		context.MintCntHash,
-	}
-	for _, root := range roots {
-		if err := d.syncState(root).Wait(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // DeliverHeaders injects a new batch of block headers received from a remote
 // node into the download schedule.
 func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) (err error) {
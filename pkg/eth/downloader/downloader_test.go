@@ -25,7 +25,9 @@ import (
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/event"
 	"AQChainRe/pkg/params"
+	"AQChainRe/pkg/rlp"
 	"AQChainRe/pkg/trie"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
@@ -41,6 +43,9 @@ var (
 	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
 )
 
+// testEpochInterval is the length of a poc epoch in seconds.
+const testEpochInterval = 360
+
 // Reduce some of the parameters to make the tester faster.
 func init() {
 	MaxForkAncestry = uint64(10000)
@@ -103,7 +108,7 @@ func newTester() *downloadTester {
 
 // makeChain creates a chain of n blocks starting at and including parent.
 // the returned hash chain is ordered head->parent. In addition, every 3rd block
-// contains a transaction and every 5th an uncle to allow testing correct block
+// contains a transaction and a record confirmation and every 5th an uncle to allow testing correct block
 // reassembly.
 func (dl *downloadTester) makeChain(n int, seed byte, parent *types.Block, parentReceipts types.Receipts, heavy bool) ([]common.Hash, map[common.Hash]*types.Header, map[common.Hash]*types.Block, map[common.Hash]types.Receipts) {
 	// Generate the block chain
@@ -122,6 +127,15 @@ func (dl *downloadTester) makeChain(n int, seed byte, parent *types.Block, paren
 				panic(err)
 			}
 			block.AddTx(tx)
+
+			// Confirm a record with a royalty too, so that fast sync has a record
+			// trie and a record storage trie to retrieve
+			payload := append([]byte("record-"), block.Number().Bytes()...)
+			tx, err = types.SignTx(types.NewTransaction(types.ConfirmationData, block.TxNonce(testAddress), common.Address{}, nil, nil, nil, payload), signer, testKey)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
 		}
 		// If the block number is a multiple of 5, add a bonus uncle to the block
 		if i > 0 && i%5 == 0 {
@@ -154,6 +168,50 @@ func (dl *downloadTester) makeChain(n int, seed byte, parent *types.Block, paren
 	return hashes, headerm, blockm, receiptm
 }
 
+// makePocChain creates a chain of n blocks like makeChain, additionally
+// maintaining the poc context tries the way the poc engine does: every block
+// bumps the mint count and latest transaction of its coinbase, and the first
+// block of every epoch elects a new validator set.
+func (dl *downloadTester) makePocChain(n int, parent *types.Block) ([]common.Hash, map[common.Hash]*types.Header, map[common.Hash]*types.Block, map[common.Hash]types.Receipts) {
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, parent, dl.peerDb, n, func(i int, block *core.BlockGen) {
+		validator := common.Address{byte(i)}
+		block.SetCoinbase(validator)
+
+		context := block.PocContext()
+		prevTime := block.PrevBlock(i - 1).Time().Int64()
+		time := prevTime + 10 // Block time is fixed by the chain maker
+
+		epoch := make([]byte, 8)
+		binary.BigEndian.PutUint64(epoch, uint64(time/testEpochInterval))
+		if prevTime/testEpochInterval != time/testEpochInterval {
+			if err := context.BecomeCandidate(validator); err != nil {
+				panic(err)
+			}
+			if err := context.SetValidators([]common.Address{validator}); err != nil {
+				panic(err)
+			}
+		}
+		context.MintCntTrie().Update(append(epoch, validator.Bytes()...), block.Number().Bytes())
+		if err := context.SetLastedTx(types.AccountLastedTx{Account: validator, RecordTime: big.NewInt(time)}); err != nil {
+			panic(err)
+		}
+	})
+	hashes := make([]common.Hash, n+1)
+	hashes[len(hashes)-1] = parent.Hash()
+
+	headerm := map[common.Hash]*types.Header{parent.Hash(): parent.Header()}
+	blockm := map[common.Hash]*types.Block{parent.Hash(): parent}
+	receiptm := map[common.Hash]types.Receipts{parent.Hash(): nil}
+
+	for i, b := range blocks {
+		hashes[len(hashes)-i-2] = b.Hash()
+		headerm[b.Hash()] = b.Header()
+		blockm[b.Hash()] = b
+		receiptm[b.Hash()] = receipts[i]
+	}
+	return hashes, headerm, blockm, receiptm
+}
+
 // makeChainFork creates two chains of length n, such that h1[:f] and
 // h2[:f] are different but have a common suffix of length n-f.
 func (dl *downloadTester) makeChainFork(n, f int, parent *types.Block, parentReceipts types.Receipts, balanced bool) ([]common.Hash, []common.Hash, map[common.Hash]*types.Header, map[common.Hash]*types.Header, map[common.Hash]*types.Block, map[common.Hash]*types.Block, map[common.Hash]types.Receipts, map[common.Hash]types.Receipts) {
@@ -291,9 +349,15 @@ func (dl *downloadTester) CurrentFastBlock() *types.Block {
 
 // FastSyncCommitHead manually sets the head block to a given hash.
 func (dl *downloadTester) FastSyncCommitHead(hash common.Hash) error {
-	// For now only check that the state trie is correct
+	// For now only check that the state, record and poc context tries are correct
 	if block := dl.GetBlockByHash(hash); block != nil {
-		_, err := trie.NewSecure(block.Root(), dl.stateDb, 0)
+		if _, err := trie.NewSecure(block.Root(), dl.stateDb, 0); err != nil {
+			return err
+		}
+		if _, err := trie.NewSecure(block.RecordRoot(), dl.stateDb, 0); err != nil {
+			return err
+		}
+		_, err := types.NewPocContextFromProto(dl.stateDb, block.Header().PocContext)
 		return err
 	}
 	return fmt.Errorf("non existent block: %x", hash[:4])
@@ -660,13 +724,47 @@ func assertOwnForkedChain(t *testing.T, tester *downloadTester, common int, leng
 			index = len(tester.ownHashes) - lengths[len(lengths)-1] + int(tester.downloader.queue.fastSyncPivot)
 		}
 		if index > 0 {
-			if statedb, err := state.New(tester.ownHeaders[tester.ownHashes[index]].Root, state.NewDatabase(tester.stateDb)); statedb == nil || err != nil {
+			header := tester.ownHeaders[tester.ownHashes[index]]
+			if statedb, err := state.New(header.Root, state.NewDatabase(tester.stateDb)); statedb == nil || err != nil {
 				t.Fatalf("state reconstruction failed: %v", err)
 			}
+			if err := checkRecordTrie(tester.stateDb, header.RecordRoot); err != nil {
+				t.Fatalf("record state reconstruction failed: %v", err)
+			}
 		}
 	}
 }
 
+// checkRecordTrie walks the record trie and the storage trie of every record,
+// failing if any node of them is missing from the database.
+func checkRecordTrie(db ethdb.Database, root common.Hash) error {
+	tr, err := trie.New(root, db)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		var record state.Record
+		if err := rlp.DecodeBytes(it.LeafBlob(), &record); err != nil {
+			return err
+		}
+		storage, err := trie.New(record.Root, db)
+		if err != nil {
+			return err
+		}
+		storageIt := storage.NodeIterator(nil)
+		for storageIt.Next(true) {
+		}
+		if storageIt.Error() != nil {
+			return storageIt.Error()
+		}
+	}
+	return it.Error()
+}
+
 // Tests that simple synchronization against a canonical chain works correctly.
 // In this test common ancestor lookup should be short circuited and not require
 // binary searching.
@@ -698,6 +796,62 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that fast sync retrieves the prefixed poc context tries of the pivot
+// block, such that the context can be reopened after the chain crossed several
+// epoch boundaries.
+func TestPocContextSync63Fast(t *testing.T) { testPocContextSync(t, 63) }
+func TestPocContextSync64Fast(t *testing.T) { testPocContextSync(t, 64) }
+
+func testPocContextSync(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain long enough for any random pivot to be past an epoch boundary
+	targetBlocks := fsMinFullBlocks + fsPivotInterval + 2*testEpochInterval/10
+	hashes, headers, blocks, receipts := tester.makePocChain(targetBlocks, tester.genesis)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	// Reopen the poc context at the pivot and cross check it with the peer's
+	pivot := tester.ownHeaders[tester.ownHashes[tester.downloader.queue.fastSyncPivot]]
+	if epoch := pivot.Time.Int64() / testEpochInterval; epoch == 0 {
+		t.Fatalf("pivot #%d did not cross an epoch boundary", pivot.Number)
+	}
+	context, err := types.NewPocContextFromProto(tester.stateDb, pivot.PocContext)
+	if err != nil {
+		t.Fatalf("failed to open pivot poc context: %v", err)
+	}
+	tries := []*trie.Trie{context.EpochTrie(), context.CandidateTrie(), context.ContributionTrie(), context.LatestTxTrie(), context.MintCntTrie()}
+	for i, tr := range tries {
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if it.Error() != nil {
+			t.Fatalf("poc context trie %d incomplete: %v", i, it.Error())
+		}
+	}
+	want, err := types.NewPocContextFromProto(tester.peerDb, pivot.PocContext)
+	if err != nil {
+		t.Fatalf("failed to open peer poc context: %v", err)
+	}
+	if have, want := context.ToProto().Root(), want.ToProto().Root(); have != want {
+		t.Fatalf("poc context root mismatch: have %x, want %x", have, want)
+	}
+	validators, err := context.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to retrieve validators: %v", err)
+	}
+	if len(validators) != 1 || validators[0] == (common.Address{}) {
+		t.Fatalf("pivot validators mismatch: have %v, want an elected validator", validators)
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
@@ -19,6 +19,7 @@ package downloader
 import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/crypto/sha3"
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/log"
@@ -59,7 +60,31 @@ type stateSyncStats struct {
 
 // syncState starts downloading state with the given root hash.
 func (d *Downloader) syncState(root common.Hash) *stateSync {
-	s := newStateSync(d, root)
+	return d.startStateSync(newStateSync(d, state.NewStateSync(root, d.stateDB)))
+}
+
+// syncPivotState starts downloading the record trie and the poc context tries
+// of a fast sync pivot header. All of them are scheduled into a single trie
+// sync so they are retrieved concurrently rather than one root at a time.
+func (d *Downloader) syncPivotState(header *types.Header) *stateSync {
+	sched := state.NewRecordSync(header.RecordRoot, d.stateDB)
+	if context := header.PocContext; context != nil {
+		roots := []common.Hash{
+			context.CandidateHash,
+			context.ContributionHash,
+			context.LatestTxHash,
+			context.EpochHash,
+			context.MintCntHash,
+		}
+		for _, root := range roots {
+			sched.AddSubTrie(root, 0, common.Hash{}, nil)
+		}
+	}
+	return d.startStateSync(newStateSync(d, sched))
+}
+
+// startStateSync hands a prepared state sync over to the state fetcher.
+func (d *Downloader) startStateSync(s *stateSync) *stateSync {
 	select {
 	case d.stateSyncStart <- s:
 	case <-d.quitCh:
@@ -231,12 +256,12 @@ type stateTask struct {
 	attempts map[string]struct{}
 }
 
-// newStateSync creates a new state trie download scheduler. This method does not
-// yet start the sync. The user needs to call run to initiate.
-func newStateSync(d *Downloader, root common.Hash) *stateSync {
+// newStateSync creates a new state download around the given trie scheduler.
+// This method does not yet start the sync. The user needs to call run to initiate.
+func newStateSync(d *Downloader, sched *trie.TrieSync) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
+		sched:   sched,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -265,6 +265,46 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// Records creates a subscription that fires for the record events of imported
+// blocks matching the given criteria, such as confirmations, transfers,
+// authorizations and status changes. Events of blocks removed by a chain
+// reorganisation are sent again with removed set to true.
+func (api *PublicFilterAPI) Records(ctx context.Context, crit RecordCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if err := crit.validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		rpcSub        = notifier.CreateSubscription()
+		matchedEvents = make(chan []*RecordEvent)
+	)
+
+	recordsSub := api.events.SubscribeRecords(crit, matchedEvents)
+
+	go func() {
+		for {
+			select {
+			case events := <-matchedEvents:
+				for _, ev := range events {
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				recordsSub.Unsubscribe()
+				return
+			case <-notifier.Closed(): // connection dropped
+				recordsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 type FilterCriteria struct {
 	FromBlock *big.Int
@@ -21,6 +21,7 @@ package filters
 import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
 	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/event"
 	"AQChainRe/pkg/rpc"
@@ -49,6 +50,8 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// RecordsSubscription queries decoded record events of new or removed (chain reorg) logs
+	RecordsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -71,26 +74,29 @@ var (
 )
 
 type subscription struct {
-	id        rpc.ID
-	typ       Type
-	created   time.Time
-	logsCrit  FilterCriteria
-	logs      chan []*types.Log
-	hashes    chan common.Hash
-	headers   chan *types.Header
-	installed chan struct{} // closed when the filter is installed
-	err       chan error    // closed when the filter is uninstalled
+	id          rpc.ID
+	typ         Type
+	created     time.Time
+	logsCrit    FilterCriteria
+	logs        chan []*types.Log
+	hashes      chan common.Hash
+	headers     chan *types.Header
+	recordsCrit RecordCriteria
+	records     chan []*RecordEvent
+	installed   chan struct{} // closed when the filter is installed
+	err         chan error    // closed when the filter is uninstalled
 }
 
 // EventSystem creates subscriptions, processes events and broadcasts them to the
 // subscription which match the subscription criteria.
 type EventSystem struct {
-	mux       *event.TypeMux
-	backend   Backend
-	lightMode bool
-	lastHead  *types.Header
-	install   chan *subscription // install filter for event notification
-	uninstall chan *subscription // remove filter for event notification
+	mux         *event.TypeMux
+	backend     Backend
+	recordState state.Database // record state to look up the origin of record events, nil in light mode
+	lightMode   bool
+	lastHead    *types.Header
+	install     chan *subscription // install filter for event notification
+	uninstall   chan *subscription // remove filter for event notification
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -107,6 +113,9 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 		install:   make(chan *subscription),
 		uninstall: make(chan *subscription),
 	}
+	if !lightMode {
+		m.recordState = state.NewDatabase(backend.ChainDb())
+	}
 
 	go m.eventLoop()
 
@@ -141,6 +150,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.records:
 			}
 		}
 
@@ -248,6 +258,25 @@ func (es *EventSystem) subscribePendingLogs(crit FilterCriteria, logs chan []*ty
 	return es.subscribe(sub)
 }
 
+// SubscribeRecords creates a subscription that writes the record events of
+// imported blocks matching the given criteria, and writes them again marked
+// as removed when their block leaves the canonical chain.
+func (es *EventSystem) SubscribeRecords(crit RecordCriteria, records chan []*RecordEvent) *Subscription {
+	sub := &subscription{
+		id:          rpc.NewID(),
+		typ:         RecordsSubscription,
+		created:     time.Now(),
+		logs:        make(chan []*types.Log),
+		hashes:      make(chan common.Hash),
+		headers:     make(chan *types.Header),
+		recordsCrit: crit,
+		records:     records,
+		installed:   make(chan struct{}),
+		err:         make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 // SubscribeNewHeads creates a subscription that writes the header of a block that is
 // imported in the chain.
 func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscription {
@@ -296,6 +325,7 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 					f.logs <- matchedLogs
 				}
 			}
+			es.broadcastRecords(filters, e)
 		}
 	case core.RemovedLogsEvent:
 		for _, f := range filters[LogsSubscription] {
@@ -303,6 +333,7 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				f.logs <- matchedLogs
 			}
 		}
+		es.broadcastRecords(filters, e.Logs)
 	case *event.TypeMuxEvent:
 		switch muxe := e.Data.(type) {
 		case core.PendingLogsEvent:
@@ -322,18 +353,35 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
 		}
-		if es.lightMode && len(filters[LogsSubscription]) > 0 {
+		if es.lightMode && (len(filters[LogsSubscription]) > 0 || len(filters[RecordsSubscription]) > 0) {
 			es.lightFilterNewHead(e.Block.Header(), func(header *types.Header, remove bool) {
 				for _, f := range filters[LogsSubscription] {
 					if matchedLogs := es.lightFilterLogs(header, f.logsCrit.Addresses, f.logsCrit.Topics, remove); len(matchedLogs) > 0 {
 						f.logs <- matchedLogs
 					}
 				}
+				if len(filters[RecordsSubscription]) > 0 {
+					es.broadcastRecords(filters, es.lightFilterLogs(header, nil, recordTopics, remove))
+				}
 			})
 		}
 	}
 }
 
+// broadcastRecords sends the record events decoded from the logs to the
+// record subscriptions whose criteria they match.
+func (es *EventSystem) broadcastRecords(filters filterIndex, logs []*types.Log) {
+	if len(filters[RecordsSubscription]) == 0 {
+		return
+	}
+	events := es.recordEvents(logs)
+	for _, f := range filters[RecordsSubscription] {
+		if matched := f.recordsCrit.filter(events); len(matched) > 0 {
+			f.records <- matched
+		}
+	}
+}
+
 func (es *EventSystem) lightFilterNewHead(newHeader *types.Header, callBack func(*types.Header, bool)) {
 	oldh := es.lastHead
 	es.lastHead = newHeader
@@ -20,6 +20,7 @@ import (
 	"AQChainRe/pkg/common"
 	"AQChainRe/pkg/core"
 	"AQChainRe/pkg/core/bloombits"
+	"AQChainRe/pkg/core/state"
 	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/event"
@@ -556,3 +557,111 @@ func TestPendingLogsSubscription(t *testing.T) {
 		}
 	}
 }
+
+// TestRecordSubscription tests that record subscriptions receive the decoded
+// record events matching their criteria, and receive them again marked as
+// removed when the logs are rolled back by a reorg.
+func TestRecordSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db, _      = ethdb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		api        = NewPublicFilterAPI(backend, false)
+
+		origin = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		buyer  = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		record = common.HexToHash("0x3333333333333333333333333333333333333333333333333333333333333333")
+		other  = common.HexToHash("0x4444444444444444444444444444444444444444444444444444444444444444")
+
+		status  = common.BytesToHash([]byte{1})
+		allLogs = []*types.Log{
+			{Address: origin, Topics: []common.Hash{core.RecordConfirmedTopic, record, origin.Hash()}, BlockNumber: 1},
+			{Address: origin, Topics: []common.Hash{core.RecordConfirmedTopic, other, origin.Hash()}, BlockNumber: 1},
+			{Address: origin, Topics: []common.Hash{core.RecordTransferredTopic, record, origin.Hash(), buyer.Hash()}, BlockNumber: 2},
+			{Address: buyer, Topics: []common.Hash{core.RecordStatusChangedTopic, record, buyer.Hash(), status}, BlockNumber: 3},
+			{Address: buyer, Topics: []common.Hash{core.CandidateLoginTopic, buyer.Hash()}, BlockNumber: 3},
+		}
+
+		testCases = []struct {
+			crit     RecordCriteria
+			expected []string
+			c        chan []*RecordEvent
+			sub      *Subscription
+		}{
+			// match all record events, skipping the candidate login
+			{RecordCriteria{}, []string{RecordConfirmed, RecordConfirmed, RecordTransferred, RecordStatusChanged}, nil, nil},
+			// match the events of a single record
+			{RecordCriteria{RecordKeys: []common.Hash{record}}, []string{RecordConfirmed, RecordTransferred, RecordStatusChanged}, nil, nil},
+			// match the events the buyer owns the record before or after
+			{RecordCriteria{Owner: &buyer}, []string{RecordTransferred, RecordStatusChanged}, nil, nil},
+			// match by origin and action
+			{RecordCriteria{Origin: &origin, Actions: []string{RecordTransferred}}, []string{RecordTransferred}, nil, nil},
+		}
+	)
+	if err := (&RecordCriteria{Actions: []string{"burned"}}).validate(); err == nil {
+		t.Fatalf("unknown action accepted")
+	}
+	// the origin of transfers and status changes is looked up in the record state of their blocks
+	statedbRecord, _ := state.NewRecord(common.Hash{}, state.NewDatabase(db))
+	statedbRecord.GetOrNewStateObject(record).SetOrigin(origin)
+	root, _ := statedbRecord.CommitTo(db, false)
+	for _, log := range allLogs {
+		header := &types.Header{Number: new(big.Int).SetUint64(log.BlockNumber), RecordRoot: root, PocContext: &types.PocContextProto{}}
+		core.WriteHeader(db, header)
+		log.BlockHash = header.Hash()
+	}
+	for i := range testCases {
+		testCases[i].c = make(chan []*RecordEvent, 2) // imported and removed events
+		testCases[i].sub = api.events.SubscribeRecords(testCases[i].crit, testCases[i].c)
+	}
+
+	// the logs are imported and then removed by a reorg
+	go func() {
+		logsFeed.Send(allLogs)
+		removed := make([]*types.Log, len(allLogs))
+		for i, log := range allLogs {
+			copied := *log
+			copied.Removed = true
+			removed[i] = &copied
+		}
+		rmLogsFeed.Send(core.RemovedLogsEvent{Logs: removed})
+	}()
+
+	for i, tt := range testCases {
+		var fetched []*RecordEvent
+		timeout := time.After(1 * time.Second)
+	fetchLoop:
+		for {
+			select {
+			case events := <-tt.c:
+				fetched = append(fetched, events...)
+				if len(fetched) >= 2*len(tt.expected) {
+					break fetchLoop
+				}
+			case <-timeout:
+				break fetchLoop
+			}
+		}
+		if len(fetched) != 2*len(tt.expected) {
+			t.Fatalf("invalid number of events for case %d, want %d, got %d", i, 2*len(tt.expected), len(fetched))
+		}
+		for j, ev := range fetched {
+			if ev.Action != tt.expected[j%len(tt.expected)] {
+				t.Errorf("case %d: event %d action mismatch: have %s, want %s", i, j, ev.Action, tt.expected[j%len(tt.expected)])
+			}
+			if ev.Origin != origin {
+				t.Errorf("case %d: event %d origin mismatch: have %x", i, j, ev.Origin)
+			}
+			if ev.Removed != (j >= len(tt.expected)) {
+				t.Errorf("case %d: event %d removed flag mismatch", i, j)
+			}
+		}
+		tt.sub.Unsubscribe()
+	}
+}
@@ -0,0 +1,188 @@
+package filters
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/common/hexutil"
+	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"fmt"
+)
+
+// 记录事件的动作
+const (
+	RecordConfirmed     = "confirmed"
+	RecordTransferred   = "transferred"
+	RecordAuthorized    = "authorized"
+	RecordStatusChanged = "statusChanged"
+)
+
+// recordTopics matches the logs of all record events.
+var recordTopics = [][]common.Hash{{
+	core.RecordConfirmedTopic, core.RecordTransferredTopic, core.AuthorizationGrantedTopic, core.RecordStatusChangedTopic,
+}}
+
+// RecordCriteria represents a request to subscribe to record events. Empty
+// fields match all events.
+type RecordCriteria struct {
+	Owner      *common.Address `json:"owner"`      // 事件前后的拥有者
+	Origin     *common.Address `json:"origin"`     // 记录的原始作者
+	RecordKeys []common.Hash   `json:"recordKeys"` // 记录键
+	Actions    []string        `json:"actions"`    // 事件动作
+}
+
+// validate checks that all requested actions are known.
+func (crit *RecordCriteria) validate() error {
+	for _, action := range crit.Actions {
+		switch action {
+		case RecordConfirmed, RecordTransferred, RecordAuthorized, RecordStatusChanged:
+		default:
+			return fmt.Errorf("unknown record action %q", action)
+		}
+	}
+	return nil
+}
+
+// RecordEvent is a decoded data-record log. Events of logs removed by a chain
+// reorganisation are sent again with Removed set.
+type RecordEvent struct {
+	Action      string          `json:"action"`
+	Record      common.Hash     `json:"record"`
+	Origin      common.Address  `json:"origin"`
+	From        common.Address  `json:"from"` // 事件前的拥有者, 授权时为授权的拥有者
+	To          common.Address  `json:"to"`   // 事件后的拥有者, 授权时为被授权者
+	Status      *hexutil.Uint64 `json:"status,omitempty"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	TxHash      common.Hash     `json:"transactionHash"`
+	TxIndex     hexutil.Uint    `json:"transactionIndex"`
+	Removed     bool            `json:"removed"`
+}
+
+// decodeRecordEvent turns a record log into an event, returning nil for logs
+// of other events. Only confirmations carry the origin of the record.
+func decodeRecordEvent(log *types.Log) *RecordEvent {
+	if len(log.Topics) < 3 {
+		return nil
+	}
+	ev := &RecordEvent{
+		Record:      log.Topics[1],
+		BlockNumber: hexutil.Uint64(log.BlockNumber),
+		BlockHash:   log.BlockHash,
+		TxHash:      log.TxHash,
+		TxIndex:     hexutil.Uint(log.TxIndex),
+		Removed:     log.Removed,
+	}
+	switch log.Topics[0] {
+	case core.RecordConfirmedTopic:
+		ev.Action = RecordConfirmed
+		ev.Origin = common.BytesToAddress(log.Topics[2].Bytes())
+		ev.To = ev.Origin
+
+	case core.RecordTransferredTopic, core.AuthorizationGrantedTopic:
+		if len(log.Topics) < 4 {
+			return nil
+		}
+		ev.Action = RecordTransferred
+		if log.Topics[0] == core.AuthorizationGrantedTopic {
+			ev.Action = RecordAuthorized
+		}
+		ev.From = common.BytesToAddress(log.Topics[2].Bytes())
+		ev.To = common.BytesToAddress(log.Topics[3].Bytes())
+
+	case core.RecordStatusChangedTopic:
+		if len(log.Topics) < 4 {
+			return nil
+		}
+		status := hexutil.Uint64(log.Topics[3].Big().Uint64())
+		ev.Action = RecordStatusChanged
+		ev.Status = &status
+		ev.From = common.BytesToAddress(log.Topics[2].Bytes())
+		ev.To = ev.From
+
+	default:
+		return nil
+	}
+	return ev
+}
+
+// recordEvents decodes the record logs into events. The origin of the record
+// is looked up in the record state committed by the block of the log; as the
+// origin never changes, this holds for logs removed by a reorg as well. Light
+// clients have no state and leave the origin of other than confirmations empty.
+func (es *EventSystem) recordEvents(logs []*types.Log) []*RecordEvent {
+	var (
+		events []*RecordEvent
+		states = make(map[common.Hash]*state.StateDBRecord)
+	)
+	for _, log := range filterLogs(logs, nil, nil, nil, recordTopics) {
+		ev := decodeRecordEvent(log)
+		if ev == nil {
+			continue
+		}
+		if ev.Action != RecordConfirmed && es.recordState != nil {
+			statedb, ok := states[log.BlockHash]
+			if !ok {
+				if header := core.GetHeader(es.backend.ChainDb(), log.BlockHash, log.BlockNumber); header != nil {
+					statedb, _ = state.NewRecord(header.RecordRoot, es.recordState)
+				}
+				states[log.BlockHash] = statedb
+			}
+			if statedb != nil {
+				ev.Origin = statedb.GetOrigin(ev.Record)
+			}
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// matches reports whether the event satisfies the criteria.
+func (crit *RecordCriteria) matches(ev *RecordEvent) bool {
+	if len(crit.Actions) > 0 && !includesAction(crit.Actions, ev.Action) {
+		return false
+	}
+	if len(crit.RecordKeys) > 0 && !includesHash(crit.RecordKeys, ev.Record) {
+		return false
+	}
+	if crit.Origin != nil && *crit.Origin != ev.Origin {
+		return false
+	}
+	if crit.Owner != nil {
+		// 被授权者不是拥有者
+		owner := *crit.Owner
+		if ev.From != owner && (ev.Action == RecordAuthorized || ev.To != owner) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter returns the events satisfying the criteria.
+func (crit *RecordCriteria) filter(events []*RecordEvent) []*RecordEvent {
+	var matched []*RecordEvent
+	for _, ev := range events {
+		if crit.matches(ev) {
+			matched = append(matched, ev)
+		}
+	}
+	return matched
+}
+
+func includesAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func includesHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package eth
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/log"
+	"AQChainRe/pkg/params"
+	"sort"
+	"time"
+)
+
+const (
+	// recordHistoryBlocks is the number of blocks in a single record history
+	// index section.
+	recordHistoryBlocks = 64
+
+	// recordHistoryConfirms is the number of confirmation blocks before a record
+	// history section is considered final and indexed. Blocks newer than the
+	// last indexed section are scanned directly when answering queries.
+	recordHistoryConfirms = 16
+
+	// recordHistoryThrottling is the time to wait between processing two
+	// consecutive index sections.
+	recordHistoryThrottling = 10 * time.Millisecond
+
+	// recordHistoryScanLimit is the maximum number of not yet indexed blocks
+	// scanned directly for a single query. While the indexer catches up, the
+	// blocks past the limit are reported as pending instead.
+	recordHistoryScanLimit = 2*recordHistoryBlocks + recordHistoryConfirms
+)
+
+// RecordHistoryIndexer implements a core.ChainIndexer, building up a per-record
+// index of the data transactions touching it for provenance queries.
+type RecordHistoryIndexer struct {
+	db     ethdb.Database      // database instance to read blocks from and write index data into
+	state  state.Database      // state database to resolve key grants on encrypted records
+	config *params.ChainConfig // chain config to derive transaction senders
+
+	section uint64                                    // Section is the section number being processed currently
+	history map[common.Hash][]core.RecordHistoryEntry // History entries collected for the current section
+}
+
+// NewRecordHistoryIndexer returns a chain indexer that generates the record
+// provenance index for the canonical chain.
+func NewRecordHistoryIndexer(db ethdb.Database, config *params.ChainConfig) *core.ChainIndexer {
+	backend := &RecordHistoryIndexer{
+		db:     db,
+		state:  state.NewDatabase(db),
+		config: config,
+	}
+	table := ethdb.NewTable(db, string(core.RecordHistoryIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, recordHistoryBlocks, recordHistoryConfirms, recordHistoryThrottling, "recordhistory")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new record history
+// index section.
+func (r *RecordHistoryIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	r.section, r.history = section, make(map[common.Hash][]core.RecordHistoryEntry)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, collecting the record history
+// of a new block into the section.
+func (r *RecordHistoryIndexer) Process(header *types.Header) {
+	number := header.Number.Uint64()
+	block := core.GetBlock(r.db, header.Hash(), number)
+	if block == nil {
+		log.Error("Record history block missing", "number", number, "hash", header.Hash())
+		return
+	}
+	receipts := core.GetBlockReceipts(r.db, block.Hash(), number)
+	for key, entries := range core.DeriveRecordHistory(r.config, block, receipts, recordHistoryState(r.db, r.state, header)) {
+		r.history[key] = append(r.history[key], entries...)
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, writing the section's record
+// history out into the database.
+func (r *RecordHistoryIndexer) Commit() error {
+	return core.WriteRecordHistorySection(r.db, r.section, r.history)
+}
+
+// recordHistoryState opens the record state a block committed to, resolving the
+// key grants of its transactions. Nodes missing the state of old blocks, like
+// fast synced ones, fall back to the state of the head block.
+func recordHistoryState(db ethdb.Database, database state.Database, header *types.Header) *state.StateDBRecord {
+	if statedb, err := state.NewRecord(header.RecordRoot, database); err == nil {
+		return statedb
+	}
+	hash := core.GetHeadBlockHash(db)
+	if head := core.GetHeader(db, hash, core.GetBlockNumber(db, hash)); head != nil {
+		if statedb, err := state.NewRecord(head.RecordRoot, database); err == nil {
+			return statedb
+		}
+	}
+	statedb, _ := state.NewRecord(common.Hash{}, database)
+	return statedb
+}
+
+// recordHistory assembles the canonical provenance history of a record from
+// the indexed sections and the not yet indexed head of the chain. At most
+// recordHistoryScanLimit unindexed blocks are scanned; if more are left the
+// history is incomplete and pending is set.
+func recordHistory(db ethdb.Database, config *params.ChainConfig, indexer *core.ChainIndexer, chain *core.BlockChain, key common.Hash) (history []core.RecordHistoryEntry, pending bool) {
+	sections, _, _ := indexer.Sections()
+
+	for _, section := range core.GetRecordHistorySections(db, key) {
+		if section >= sections {
+			continue
+		}
+		for _, entry := range core.GetRecordHistory(db, key, section) {
+			// Entries of reorged blocks are skipped, the section will be reindexed
+			if core.GetCanonicalHash(db, entry.BlockNumber) == entry.BlockHash {
+				history = append(history, entry)
+			}
+		}
+	}
+	head := chain.CurrentBlock().NumberU64()
+	first := sections * recordHistoryBlocks
+	if head >= first+recordHistoryScanLimit {
+		head, pending = first+recordHistoryScanLimit-1, true
+	}
+	for number := first; number <= head; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			break
+		}
+		receipts := core.GetBlockReceipts(db, block.Hash(), number)
+		history = append(history, core.DeriveRecordHistory(config, block, receipts, recordHistoryState(db, chain.StateCache(), block.Header()))[key]...)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		if history[i].BlockNumber != history[j].BlockNumber {
+			return history[i].BlockNumber < history[j].BlockNumber
+		}
+		return history[i].TxIndex < history[j].TxIndex
+	})
+	return history, pending
+}
@@ -0,0 +1,67 @@
+package eth
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/consensus/ethash"
+	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/params"
+	"math/big"
+	"testing"
+)
+
+// Tests that a record history query scans a bounded number of unindexed blocks,
+// flagging the history as pending while the indexer lags further behind.
+func TestRecordHistoryScanLimit(t *testing.T) {
+	testRecordHistoryScanLimit(t, recordHistoryScanLimit/2, false)
+	testRecordHistoryScanLimit(t, recordHistoryScanLimit+10, true)
+}
+
+func testRecordHistoryScanLimit(t *testing.T, blocks int, pending bool) {
+	var (
+		db, _ = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = core.NewBlockChain(db, gspec.Config, ethash.NewFaker())
+		signer        = types.MakeSigner(gspec.Config, big.NewInt(1))
+		payload       = []byte("document")
+	)
+	defer blockchain.Stop()
+
+	// Confirm a record in the first block and authorize it in the last one
+	chain, _ := core.GenerateChain(gspec.Config, genesis, db, blocks, func(i int, block *core.BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0:
+			tx, _ = types.SignTx(types.NewTransaction(types.ConfirmationData, block.TxNonce(testBank), common.Address{}, nil, nil, nil, payload), signer, testBankKey)
+		case blocks - 1:
+			tx, _ = types.SignTx(types.NewTransaction(types.AuthorizationData, block.TxNonce(testBank), common.Address{0x01}, nil, nil, nil, payload), signer, testBankKey)
+		default:
+			return
+		}
+		block.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// The indexer is never started, so no section is indexed
+	indexer := NewRecordHistoryIndexer(db, gspec.Config)
+	defer indexer.Close()
+
+	key := core.RecordKeys(gspec.Config, chain[0].Number(), chain[0].Transactions()[0], nil)[0]
+	history, lagging := recordHistory(db, gspec.Config, indexer, blockchain, key)
+	if lagging != pending {
+		t.Fatalf("%d blocks: pending mismatch: have %v, want %v", blocks, lagging, pending)
+	}
+	want := 2
+	if pending {
+		want = 1
+	}
+	if len(history) != want || history[0].Type != types.ConfirmationData {
+		t.Fatalf("%d blocks: history mismatch: have %+v, want %d entries", blocks, history, want)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"AQChainRe/pkg/common/hexutil"
 	"AQChainRe/pkg/common/math"
 	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
 	"AQChainRe/pkg/core/types"
 	"AQChainRe/pkg/crypto"
 	"AQChainRe/pkg/log"
@@ -44,6 +45,9 @@ import (
 const (
 	defaultGas      = 90000
 	defaultGasPrice = 50 * params.Shannon
+
+	// maxOwnedRecordsPage caps the number of records returned by a single eth_getRecordsByOwner call
+	maxOwnedRecordsPage = 1000
 )
 
 // PublicEthereumAPI provides an API to access Ethereum related information.
@@ -365,6 +369,129 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 	return submitTransaction(ctx, s.b, signed)
 }
 
+// EncryptedRecordResult is the payload of an EncryptedConfirmationData
+// transaction along with the key of the record it will create.
+type EncryptedRecordResult struct {
+	Record  common.Hash   `json:"record"`
+	Payload hexutil.Bytes `json:"payload"`
+}
+
+// PublicKey returns the uncompressed public key of an account, which other
+// parties need to wrap record content keys to it.
+func (s *PrivateAccountAPI) PublicKey(account common.Address, passwd string) (hexutil.Bytes, error) {
+	pub, err := fetchKeystore(s.am).PublicKeyWithPassphrase(accounts.Account{Address: account}, passwd)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.FromECDSAPub(pub), nil
+}
+
+// EncryptRecord encrypts a record payload under a fresh content key wrapped
+// to the public key of the owner, as returned by personal_publicKey. The
+// royalty in basis points is paid to the origin on every sale of the record.
+// It sits in the personal namespace so that plaintexts are only sent to nodes
+// trusted with the account keys.
+func (s *PrivateAccountAPI) EncryptRecord(plaintext hexutil.Bytes, owner hexutil.Bytes, royalty hexutil.Uint64) (*EncryptedRecordResult, error) {
+	pub := crypto.ToECDSAPub(owner)
+	if pub == nil || pub.X == nil {
+		return nil, errors.New("invalid owner public key")
+	}
+	if uint64(royalty) > params.MaxRoyalty {
+		return nil, types.ErrInvalidRoyalty
+	}
+	record, err := types.SealRecord(plaintext, pub)
+	if err != nil {
+		return nil, err
+	}
+	record.Royalty = uint64(royalty)
+	payload, err := record.EncodeToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedRecordResult{Record: record.Key(), Payload: payload}, nil
+}
+
+// DecryptRecord decrypts an encrypted record with the content key granted to
+// the account, either at confirmation or by a later authorization or transfer.
+func (s *PrivateAccountAPI) DecryptRecord(ctx context.Context, record common.Hash, account common.Address, passwd string) (hexutil.Bytes, error) {
+	ciphertext, key, err := s.recordContentKey(ctx, record, account, passwd)
+	if err != nil {
+		return nil, err
+	}
+	return types.OpenRecord(ciphertext, key)
+}
+
+// RewrapRecordKey re-encrypts the content key of a record held by the account
+// to the public key of a new party. The result is the payload of the
+// authorization, transfer or transfer offer transaction granting access.
+func (s *PrivateAccountAPI) RewrapRecordKey(ctx context.Context, record common.Hash, account common.Address, passwd string, recipient hexutil.Bytes) (hexutil.Bytes, error) {
+	pub := crypto.ToECDSAPub(recipient)
+	if pub == nil || pub.X == nil {
+		return nil, errors.New("invalid recipient public key")
+	}
+	_, key, err := s.recordContentKey(ctx, record, account, passwd)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := types.WrapContentKey(key, pub)
+	if err != nil {
+		return nil, err
+	}
+	return (&types.KeyGrant{Record: record, WrappedKey: wrapped}).EncodeToBytes()
+}
+
+// recordContentKey walks the transactions of an encrypted record for its
+// ciphertext and the most recent content key wrapped to the account.
+func (s *PrivateAccountAPI) recordContentKey(ctx context.Context, record common.Hash, account common.Address, passwd string) ([]byte, []byte, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if stateRecord == nil || err != nil {
+		return nil, nil, err
+	}
+	if !stateRecord.IsEncrypted(record) {
+		return nil, nil, fmt.Errorf("record %x is not encrypted", record)
+	}
+	var (
+		ciphertext []byte
+		wrapped    [][]byte
+	)
+	for _, hash := range stateRecord.GetRecordTxs(record) {
+		tx, _, number, _ := core.GetTransaction(s.b.ChainDb(), hash)
+		if tx == nil {
+			continue
+		}
+		switch tx.Type() {
+		case types.EncryptedConfirmationData:
+			confirmation, err := types.DecodeEncryptedRecord(tx.Data())
+			if err != nil {
+				continue
+			}
+			ciphertext = confirmation.Ciphertext
+			signer := types.MakeSigner(s.b.ChainConfig(), new(big.Int).SetUint64(number))
+			if from, err := types.Sender(signer, tx); err == nil && from == account {
+				wrapped = append(wrapped, confirmation.WrappedKey)
+			}
+		default:
+			grant, err := types.DecodeKeyGrant(tx.Data())
+			if err != nil || grant.Record != record || tx.To() == nil || *tx.To() != account {
+				continue
+			}
+			wrapped = append(wrapped, grant.WrappedKey)
+		}
+	}
+	if ciphertext == nil {
+		return nil, nil, fmt.Errorf("ciphertext of record %x not found", record)
+	}
+	ks := fetchKeystore(s.am)
+	for i := len(wrapped) - 1; i >= 0; i-- {
+		if key, err := ks.DecryptWithPassphrase(accounts.Account{Address: account}, passwd, wrapped[i]); err == nil && len(key) == types.ContentKeyLength {
+			return ciphertext, key, nil
+		} else if err == keystore.ErrDecrypt || err == keystore.ErrNoMatch {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, fmt.Errorf("no content key of record %x granted to %x", record, account)
+}
+
 // signHash is a helper function that calculates a hash for the given message that can be
 // safely used to calculate a signature from.
 //
@@ -476,12 +603,25 @@ func (s *PublicBlockChainAPI) GetContribution(ctx context.Context, address commo
 }
 
 func (s *PublicBlockChainAPI) RecordExist(ctx context.Context, data string, blockNr rpc.BlockNumber) (bool, error) {
-	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	stateRecord, header, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
 	if stateRecord == nil || err != nil {
 		return false, err
 	}
 	b, _ := rlp.EncodeToBytes(data)
-	return stateRecord.Exist(common.BytesToHash(b)), stateRecord.Error()
+	// 过期记录在被交易触及前仍保存在状态中
+	hash := common.BytesToHash(b)
+	return stateRecord.Exist(hash) && !stateRecord.IsExpired(hash, header.Number.Uint64()), stateRecord.Error()
+}
+
+// GetRecordExpiry returns the last block a record is valid in, zero if it never expires.
+func (s *PublicBlockChainAPI) GetRecordExpiry(ctx context.Context, data string, blockNr rpc.BlockNumber) (hexutil.Uint64, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return 0, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	expiry := stateRecord.GetExpiry(common.BytesToHash(b))
+	return hexutil.Uint64(expiry), stateRecord.Error()
 }
 
 func (s *PublicBlockChainAPI) GetOrigin(ctx context.Context, data string, blockNr rpc.BlockNumber) (common.Address, error) {
@@ -504,6 +644,426 @@ func (s *PublicBlockChainAPI) GetOwner(ctx context.Context, data string, blockNr
 	return o, stateRecord.Error()
 }
 
+// GetRoyalty returns the royalty rate in basis points paid to the origin of a record on every sale.
+func (s *PublicBlockChainAPI) GetRoyalty(ctx context.Context, data string, blockNr rpc.BlockNumber) (hexutil.Uint64, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return 0, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	royalty := stateRecord.GetRoyalty(common.BytesToHash(b))
+	return hexutil.Uint64(royalty), stateRecord.Error()
+}
+
+// GetAskPrice returns the price a record is offered for, zero if it is not for sale.
+func (s *PublicBlockChainAPI) GetAskPrice(ctx context.Context, data string, blockNr rpc.BlockNumber) (*big.Int, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	price := stateRecord.GetAskPrice(common.BytesToHash(b))
+	return price, stateRecord.Error()
+}
+
+// RPCTransferOffer is a pending two-phase transfer offer of a record.
+type RPCTransferOffer struct {
+	Record common.Hash    `json:"record"`
+	To     common.Address `json:"to"`
+	Price  *hexutil.Big   `json:"price"`
+	Expiry hexutil.Uint64 `json:"expiry"`
+}
+
+// GetTransferOffer returns the pending transfer offer of a record, or nil if
+// there is none or it has expired.
+func (s *PublicBlockChainAPI) GetTransferOffer(ctx context.Context, data string, blockNr rpc.BlockNumber) (*RPCTransferOffer, error) {
+	stateRecord, header, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	return pendingTransferOffer(stateRecord, header, common.BytesToHash(b)), stateRecord.Error()
+}
+
+// GetPendingTransfers returns the unexpired transfer offers addressed to an account.
+func (s *PublicBlockChainAPI) GetPendingTransfers(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) ([]*RPCTransferOffer, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	stateRecord, header, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	offers := []*RPCTransferOffer{}
+	for _, record := range state.PendingOffers(address) {
+		if offer := pendingTransferOffer(stateRecord, header, record); offer != nil && offer.To == address {
+			offers = append(offers, offer)
+		}
+	}
+	if err := state.Error(); err != nil {
+		return nil, err
+	}
+	return offers, stateRecord.Error()
+}
+
+func pendingTransferOffer(stateRecord *state.StateDBRecord, header *types.Header, record common.Hash) *RPCTransferOffer {
+	if stateRecord.GetStatus(record) != state.RecordPendingTransfer {
+		return nil
+	}
+	to, price, expiry := stateRecord.GetTransferOffer(record)
+	if header.Number.Uint64() > expiry {
+		return nil
+	}
+	return &RPCTransferOffer{Record: record, To: to, Price: (*hexutil.Big)(price), Expiry: hexutil.Uint64(expiry)}
+}
+
+// RPCRecordOwners is the owner set of a record and the number of co-owner
+// approvals a proposal needs. Singly owned records report their owner with a
+// threshold of one.
+type RPCRecordOwners struct {
+	Owners    []common.Address `json:"owners"`
+	Threshold hexutil.Uint64   `json:"threshold"`
+	Joint     bool             `json:"joint"`
+}
+
+// GetRecordOwners returns the owner set and approval threshold of a record.
+func (s *PublicBlockChainAPI) GetRecordOwners(ctx context.Context, data string, blockNr rpc.BlockNumber) (*RPCRecordOwners, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	record := common.BytesToHash(b)
+	if owners, threshold := stateRecord.GetJointOwners(record); len(owners) > 0 {
+		return &RPCRecordOwners{Owners: owners, Threshold: hexutil.Uint64(threshold), Joint: true}, stateRecord.Error()
+	}
+	return &RPCRecordOwners{Owners: []common.Address{stateRecord.GetOwner(record)}, Threshold: 1}, stateRecord.Error()
+}
+
+// RPCRecordProposal is a proposal on a jointly owned record collecting approvals.
+type RPCRecordProposal struct {
+	ID         common.Hash      `json:"id"`
+	Action     hexutil.Uint64   `json:"action"`
+	To         *common.Address  `json:"to,omitempty"`
+	Status     *hexutil.Uint64  `json:"status,omitempty"`
+	Approvals  hexutil.Uint64   `json:"approvals"`
+	ApprovedBy []common.Address `json:"approvedBy"`
+	Expiry     hexutil.Uint64   `json:"expiry"`
+}
+
+// GetRecordProposals returns the unexpired proposals of a jointly owned record.
+func (s *PublicBlockChainAPI) GetRecordProposals(ctx context.Context, data string, blockNr rpc.BlockNumber) ([]*RPCRecordProposal, error) {
+	stateRecord, header, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	b, _ := rlp.EncodeToBytes(data)
+	record := common.BytesToHash(b)
+	owners, _ := stateRecord.GetJointOwners(record)
+
+	proposals := []*RPCRecordProposal{}
+	for _, id := range stateRecord.GetProposals(record) {
+		packed, expiry, approvals := stateRecord.GetProposal(record, id)
+		if header.Number.Uint64() > expiry {
+			continue
+		}
+		proposal := types.UnpackRecordProposal(record, packed)
+		result := &RPCRecordProposal{
+			ID:         id,
+			Action:     hexutil.Uint64(proposal.Action),
+			Approvals:  hexutil.Uint64(approvals),
+			ApprovedBy: []common.Address{},
+			Expiry:     hexutil.Uint64(expiry),
+		}
+		switch proposal.Action {
+		case types.ProposalTransfer:
+			result.To = &proposal.To
+		case types.ProposalStatus:
+			status := hexutil.Uint64(proposal.Status)
+			result.Status = &status
+		}
+		for _, owner := range owners {
+			if stateRecord.HasApproved(record, id, owner) {
+				result.ApprovedBy = append(result.ApprovedBy, owner)
+			}
+		}
+		proposals = append(proposals, result)
+	}
+	return proposals, stateRecord.Error()
+}
+
+// BuildJointOwnership returns the payload of a JointOwnershipData transaction
+// sharing a record between the given co-owners.
+func (s *PublicBlockChainAPI) BuildJointOwnership(record common.Hash, owners []common.Address, threshold hexutil.Uint64) (hexutil.Bytes, error) {
+	joint := &types.JointOwnership{Record: record, Owners: owners, Threshold: uint64(threshold)}
+	if err := joint.Validate(); err != nil {
+		return nil, err
+	}
+	return joint.EncodeToBytes()
+}
+
+// BuildConfirmation returns the payload of a ConfirmationData transaction
+// from the record market fork on, confirming the data as a record that pays
+// the royalty in basis points to its origin on every sale. A non-zero expiry
+// is the last block the record is valid at.
+func (s *PublicBlockChainAPI) BuildConfirmation(data string, royalty hexutil.Uint64, expiry hexutil.Uint64) (hexutil.Bytes, error) {
+	if len(data) == 0 {
+		return nil, types.ErrEmptyConfirmation
+	}
+	if uint64(royalty) > params.MaxRoyalty {
+		return nil, types.ErrInvalidRoyalty
+	}
+	record := &types.RecordConfirmation{Data: []byte(data), Royalty: uint64(royalty), Expiry: uint64(expiry)}
+	return record.EncodeToBytes()
+}
+
+// BuildRecordTombstone returns the payload of a RecordTombstone transaction
+// erasing the record, or approving its takedown when sent by a validator.
+func (s *PublicBlockChainAPI) BuildRecordTombstone(record common.Hash) (hexutil.Bytes, error) {
+	if record == (common.Hash{}) {
+		return nil, types.ErrEmptyTombstone
+	}
+	tombstone := &types.Tombstone{Record: record}
+	return tombstone.EncodeToBytes()
+}
+
+// BuildMultisigAccount returns the payload of a MultisigCreation transaction
+// creating an account controlled by the signers with the given threshold.
+func (s *PublicBlockChainAPI) BuildMultisigAccount(signers []common.Address, threshold hexutil.Uint64) (hexutil.Bytes, error) {
+	account := &types.MultisigAccount{Signers: signers, Threshold: uint64(threshold)}
+	if err := account.Validate(); err != nil {
+		return nil, err
+	}
+	return account.EncodeToBytes()
+}
+
+// RPCMultisig is the signer set and threshold of a multisig account.
+type RPCMultisig struct {
+	Signers   []common.Address `json:"signers"`
+	Threshold hexutil.Uint64   `json:"threshold"`
+}
+
+// GetMultisig returns the signers and threshold of a multisig account, or nil
+// if the account is not a multisig account.
+func (s *PublicBlockChainAPI) GetMultisig(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*RPCMultisig, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	signers, threshold := state.GetMultisig(address)
+	if threshold == 0 {
+		return nil, state.Error()
+	}
+	return &RPCMultisig{Signers: signers, Threshold: hexutil.Uint64(threshold)}, state.Error()
+}
+
+// RPCOperation is an operation of a multi-operation transaction, with the
+// fields of a transaction of the same type.
+type RPCOperation struct {
+	Type  types.TxType    `json:"type"`
+	To    *common.Address `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+	Data  hexutil.Bytes   `json:"data"`
+}
+
+// BuildMultiOperation returns the payload of a MultiOperationData transaction
+// running the operations in order, all or none of them taking effect.
+func (s *PublicBlockChainAPI) BuildMultiOperation(ops []RPCOperation) (hexutil.Bytes, error) {
+	multi := &types.MultiOperation{Ops: make([]*types.Operation, len(ops))}
+	for i, op := range ops {
+		operation := &types.Operation{Type: op.Type, Value: new(big.Int), Payload: op.Data}
+		if op.To != nil {
+			operation.Recipient = *op.To
+		}
+		if op.Value != nil {
+			operation.Value = (*big.Int)(op.Value)
+		}
+		multi.Ops[i] = operation
+	}
+	if err := multi.Validate(); err != nil {
+		return nil, err
+	}
+	return multi.EncodeToBytes()
+}
+
+// RPCFeeEstimate is the fee of a transaction and the balance it needs from its
+// sender.
+type RPCFeeEstimate struct {
+	Fee  *hexutil.Big `json:"fee"`
+	Cost *hexutil.Big `json:"cost"`
+}
+
+// EstimateFee returns the fee a transaction with the given arguments pays to
+// the validator of the next block, and its cost including the value it spends.
+func (s *PublicBlockChainAPI) EstimateFee(args SendTxArgs) *RPCFeeEstimate {
+	if args.Nonce == nil {
+		args.Nonce = new(hexutil.Uint64)
+	}
+	tx := args.toTransaction()
+	config := s.b.ChainConfig()
+	number := new(big.Int).Add(s.b.CurrentBlock().Number(), common.Big1)
+	return &RPCFeeEstimate{
+		Fee:  (*hexutil.Big)(tx.Fee(config, number)),
+		Cost: (*hexutil.Big)(core.TxCost(config, number, tx, args.From)),
+	}
+}
+
+// RPCRecordState is the state of a record in a simulation.
+type RPCRecordState struct {
+	Origin   common.Address `json:"origin"`
+	Owner    common.Address `json:"owner"`
+	Status   hexutil.Uint64 `json:"status"`
+	AskPrice *hexutil.Big   `json:"askPrice"`
+	Expiry   hexutil.Uint64 `json:"expiry"`
+}
+
+// RPCRecordDiff is the state of a record touched by a simulated transaction
+// before and after it, nil where the record does not exist.
+type RPCRecordDiff struct {
+	Record common.Hash     `json:"record"`
+	Before *RPCRecordState `json:"before"`
+	After  *RPCRecordState `json:"after"`
+}
+
+// RPCSimulation is the would-be outcome of a transaction: its receipt, the
+// contribution changes of the accounts involved and the records it touches.
+type RPCSimulation struct {
+	Status        hexutil.Uint                    `json:"status"`
+	Failed        bool                            `json:"failed"`
+	FailureCode   hexutil.Uint                    `json:"failureCode"`
+	FailureReason string                          `json:"failureReason"`
+	Results       []map[string]interface{}        `json:"results,omitempty"`
+	Logs          []*types.Log                    `json:"logs"`
+	Fee           *hexutil.Big                    `json:"fee"`
+	MissingQuorum hexutil.Uint64                  `json:"missingQuorum"` // 多签交易还需要的签名者数量
+	Contributions map[common.Address]*hexutil.Big `json:"contributions"`
+	Records       []*RPCRecordDiff                `json:"records"`
+}
+
+// SimulateTransaction dry runs a transaction with the given arguments on top
+// of the state of a block, without signing or broadcasting it. Transactions
+// that would be included as failed report the reason, transactions that could
+// not be included at all return an error.
+func (s *PublicBlockChainAPI) SimulateTransaction(ctx context.Context, args SendTxArgs, blockNr rpc.BlockNumber) (*RPCSimulation, error) {
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return nil, err
+	}
+	pocContext, _, err := s.b.PocContextAndHeaderByNumber(ctx, blockNr)
+	if pocContext == nil || err != nil {
+		return nil, err
+	}
+	sender := args.From
+	if args.Multisig != nil {
+		sender = *args.Multisig
+	}
+	if args.Nonce == nil {
+		nonce := statedb.GetNonce(sender)
+		args.Nonce = (*hexutil.Uint64)(&nonce)
+	}
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	tx := args.toTransaction()
+	msg := tx.AsSimulatedMessage(sender)
+
+	// 记下交易前的记录状态和相关账户, 用于比较
+	var (
+		number   = header.Number.Uint64()
+		records  = core.RecordKeys(s.b.ChainConfig(), header.Number, tx, stateRecord)
+		before   = stateRecord.Copy()
+		accounts = []common.Address{sender, msg.Payer()}
+	)
+	if tx.To() != nil {
+		accounts = append(accounts, *tx.To())
+	}
+	for _, record := range records {
+		accounts = append(accounts, before.GetOwner(record))
+	}
+	contributions := make(map[common.Address]*big.Int)
+	for _, addr := range accounts {
+		contributions[addr] = statedb.GetContribution(addr)
+	}
+	// 交易还没有签名, 多签账户的全部门限都要报告给调用者
+	missing, err := core.MultisigQuorum(statedb, types.MakeSigner(s.b.ChainConfig(), header.Number), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := core.SimulateTransaction(s.b.ChainConfig(), pocContext, statedb, stateRecord, header, tx, sender)
+	if err != nil {
+		return nil, err
+	}
+	result := &RPCSimulation{
+		Status:        hexutil.Uint(receipt.Status),
+		Failed:        receipt.Failed(),
+		FailureCode:   hexutil.Uint(receipt.Failure),
+		Results:       rpcOperationResults(receipt.Results),
+		Logs:          receipt.Logs,
+		Fee:           (*hexutil.Big)(tx.Fee(s.b.ChainConfig(), header.Number)),
+		MissingQuorum: hexutil.Uint64(missing),
+		Contributions: make(map[common.Address]*hexutil.Big),
+		Records:       make([]*RPCRecordDiff, len(records)),
+	}
+	if receipt.Failure != types.FailureNone {
+		result.FailureReason = receipt.Failure.String()
+	}
+	if result.Logs == nil {
+		result.Logs = []*types.Log{}
+	}
+	for _, record := range records {
+		accounts = append(accounts, stateRecord.GetOwner(record))
+	}
+	for _, addr := range accounts {
+		if _, ok := contributions[addr]; !ok {
+			contributions[addr] = new(big.Int)
+		}
+		if delta := new(big.Int).Sub(statedb.GetContribution(addr), contributions[addr]); delta.Sign() != 0 {
+			result.Contributions[addr] = (*hexutil.Big)(delta)
+		}
+	}
+	for i, record := range records {
+		result.Records[i] = &RPCRecordDiff{
+			Record: record,
+			Before: rpcRecordState(before, record, number),
+			After:  rpcRecordState(stateRecord, record, number),
+		}
+	}
+	return result, nil
+}
+
+func rpcRecordState(stateRecord *state.StateDBRecord, record common.Hash, number uint64) *RPCRecordState {
+	if !stateRecord.Exist(record) || stateRecord.IsExpired(record, number) {
+		return nil
+	}
+	return &RPCRecordState{
+		Origin:   stateRecord.GetOrigin(record),
+		Owner:    stateRecord.GetOwner(record),
+		Status:   hexutil.Uint64(stateRecord.GetStatus(record)),
+		AskPrice: (*hexutil.Big)(stateRecord.GetAskPrice(record)),
+		Expiry:   hexutil.Uint64(stateRecord.GetExpiry(record)),
+	}
+}
+
+// BuildRecordProposal returns the payload of a JointApprovalData transaction
+// approving a transfer (action 1) or status change (action 2) of a record.
+func (s *PublicBlockChainAPI) BuildRecordProposal(record common.Hash, action hexutil.Uint64, to common.Address, status hexutil.Uint64) (hexutil.Bytes, error) {
+	proposal := &types.RecordProposal{Record: record, Action: uint8(action), To: to, Status: uint8(status)}
+	payload, err := proposal.EncodeToBytes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := types.DecodeRecordProposal(payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
 func (s *PublicBlockChainAPI) GetRecordTxs(ctx context.Context, data string, blockNr rpc.BlockNumber) ([]common.Hash, error) {
 	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
 	if stateRecord == nil || err != nil {
@@ -514,6 +1074,136 @@ func (s *PublicBlockChainAPI) GetRecordTxs(ctx context.Context, data string, blo
 	return txs, stateRecord.Error()
 }
 
+// RPCRecordHistoryEntry is a single provenance step of a record in its RPC representation.
+type RPCRecordHistoryEntry struct {
+	Type             types.TxType   `json:"type"`
+	From             common.Address `json:"from"`
+	To               common.Address `json:"to"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	Timestamp        *hexutil.Big   `json:"timestamp"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// RPCRecordHistory is the provenance history of a record. Pending is set when
+// the history indexer lags too far behind the head for the unindexed blocks to
+// be scanned on demand; the entries then stop before the newest blocks.
+type RPCRecordHistory struct {
+	Entries []*RPCRecordHistoryEntry `json:"entries"`
+	Pending bool                     `json:"pending"`
+}
+
+// GetRecordHistory returns the ordered provenance history of a record on the
+// canonical chain: every data transaction that confirmed, transferred or
+// authorized it.
+func (s *PublicBlockChainAPI) GetRecordHistory(ctx context.Context, recordKey common.Hash) (*RPCRecordHistory, error) {
+	history, pending, err := s.b.GetRecordHistory(ctx, recordKey)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*RPCRecordHistoryEntry, len(history))
+	for i, entry := range history {
+		entries[i] = &RPCRecordHistoryEntry{
+			Type:             entry.Type,
+			From:             entry.From,
+			To:               entry.To,
+			BlockHash:        entry.BlockHash,
+			BlockNumber:      hexutil.Uint64(entry.BlockNumber),
+			Timestamp:        (*hexutil.Big)(entry.Time),
+			TransactionHash:  entry.TxHash,
+			TransactionIndex: hexutil.Uint64(entry.TxIndex),
+		}
+	}
+	return &RPCRecordHistory{Entries: entries, Pending: pending}, nil
+}
+
+// BatchLeafProof is the inclusion proof of a single leaf of a batch confirmation.
+type BatchLeafProof struct {
+	Leaf  common.Hash   `json:"leaf"`
+	Index hexutil.Uint  `json:"index"`
+	Proof []common.Hash `json:"proof"`
+}
+
+// BatchConfirmationResult is the payload of a batch confirmation transaction
+// together with the inclusion proof of every leaf.
+type BatchConfirmationResult struct {
+	Root    common.Hash      `json:"root"`
+	Size    hexutil.Uint     `json:"size"`
+	Payload hexutil.Bytes    `json:"payload"`
+	Proofs  []BatchLeafProof `json:"proofs"`
+}
+
+// BuildBatchConfirmation builds the merkle tree over the given record digests
+// and returns the payload for a BatchConfirmationData transaction along with
+// the per-leaf inclusion proofs.
+func (s *PublicBlockChainAPI) BuildBatchConfirmation(leaves []common.Hash) (*BatchConfirmationResult, error) {
+	batch, err := types.NewBatchConfirmation(leaves)
+	if err != nil {
+		return nil, err
+	}
+	if err := batch.Validate(); err != nil {
+		return nil, err
+	}
+	payload, err := batch.EncodeToBytes()
+	if err != nil {
+		return nil, err
+	}
+	result := &BatchConfirmationResult{
+		Root:    batch.Root,
+		Size:    hexutil.Uint(len(leaves)),
+		Payload: payload,
+		Proofs:  make([]BatchLeafProof, len(leaves)),
+	}
+	for i, leaf := range leaves {
+		proof, err := types.MerkleProof(leaves, i)
+		if err != nil {
+			return nil, err
+		}
+		result.Proofs[i] = BatchLeafProof{Leaf: leaf, Index: hexutil.Uint(i), Proof: proof}
+	}
+	return result, nil
+}
+
+// VerifyBatchProof checks the inclusion proof of leaf at index in a batch of
+// the given size committed to by root.
+func (s *PublicBlockChainAPI) VerifyBatchProof(root common.Hash, leaf common.Hash, index hexutil.Uint, size hexutil.Uint, proof []common.Hash) bool {
+	return types.VerifyMerkleProof(root, leaf, int(index), int(size), proof)
+}
+
+// GetBatchRoot returns the merkle root of the batch a record was confirmed in.
+func (s *PublicBlockChainAPI) GetBatchRoot(ctx context.Context, leaf common.Hash, blockNr rpc.BlockNumber) (common.Hash, error) {
+	stateRecord, _, err := s.b.StateRecordAndHeaderByNumber(ctx, blockNr)
+	if stateRecord == nil || err != nil {
+		return common.Hash{}, err
+	}
+	root := stateRecord.GetBatchRoot(leaf)
+	return root, stateRecord.Error()
+}
+
+// OwnedRecordsPage is a page of the records owned by an account. Next is the
+// cursor to pass for the following page, empty once all records are returned.
+type OwnedRecordsPage struct {
+	Records []common.Hash `json:"records"`
+	Next    hexutil.Bytes `json:"next"`
+}
+
+// GetRecordsByOwner returns up to limit records owned by an account, starting at cursor.
+func (s *PublicBlockChainAPI) GetRecordsByOwner(ctx context.Context, address common.Address, cursor hexutil.Bytes, limit hexutil.Uint, blockNr rpc.BlockNumber) (*OwnedRecordsPage, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if limit == 0 || limit > maxOwnedRecordsPage {
+		limit = maxOwnedRecordsPage
+	}
+	records, next := state.OwnedRecords(address, cursor, int(limit))
+	if records == nil {
+		records = []common.Hash{}
+	}
+	return &OwnedRecordsPage{Records: records, Next: next}, state.Error()
+}
+
 // GetBlockByNumber returns the requested block. When blockNr is -1 the chain head is returned. When fullTx is true all
 // transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
@@ -724,6 +1414,9 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+	Window           *types.TxWindow `json:"window,omitempty"`
+	Sponsor          *common.Address `json:"sponsor,omitempty"`
+	Multisig         *common.Address `json:"multisig,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -749,6 +1442,13 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		V:        (*hexutil.Big)(v),
 		R:        (*hexutil.Big)(r),
 		S:        (*hexutil.Big)(s),
+		Window:   tx.Window(),
+	}
+	if sponsor, ok := tx.SponsorAddress(); ok {
+		result.Sponsor = &sponsor
+	}
+	if account, ok := tx.MultisigAccount(); ok {
+		result.Multisig = &account
 	}
 	if blockHash != (common.Hash{}) {
 		result.BlockHash = blockHash
@@ -929,6 +1629,16 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	if receipt.Logs == nil {
 		fields["logs"] = [][]*types.Log{}
 	}
+	// 失败交易的原因码和说明, 成功的交易只有 failed 字段
+	fields["failed"] = receipt.Failed()
+	if receipt.Failure != types.FailureNone {
+		fields["failureCode"] = hexutil.Uint(receipt.Failure)
+		fields["failureReason"] = receipt.Failure.String()
+	}
+	// 多操作交易每个操作的结果
+	if len(receipt.Results) > 0 {
+		fields["results"] = rpcOperationResults(receipt.Results)
+	}
 	// If the ContractAddress is 20 0x0 bytes, assume it is not a contract creation
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
@@ -936,6 +1646,23 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	return fields, nil
 }
 
+// rpcOperationResults returns the RPC representation of the operation results
+// of a multi-operation transaction.
+func rpcOperationResults(results []*types.OperationResult) []map[string]interface{} {
+	if len(results) == 0 {
+		return nil
+	}
+	fields := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		fields[i] = map[string]interface{}{"status": hexutil.Uint(result.Status)}
+		if result.Failure != types.FailureNone {
+			fields[i]["failureCode"] = hexutil.Uint(result.Failure)
+			fields[i]["failureReason"] = result.Failure.String()
+		}
+	}
+	return fields
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
 func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	if err := tx.Validate(); err != nil {
@@ -966,6 +1693,18 @@ type SendTxArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 	Nonce    *hexutil.Uint64 `json:"nonce"`
 	Type     types.TxType    `json:"type"`
+
+	// 可选的有效期, 为空表示不限制
+	ValidAfter     *hexutil.Uint64 `json:"validAfter"`
+	ValidUntil     *hexutil.Uint64 `json:"validUntil"`
+	ValidAfterTime *hexutil.Uint64 `json:"validAfterTime"`
+	ValidUntilTime *hexutil.Uint64 `json:"validUntilTime"`
+
+	// 可选的赞助者, 由赞助者支付手续费
+	Sponsor *common.Address `json:"sponsor"`
+
+	// 可选的多签账户, 交易由多签账户发送, From 为其中一个签名者
+	Multisig *common.Address `json:"multisig"`
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
@@ -984,7 +1723,11 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 		args.Value = new(hexutil.Big)
 	}
 	if args.Nonce == nil {
-		nonce, err := b.GetPoolNonce(ctx, args.From)
+		sender := args.From
+		if args.Multisig != nil {
+			sender = *args.Multisig
+		}
+		nonce, err := b.GetPoolNonce(ctx, sender)
 		if err != nil {
 			return err
 		}
@@ -994,14 +1737,48 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 }
 
 func (args *SendTxArgs) toTransaction() *types.Transaction {
+	var tx *types.Transaction
 	if args.Type == types.Binary && args.To == nil {
-		return types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+		tx = types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+	} else {
+		to := common.Address{}
+		if args.To != nil {
+			to = *args.To
+		}
+		tx = types.NewTransaction(args.Type, uint64(*args.Nonce), to, (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+	}
+	if window := args.window(); window != nil {
+		tx = tx.WithWindow(window)
+	}
+	if args.Sponsor != nil {
+		tx = tx.WithSponsor(*args.Sponsor)
 	}
-	to := common.Address{}
-	if args.To != nil {
-		to = *args.To
+	if args.Multisig != nil {
+		tx = tx.WithMultisig(*args.Multisig)
 	}
-	return types.NewTransaction(args.Type, uint64(*args.Nonce), to, (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+	return tx
+}
+
+// window returns the validity window requested by the arguments, or nil if
+// no bound was given.
+func (args *SendTxArgs) window() *types.TxWindow {
+	if args.ValidAfter == nil && args.ValidUntil == nil && args.ValidAfterTime == nil && args.ValidUntilTime == nil {
+		return nil
+	}
+	window := new(types.TxWindow)
+	if args.ValidAfter != nil {
+		window.AfterBlock = uint64(*args.ValidAfter)
+	}
+	if args.ValidUntil != nil {
+		window.UntilBlock = uint64(*args.ValidUntil)
+	}
+	if args.ValidAfterTime != nil {
+		window.AfterTime = uint64(*args.ValidAfterTime)
+	}
+	if args.ValidUntilTime != nil {
+		window.UntilTime = uint64(*args.ValidUntilTime)
+	}
+	return window
 }
 
 // submitTransaction is a helper function that submits tx to txPool and logs a message.
@@ -1078,6 +1855,64 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return submitTransaction(ctx, s.b, tx)
 }
 
+// SponsorTransaction adds the signature of the sponsor to a raw transaction
+// signed by its sender and submits it to the transaction pool. The sponsor
+// declared by the sender must be an account of this node, it pays the fee.
+func (s *PublicTransactionPoolAPI) SponsorTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if err := tx.Validate(); err != nil {
+		return common.Hash{}, err
+	}
+	sponsor, ok := tx.SponsorAddress()
+	if !ok {
+		return common.Hash{}, types.ErrInvalidSponsor
+	}
+	account := accounts.Account{Address: sponsor}
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sig, err := wallet.SignHash(account, types.SponsorHash(tx).Bytes())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if tx, err = tx.WithSponsorSignature(sig); err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(ctx, s.b, tx)
+}
+
+// CosignTransaction adds the signature of another signer of the multisig
+// account sending a raw transaction and returns the raw transaction. The
+// signer must be an account of this node. Once the threshold of the account is
+// reached the transaction can be sent with sendRawTransaction.
+func (s *PublicTransactionPoolAPI) CosignTransaction(ctx context.Context, encodedTx hexutil.Bytes, addr common.Address) (hexutil.Bytes, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return nil, err
+	}
+	if _, ok := tx.MultisigAccount(); !ok {
+		return nil, types.ErrInvalidMultisig
+	}
+	account := accounts.Account{Address: addr}
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), s.b.CurrentBlock().Number())
+	sig, err := wallet.SignHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if tx, err = tx.WithMultisigSignature(signer, sig); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(tx)
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message).
 //
@@ -48,8 +48,10 @@ type Backend interface {
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	StateRecordAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDBRecord, *types.Header, error)
+	PocContextAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.PocContext, *types.Header, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
+	GetRecordHistory(ctx context.Context, key common.Hash) ([]core.RecordHistoryEntry, bool, error)
 	GetTd(blockHash common.Hash) *big.Int
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
@@ -439,6 +439,126 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
 		}),
+        new web3._extend.Method({
+			name: 'getRoyalty',
+			call: 'eth_getRoyalty',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+        new web3._extend.Method({
+			name: 'getAskPrice',
+			call: 'eth_getAskPrice',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			outputFormatter: web3._extend.formatters.outputBigNumberFormatter
+		}),
+        new web3._extend.Method({
+			name: 'getRecordExpiry',
+			call: 'eth_getRecordExpiry',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+        new web3._extend.Method({
+			name: 'buildConfirmation',
+			call: 'eth_buildConfirmation',
+			params: 3,
+		}),
+        new web3._extend.Method({
+			name: 'estimateFee',
+			call: 'eth_estimateFee',
+			params: 1,
+		}),
+        new web3._extend.Method({
+			name: 'simulateTransaction',
+			call: 'eth_simulateTransaction',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'buildMultiOperation',
+			call: 'eth_buildMultiOperation',
+			params: 1,
+		}),
+        new web3._extend.Method({
+			name: 'buildMultisigAccount',
+			call: 'eth_buildMultisigAccount',
+			params: 2,
+		}),
+        new web3._extend.Method({
+			name: 'getMultisig',
+			call: 'eth_getMultisig',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'buildRecordTombstone',
+			call: 'eth_buildRecordTombstone',
+			params: 1,
+		}),
+        new web3._extend.Method({
+			name: 'getTransferOffer',
+			call: 'eth_getTransferOffer',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'getPendingTransfers',
+			call: 'eth_getPendingTransfers',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'getRecordOwners',
+			call: 'eth_getRecordOwners',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'getRecordProposals',
+			call: 'eth_getRecordProposals',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+        new web3._extend.Method({
+			name: 'buildJointOwnership',
+			call: 'eth_buildJointOwnership',
+			params: 3,
+		}),
+        new web3._extend.Method({
+			name: 'buildRecordProposal',
+			call: 'eth_buildRecordProposal',
+			params: 4,
+		}),
+        new web3._extend.Method({
+			name: 'getRecordHistory',
+			call: 'eth_getRecordHistory',
+			params: 1,
+		}),
+        new web3._extend.Method({
+			name: 'buildBatchConfirmation',
+			call: 'eth_buildBatchConfirmation',
+			params: 1,
+		}),
+        new web3._extend.Method({
+			name: 'verifyBatchProof',
+			call: 'eth_verifyBatchProof',
+			params: 5,
+			inputFormatter: [null, null, web3._extend.utils.toHex, web3._extend.utils.toHex, null],
+		}),
+        new web3._extend.Method({
+			name: 'getBatchRoot',
+			call: 'eth_getBatchRoot',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+		new web3._extend.Method({
+			name: 'getRecordsByOwner',
+			call: 'eth_getRecordsByOwner',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'eth_sign',
@@ -457,6 +577,17 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'sponsorTransaction',
+			call: 'eth_sponsorTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'cosignTransaction',
+			call: 'eth_cosignTransaction',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'eth_submitTransaction',
@@ -583,6 +714,29 @@ web3._extend({
 			call: 'personal_deriveAccount',
 			params: 3
 		}),
+		new web3._extend.Method({
+			name: 'publicKey',
+			call: 'personal_publicKey',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'encryptRecord',
+			call: 'personal_encryptRecord',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'decryptRecord',
+			call: 'personal_decryptRecord',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'rewrapRecordKey',
+			call: 'personal_rewrapRecordKey',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
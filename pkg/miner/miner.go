@@ -31,6 +31,7 @@ import (
 	"AQChainRe/pkg/params"
 	"fmt"
 	"sync/atomic"
+	"time"
 )
 
 // Backend wraps all methods required for mining.
@@ -56,13 +57,13 @@ type Miner struct {
 	shouldStart int32 // should start indicates whether we should start after sync
 }
 
-// 创建worker
-func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine consensus.Engine) *Miner {
+// 创建worker, buildTime 为每个区块填充交易的最长时间
+func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine consensus.Engine, buildTime time.Duration) *Miner {
 	miner := &Miner{
 		eth:      eth,
 		mux:      mux,
 		engine:   engine,
-		worker:   newWorker(config, engine, common.Address{}, eth, mux),
+		worker:   newWorker(config, engine, common.Address{}, eth, mux, buildTime),
 		canStart: 1,
 	}
 	go miner.update()
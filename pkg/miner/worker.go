@@ -61,10 +61,13 @@ type Work struct {
 	state       *state.StateDB       // apply state changes here
 	stateRecord *state.StateDBRecord // apply state changes here
 	pocContext  *types.PocContext
+	policy      core.TxPolicy // ordering of the transactions to include
 	ancestors   set.Interface // ancestor set (used for checking uncle parent validity)
 	family      set.Interface // family set (used for checking uncle invalidity)
 	uncles      set.Interface // uncle set
 	tcount      int           // tx count in cycle
+	payload     uint64        // payload bytes of the included transactions
+	deadline    time.Time     // time to stop including transactions (zero = none)
 
 	Block *types.Block // the new block
 
@@ -103,8 +106,9 @@ type worker struct {
 	proc    core.Validator
 	chainDb ethdb.Database
 
-	coinbase common.Address
-	extra    []byte
+	coinbase  common.Address
+	extra     []byte
+	buildTime time.Duration // maximum time spent filling a block with transactions
 
 	currentMu sync.Mutex
 	current   *Work
@@ -122,9 +126,10 @@ type worker struct {
 	stopper chan struct{}
 }
 
-func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, eth Backend, mux *event.TypeMux) *worker {
+func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, eth Backend, mux *event.TypeMux, buildTime time.Duration) *worker {
 	worker := &worker{
 		config:         config,
+		buildTime:      buildTime,
 		engine:         engine,
 		eth:            eth,
 		mux:            mux,
@@ -284,7 +289,7 @@ func (self *worker) update() {
 				self.currentMu.Lock()
 				acc, _ := types.Sender(self.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
-				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
+				txset := self.current.orderTransactions(txs)
 
 				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
 				self.currentMu.Unlock()
@@ -332,8 +337,11 @@ func (self *worker) wait() {
 			self.mux.Post(core.NewMinedBlockEvent{Block: block})
 			var (
 				events []interface{}
-				logs   = work.state.Logs()
+				logs   []*types.Log
 			)
+			for _, r := range work.receipts {
+				logs = append(logs, r.Logs...)
+			}
 			events = append(events, core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
 			if stat == core.CanonStatTy {
 				events = append(events, core.ChainHeadEvent{Block: block})
@@ -367,12 +375,14 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		state:       state,
 		stateRecord: stateRecord,
 		pocContext:  pocContext,
+		policy:      core.NewDefaultTxPolicy(self.config, header.Number, state.Copy(), self.eth.TxPool().Arrival),
 		ancestors:   set.New(0),
 		family:      set.New(0),
 		uncles:      set.New(0),
 		header:      header,
 		createdAt:   time.Now(),
 	}
+	work.deadline = buildDeadline(header, self.buildTime)
 
 	// when 08 is processed ancestors contain 07 (quick block)
 	for _, ancestor := range self.chain.GetBlocksFromHash(parent.Hash(), 7) {
@@ -389,6 +399,17 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	return nil
 }
 
+// buildDeadline returns the time to stop including transactions into a block
+// with the given header, zero for none. It counts from the slot of the block
+// rather than from the start of building, so that a late start cannot push the
+// block past its slot.
+func buildDeadline(header *types.Header, buildTime time.Duration) time.Time {
+	if buildTime <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(header.Time.Int64(), 0).Add(buildTime)
+}
+
 func (self *worker) createNewWork() (*Work, error) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
@@ -451,11 +472,15 @@ func (self *worker) createNewWork() (*Work, error) {
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
+	if self.config.IsRecordTrie(header.Number) {
+		work.state.EnableRecordTrie()
+	}
 	pending, err := self.eth.TxPool().Pending()
 	if err != nil {
 		return nil, fmt.Errorf("got error when fetch pending transactions, err: %s", err)
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	work.commitReservedTransactions(self.mux, pending, self.chain, self.coinbase)
+	txs := work.orderTransactions(pending)
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -510,6 +535,52 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
+// orderTransactions sorts the given transactions by the priority the ordering
+// policy gives them.
+func (env *Work) orderTransactions(txs map[common.Address]types.Transactions) *types.TransactionsByPriceAndNonce {
+	return types.NewTransactionsByPriorityAndNonce(env.signer, txs, func(tx *types.Transaction) *big.Int {
+		from, _ := types.Sender(env.signer, tx)
+		return env.policy.Priority(tx, from)
+	})
+}
+
+// commitReservedTransactions commits the candidate logins and logouts next in
+// line for their senders ahead of all other transactions, up to the quota
+// reserved for them in every block, so that a backlog of data transactions
+// cannot starve poc management. Committed transactions are removed from pending.
+func (env *Work) commitReservedTransactions(mux *event.TypeMux, pending map[common.Address]types.Transactions, bc *core.BlockChain, coinbase common.Address) {
+	heads := make(map[common.Address]types.Transactions)
+	for from, txs := range pending {
+		if txType := txs[0].Type(); txType == types.LoginCandidate || txType == types.LogoutCandidate {
+			heads[from] = types.Transactions{txs[0]}
+		}
+	}
+	// 按优先级选出配额内的候选交易
+	reserved := make(map[common.Address]types.Transactions)
+	for ordered := env.orderTransactions(heads); uint64(len(reserved)) < params.PocTxQuota; ordered.Shift() {
+		tx := ordered.Peek()
+		if tx == nil {
+			break
+		}
+		from, _ := types.Sender(env.signer, tx)
+		reserved[from] = types.Transactions{tx}
+	}
+	if len(reserved) == 0 {
+		return
+	}
+	env.commitTransactions(mux, env.orderTransactions(reserved), bc, coinbase)
+
+	for from := range reserved {
+		if txs := pending[from]; env.state.GetNonce(from) > txs[0].Nonce() {
+			if len(txs) == 1 {
+				delete(pending, from)
+			} else {
+				pending[from] = txs[1:]
+			}
+		}
+	}
+}
+
 func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
 
 	var coalescedLogs []*types.Log
@@ -521,6 +592,23 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		if tx == nil {
 			break
 		}
+		// 交易不消耗 gas, 按区块容量和出块时间限制打包
+		if !env.deadline.IsZero() && time.Now().After(env.deadline) {
+			log.Debug("Block build time exceeded", "txs", env.tcount, "payload", env.payload)
+			break
+		}
+		if limits := env.config.BlockLimits; env.config.IsBlockLimits(env.header.Number) {
+			if limits.MaxTxs > 0 && uint64(env.tcount) >= limits.MaxTxs {
+				log.Trace("Transaction limit reached for current block", "txs", env.tcount)
+				break
+			}
+			if limits.MaxPayloadBytes > 0 && env.payload+tx.PayloadSize() > limits.MaxPayloadBytes {
+				// Pop the oversized transaction without shifting in the next from the account
+				log.Trace("Payload limit exceeded for current block", "hash", tx.Hash(), "payload", env.payload)
+				txs.Pop()
+				continue
+			}
+		}
 		// Error may be ignored here. The error has already been checked
 		// during transaction acceptance is the transaction pool.
 		//
@@ -554,10 +642,16 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			log.Trace("Skipping account with hight nonce", "sender", from, "nonce", tx.Nonce())
 			txs.Pop()
 
+		case core.ErrTxNotYetValid, core.ErrTxExpired:
+			// Outside its validity window, later transactions of the account can't run either
+			log.Trace("Skipping transaction outside its validity window", "sender", from, "nonce", tx.Nonce(), "err", err)
+			txs.Pop()
+
 		case nil:
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			env.tcount++
+			env.payload += tx.PayloadSize()
 			txs.Shift()
 
 		default:
@@ -0,0 +1,91 @@
+package miner
+
+import (
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/core"
+	"AQChainRe/pkg/core/state"
+	"AQChainRe/pkg/core/types"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
+	"AQChainRe/pkg/event"
+	"AQChainRe/pkg/params"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestWork creates an environment for building block number on an empty
+// state.
+func newTestWork(config *params.ChainConfig, number int64) *Work {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	stateRecord, _ := state.NewRecord(common.Hash{}, state.NewDatabase(db))
+	pocContext, _ := types.NewPocContext(db)
+
+	header := &types.Header{Number: big.NewInt(number), Time: big.NewInt(time.Now().Unix())}
+	return &Work{
+		config:      config,
+		signer:      types.NewEIP155Signer(config.ChainId),
+		state:       statedb,
+		stateRecord: stateRecord,
+		pocContext:  pocContext,
+		policy:      core.NewDefaultTxPolicy(config, header.Number, statedb.Copy(), func(common.Hash) time.Time { return time.Time{} }),
+		header:      header,
+	}
+}
+
+// Tests that the worker fills blocks up to the block limits from the block
+// limits fork on, skipping transactions whose payload doesn't fit, and stops
+// at the build deadline.
+func TestCommitTransactionsBlockLimits(t *testing.T) {
+	config := *params.TestChainConfig
+	config.BlockLimitsBlock = big.NewInt(2)
+	config.BlockLimits = &params.BlockLimits{MaxTxs: 3, MaxPayloadBytes: 10}
+
+	// Confirmations from separate senders, the first ones paying the most
+	payloads := []string{"12345678", "1234", "1", "2", "3"}
+	tests := []struct {
+		number   int64
+		deadline time.Duration
+		included []string
+	}{
+		{1, 0, payloads},
+		{2, 0, []string{"12345678", "1", "2"}},
+		{2, -time.Second, nil},
+	}
+	for i, test := range tests {
+		work := newTestWork(&config, test.number)
+		if test.deadline != 0 {
+			work.deadline = time.Now().Add(test.deadline)
+		}
+		pending := make(map[common.Address]types.Transactions)
+		for j, payload := range payloads {
+			key, _ := crypto.GenerateKey()
+			price := big.NewInt(int64(len(payloads) - j))
+			tx, _ := types.SignTx(types.NewTransaction(types.ConfirmationData, 0, common.Address{}, nil, nil, price, []byte(payload)), work.signer, key)
+			pending[crypto.PubkeyToAddress(key.PublicKey)] = types.Transactions{tx}
+		}
+		work.commitTransactions(new(event.TypeMux), work.orderTransactions(pending), nil, common.Address{})
+
+		if len(work.txs) != len(test.included) {
+			t.Errorf("test %d: included transaction count mismatch: have %d, want %d", i, len(work.txs), len(test.included))
+			continue
+		}
+		for j, tx := range work.txs {
+			if string(tx.Data()) != test.included[j] {
+				t.Errorf("test %d: transaction %d payload mismatch: have %q, want %q", i, j, tx.Data(), test.included[j])
+			}
+		}
+	}
+}
+
+// Tests that the build deadline counts from the slot of the block.
+func TestBuildDeadline(t *testing.T) {
+	header := &types.Header{Time: big.NewInt(1000)}
+	if deadline := buildDeadline(header, 5*time.Second); !deadline.Equal(time.Unix(1005, 0)) {
+		t.Fatalf("deadline mismatch: have %v, want %v", deadline, time.Unix(1005, 0))
+	}
+	if deadline := buildDeadline(header, 0); !deadline.IsZero() {
+		t.Fatalf("deadline without build time: have %v, want none", deadline)
+	}
+}
@@ -41,8 +41,8 @@ var (
 
 		Poc: &PocConfig{},
 	}
-	TestChainConfig          = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil}
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil}
+	TestChainConfig          = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil}
 )
 
 // ChainConfig is the core config which determines the blockchain settings.
@@ -67,6 +67,21 @@ type ChainConfig struct {
 
 	ByzantiumBlock *big.Int `json:"byzantiumBlock,omitempty"` // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 
+	RecordTrieBlock        *big.Int `json:"recordTrieBlock,omitempty"`        // Owned records move from the account body into a per-account trie (nil = no fork)
+	RecordMarketBlock      *big.Int `json:"recordMarketBlock,omitempty"`      // Confirmations carry record terms, transfers move the owner and may be priced (nil = no fork)
+	BatchConfirmationBlock *big.Int `json:"batchConfirmationBlock,omitempty"` // Senders may confirm many records in one transaction committing to their merkle root (nil = no fork)
+	RecordTombstoneBlock   *big.Int `json:"recordTombstoneBlock,omitempty"`   // Approved takedowns may erase records and prune their payloads (nil = no fork)
+
+	FeeBlock *big.Int   `json:"feeBlock,omitempty"` // Senders pay the fee schedule to the block validator (nil = no fork)
+	Fee      *FeeConfig `json:"fee,omitempty"`      // Fee schedule charged from the fee block on
+
+	BlockLimitsBlock *big.Int     `json:"blockLimitsBlock,omitempty"` // Blocks are bounded by the block limits (nil = no fork)
+	BlockLimits      *BlockLimits `json:"blockLimits,omitempty"`      // Capacity of a block from the block limits block on (nil = unbounded)
+
+	PocNonceBlock   *big.Int `json:"pocNonceBlock,omitempty"`   // Candidate logins and logouts consume the sender nonce and fail if they change nothing (nil = no fork)
+	TxEnvelopeBlock *big.Int `json:"txEnvelopeBlock,omitempty"` // Transactions may carry envelope extensions: validity window, sponsor, multisig account (nil = no fork)
+	FailedTxBlock   *big.Int `json:"failedTxBlock,omitempty"`   // Failed data and candidate transactions are included with a failure reason instead of rejected (nil = no fork)
+
 	Poc *PocConfig `json:"poc,omitempty"`
 }
 
@@ -91,9 +106,65 @@ func (d *PocConfig) String() string {
 	return "poc"
 }
 
+// FeeConfig is the fee schedule of transactions. Every transaction pays the
+// base fee of its type plus a fee per byte of payload, so that filling blocks
+// with records has a cost.
+type FeeConfig struct {
+	BaseFee  *big.Int           `json:"baseFee"`            // Base fee of types without an entry in TypeFees
+	TypeFees map[uint8]*big.Int `json:"typeFees,omitempty"` // Base fee per transaction type
+	ByteFee  *big.Int           `json:"byteFee"`            // Fee per byte of transaction payload
+}
+
+// Fee returns the fee of a transaction of the given type carrying size bytes
+// of payload.
+func (f *FeeConfig) Fee(txType uint8, size int) *big.Int {
+	fee := new(big.Int)
+	if base, ok := f.TypeFees[txType]; ok && base != nil {
+		fee.Set(base)
+	} else if f.BaseFee != nil {
+		fee.Set(f.BaseFee)
+	}
+	if f.ByteFee != nil && size > 0 {
+		fee.Add(fee, new(big.Int).Mul(f.ByteFee, big.NewInt(int64(size))))
+	}
+	return fee
+}
+
+// equal reports whether two fee schedules charge the same fees.
+func (f *FeeConfig) equal(g *FeeConfig) bool {
+	if f == nil || g == nil {
+		return f == g
+	}
+	if !configNumEqual(f.BaseFee, g.BaseFee) || !configNumEqual(f.ByteFee, g.ByteFee) || len(f.TypeFees) != len(g.TypeFees) {
+		return false
+	}
+	for txType, fee := range f.TypeFees {
+		other, ok := g.TypeFees[txType]
+		if !ok || !configNumEqual(fee, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockLimits bounds the contents of a block, since transactions consume no
+// gas that would fill it up. Zero values leave the dimension unbounded.
+type BlockLimits struct {
+	MaxTxs          uint64 `json:"maxTxs,omitempty"`          // Maximum number of transactions in a block
+	MaxPayloadBytes uint64 `json:"maxPayloadBytes,omitempty"` // Maximum total payload bytes of the transactions in a block
+}
+
+// equal reports whether two block limits bound blocks the same way.
+func (l *BlockLimits) equal(m *BlockLimits) bool {
+	if l == nil || m == nil {
+		return l == m
+	}
+	return *l == *m
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v RecordTrie: %v RecordMarket: %v BatchConfirmation: %v RecordTombstone: %v Fee: %v BlockLimits: %v PocNonce: %v TxEnvelope: %v FailedTx: %v Engine: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -102,6 +173,15 @@ func (c *ChainConfig) String() string {
 		c.EIP155Block,
 		c.EIP158Block,
 		c.ByzantiumBlock,
+		c.RecordTrieBlock,
+		c.RecordMarketBlock,
+		c.BatchConfirmationBlock,
+		c.RecordTombstoneBlock,
+		c.FeeBlock,
+		c.BlockLimitsBlock,
+		c.PocNonceBlock,
+		c.TxEnvelopeBlock,
+		c.FailedTxBlock,
 		c.Poc,
 	)
 }
@@ -132,6 +212,57 @@ func (c *ChainConfig) IsByzantium(num *big.Int) bool {
 	return isForked(c.ByzantiumBlock, num)
 }
 
+// IsRecordTrie returns whether num is either equal to the record trie fork block or greater.
+func (c *ChainConfig) IsRecordTrie(num *big.Int) bool {
+	return isForked(c.RecordTrieBlock, num)
+}
+
+// IsRecordMarket returns whether num is either equal to the record market fork block or greater.
+func (c *ChainConfig) IsRecordMarket(num *big.Int) bool {
+	return isForked(c.RecordMarketBlock, num)
+}
+
+// IsBatchConfirmation returns whether num is either equal to the batch confirmation
+// fork block or greater.
+func (c *ChainConfig) IsBatchConfirmation(num *big.Int) bool {
+	return isForked(c.BatchConfirmationBlock, num)
+}
+
+// IsRecordTombstone returns whether num is either equal to the record tombstone
+// fork block or greater.
+func (c *ChainConfig) IsRecordTombstone(num *big.Int) bool {
+	return isForked(c.RecordTombstoneBlock, num)
+}
+
+// IsFee returns whether num is either equal to the fee fork block or greater
+// and a fee schedule is configured.
+func (c *ChainConfig) IsFee(num *big.Int) bool {
+	return c.Fee != nil && isForked(c.FeeBlock, num)
+}
+
+// IsBlockLimits returns whether num is either equal to the block limits fork
+// block or greater and block limits are configured.
+func (c *ChainConfig) IsBlockLimits(num *big.Int) bool {
+	return c.BlockLimits != nil && isForked(c.BlockLimitsBlock, num)
+}
+
+// IsPocNonce returns whether num is either equal to the poc nonce fork block or greater.
+func (c *ChainConfig) IsPocNonce(num *big.Int) bool {
+	return isForked(c.PocNonceBlock, num)
+}
+
+// IsTxEnvelope returns whether num is either equal to the transaction envelope
+// fork block or greater.
+func (c *ChainConfig) IsTxEnvelope(num *big.Int) bool {
+	return isForked(c.TxEnvelopeBlock, num)
+}
+
+// IsFailedTx returns whether num is either equal to the failed transaction
+// inclusion fork block or greater.
+func (c *ChainConfig) IsFailedTx(num *big.Int) bool {
+	return isForked(c.FailedTxBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -192,6 +323,39 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ByzantiumBlock, newcfg.ByzantiumBlock, head) {
 		return newCompatError("Byzantium fork block", c.ByzantiumBlock, newcfg.ByzantiumBlock)
 	}
+	if isForkIncompatible(c.RecordTrieBlock, newcfg.RecordTrieBlock, head) {
+		return newCompatError("RecordTrie fork block", c.RecordTrieBlock, newcfg.RecordTrieBlock)
+	}
+	if isForkIncompatible(c.RecordMarketBlock, newcfg.RecordMarketBlock, head) {
+		return newCompatError("RecordMarket fork block", c.RecordMarketBlock, newcfg.RecordMarketBlock)
+	}
+	if isForkIncompatible(c.BatchConfirmationBlock, newcfg.BatchConfirmationBlock, head) {
+		return newCompatError("BatchConfirmation fork block", c.BatchConfirmationBlock, newcfg.BatchConfirmationBlock)
+	}
+	if isForkIncompatible(c.RecordTombstoneBlock, newcfg.RecordTombstoneBlock, head) {
+		return newCompatError("RecordTombstone fork block", c.RecordTombstoneBlock, newcfg.RecordTombstoneBlock)
+	}
+	if isForkIncompatible(c.FeeBlock, newcfg.FeeBlock, head) {
+		return newCompatError("Fee fork block", c.FeeBlock, newcfg.FeeBlock)
+	}
+	if isForked(c.FeeBlock, head) && !c.Fee.equal(newcfg.Fee) {
+		return newCompatError("Fee schedule", c.FeeBlock, newcfg.FeeBlock)
+	}
+	if isForkIncompatible(c.BlockLimitsBlock, newcfg.BlockLimitsBlock, head) {
+		return newCompatError("BlockLimits fork block", c.BlockLimitsBlock, newcfg.BlockLimitsBlock)
+	}
+	if isForked(c.BlockLimitsBlock, head) && !c.BlockLimits.equal(newcfg.BlockLimits) {
+		return newCompatError("BlockLimits", c.BlockLimitsBlock, newcfg.BlockLimitsBlock)
+	}
+	if isForkIncompatible(c.PocNonceBlock, newcfg.PocNonceBlock, head) {
+		return newCompatError("PocNonce fork block", c.PocNonceBlock, newcfg.PocNonceBlock)
+	}
+	if isForkIncompatible(c.TxEnvelopeBlock, newcfg.TxEnvelopeBlock, head) {
+		return newCompatError("TxEnvelope fork block", c.TxEnvelopeBlock, newcfg.TxEnvelopeBlock)
+	}
+	if isForkIncompatible(c.FailedTxBlock, newcfg.FailedTxBlock, head) {
+		return newCompatError("FailedTx fork block", c.FailedTxBlock, newcfg.FailedTxBlock)
+	}
 	return nil
 }
 
@@ -70,6 +70,78 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{FeeBlock: big.NewInt(10), Fee: &FeeConfig{BaseFee: big.NewInt(1)}},
+			new:     &ChainConfig{FeeBlock: big.NewInt(10), Fee: &FeeConfig{BaseFee: big.NewInt(2)}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{FeeBlock: big.NewInt(10), Fee: &FeeConfig{BaseFee: big.NewInt(1), TypeFees: map[uint8]*big.Int{1: big.NewInt(5)}}},
+			new:    &ChainConfig{FeeBlock: big.NewInt(10), Fee: &FeeConfig{BaseFee: big.NewInt(1), TypeFees: map[uint8]*big.Int{1: big.NewInt(6)}}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "Fee schedule",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{BlockLimitsBlock: big.NewInt(10), BlockLimits: &BlockLimits{MaxTxs: 100}},
+			new:    &ChainConfig{BlockLimitsBlock: big.NewInt(10), BlockLimits: &BlockLimits{MaxTxs: 200}},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         "BlockLimits",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{TxEnvelopeBlock: big.NewInt(10)},
+			new:    &ChainConfig{TxEnvelopeBlock: big.NewInt(20)},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "TxEnvelope fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{FailedTxBlock: big.NewInt(10)},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "FailedTx fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{BatchConfirmationBlock: big.NewInt(10)},
+			new:    &ChainConfig{BatchConfirmationBlock: big.NewInt(5)},
+			head:   8,
+			wantErr: &ConfigCompatError{
+				What:         "BatchConfirmation fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(5),
+				RewindTo:     4,
+			},
+		},
+		{
+			stored:  &ChainConfig{RecordTombstoneBlock: nil},
+			new:     &ChainConfig{RecordTombstoneBlock: big.NewInt(5)},
+			head:    10,
+			wantErr: &ConfigCompatError{
+				What:         "RecordTombstone fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(5),
+				RewindTo:     4,
+			},
+		},
 	}
 
 	for _, test := range tests {
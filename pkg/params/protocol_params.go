@@ -55,6 +55,29 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	BatchContributionLeafCap uint64 = 64 // Maximum number of leaves of a batch confirmation credited with contribution
+
+	RoyaltyBasisPoints uint64 = 10000 // Denominator of the royalty rate fixed by the origin of a record
+	MaxRoyalty         uint64 = 5000  // Maximum royalty rate in basis points a record may carry
+
+	TransferOfferDuration uint64 = 17280 // Number of blocks a record transfer offer stays open for the recipient to accept
+
+	MaxJointOwners         uint64 = 16    // Maximum number of co-owners of a jointly owned record
+	RecordProposalDuration uint64 = 17280 // Number of blocks a proposal on a jointly owned record collects approvals
+
+	RecordRenewalPeriod uint64 = 6307200 // Number of blocks a renewal extends the expiry of a record by
+	RecordRenewalFee    uint64 = 1e16    // Fee in wei burned by the sender of a record renewal
+
+	MaxOperations uint64 = 16 // Maximum number of operations in a multi-operation transaction
+
+	MaxMultisigSigners uint64 = 16 // Maximum number of signers of a multisig account
+
+	TxPriorityContributionUnit uint64 = 1e18 // Contribution raising the priority of a sender's transactions by one percent
+	TxPriorityContributionCap  uint64 = 100  // Maximum percentage the contribution of the sender raises a priority by
+	TxPriorityAgePeriod        uint64 = 30   // Seconds a transaction waits to raise its priority by one percent
+	TxPriorityAgeCap           uint64 = 100  // Maximum percentage waiting raises a priority by
+	PocTxQuota                 uint64 = 16   // Number of transactions per block reserved for candidate logins and logouts
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas            uint64 = 3000   // Elliptic curve sender recovery gas price
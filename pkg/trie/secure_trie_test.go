@@ -22,9 +22,9 @@ import (
 	"sync"
 	"testing"
 
-	"AQChainRe/common"
-	"AQChainRe/crypto"
-	"AQChainRe/ethdb"
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
 )
 
 func newEmptySecure() *SecureTrie {
@@ -96,8 +96,9 @@ func NewTrieSync(root common.Hash, database DatabaseReader, callback TrieSyncLea
 
 // AddSubTrie registers a new trie to the sync code, rooted at the designated parent.
 func (s *TrieSync) AddSubTrie(root common.Hash, depth int, parent common.Hash, callback TrieSyncLeafCallback) {
-	// Short circuit if the trie is empty or already known
-	if root == emptyRoot {
+	// Short circuit if the trie is empty or already known. A zero root is
+	// treated as empty the same way New does, as uncommitted contexts carry it.
+	if root == emptyRoot || root == (common.Hash{}) {
 		return
 	}
 	if _, ok := s.membatch.batch[root]; ok {
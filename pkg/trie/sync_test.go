@@ -349,3 +349,58 @@ func TestIncompleteTrieSync(t *testing.T) {
 		dstDb.Put(key, value)
 	}
 }
+
+// Tests that several prefixed tries, scheduled into a single sync the way the
+// poc context tries are, can be reopened with their prefix once retrieved.
+func TestPrefixedTrieSync(t *testing.T) {
+	srcDb, _ := ethdb.NewMemDatabase()
+
+	prefixes := [][]byte{[]byte("epoch-"), []byte("candidate-"), []byte("mintCnt-")}
+	roots := make([]common.Hash, len(prefixes))
+	for i, prefix := range prefixes {
+		trie, _ := NewTrieWithPrefix(common.Hash{}, prefix, srcDb)
+		for j := byte(0); j < 100; j++ {
+			trie.Update(common.LeftPadBytes([]byte{j}, 20), []byte{j})
+		}
+		roots[i], _ = trie.Commit()
+	}
+	// Schedule all the tries and an uncommitted (zero) root into one sync
+	dstDb, _ := ethdb.NewMemDatabase()
+	sched := NewTrieSync(roots[0], dstDb, nil)
+	for _, root := range append(roots[1:], common.Hash{}) {
+		sched.AddSubTrie(root, 0, common.Hash{}, nil)
+	}
+	queue := append([]common.Hash{}, sched.Missing(100)...)
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Get(hash.Bytes())
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if index, err := sched.Commit(dstDb); err != nil {
+			t.Fatalf("failed to commit data #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(100)...)
+	}
+	// Cross check that the prefixed tries are complete and readable
+	for i, prefix := range prefixes {
+		if err := checkTrieConsistency(dstDb, roots[i]); err != nil {
+			t.Fatalf("trie %q inconsistent: %v", prefix, err)
+		}
+		trie, err := NewTrieWithPrefix(roots[i], prefix, dstDb)
+		if err != nil {
+			t.Fatalf("failed to open trie %q: %v", prefix, err)
+		}
+		for j := byte(0); j < 100; j++ {
+			if have := trie.Get(common.LeftPadBytes([]byte{j}, 20)); !bytes.Equal(have, []byte{j}) {
+				t.Errorf("trie %q entry %d: content mismatch: have %x, want %x", prefix, j, have, []byte{j})
+			}
+		}
+	}
+}
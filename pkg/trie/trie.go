@@ -127,6 +127,10 @@ func New(root common.Hash, db Database) (*Trie, error) {
 	return trie, nil
 }
 
+// NewTrieWithPrefix creates a trie like New, prepending prefix to every key
+// the trie is accessed with. The prefix only lives in the key path: nodes are
+// still stored under their bare hash, so a prefixed trie is retrieved by
+// TrieSync and the node data protocol like any other trie.
 func NewTrieWithPrefix(root common.Hash, prefix []byte, db Database) (*Trie, error) {
 	trie, err := New(root, db)
 	if err != nil {
@@ -17,9 +17,9 @@
 package trie
 
 import (
-	"AQChainRe/common"
-	"AQChainRe/crypto"
-	"AQChainRe/ethdb"
+	"AQChainRe/pkg/common"
+	"AQChainRe/pkg/crypto"
+	"AQChainRe/pkg/ethdb"
 	"AQChainRe/pkg/rlp"
 	"bytes"
 	"encoding/binary"